@@ -0,0 +1,760 @@
+// cmd/bilibili-uploader/main.go - B站一键投稿命令行工具
+package main
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/logging"
+    "bilibili-uploader/services"
+    "bilibili-uploader/tracing"
+    "bufio"
+    "context"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/url"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/codes"
+)
+
+const usage = `B站一键投稿命令行工具
+
+用法:
+  bilibili-uploader <命令> [参数]
+
+命令:
+  login [--qr|--cookie]   登录B站账号并保存凭证
+  upload <文件> --title   上传视频并投稿
+  process <文件>          使用FFmpeg转码视频
+  status <BV号>           查询稿件审核状态
+  jobs                    查看后台任务
+  history                 查看投稿历史
+  rotate-keys             用当前密钥重新加密服务端保存的B站凭证
+
+全局环境变量:
+  BILIBILI_UPLOADER_HOME  凭证和历史记录目录（默认 ~/.config/bilibili-uploader）
+  BILIBILI_ACCESS_TOKEN   直接使用该访问令牌，跳过本地凭证（适合CI）
+  BILIBILI_API_URL 等     B站接口地址（BILIBILI_MEMBER_URL、BILIBILI_PASSPORT_URL），可指向模拟接口
+  OUTBOUND_PROXY          出站代理（http、https或socks5）
+  LOG_LEVEL、LOG_FORMAT   日志级别（debug、info、warn、error）和格式（text、json）
+  UPLOAD_PART_RETRIES     分片上传失败时的重试次数（默认2）
+  OTEL_TRACES_EXPORTER    链路追踪导出器（otlp、stdout、none），OTLP地址见OTEL_EXPORTER_OTLP_ENDPOINT
+  METRICS_FILE            命令结束后把Prometheus指标写入该文件（供node_exporter textfile收集）
+
+使用 "bilibili-uploader <命令> -h" 查看命令参数
+`
+
+func main() {
+    if len(os.Args) < 2 {
+        fmt.Fprint(os.Stderr, usage)
+        os.Exit(2)
+    }
+
+    commands := map[string]func(ctx context.Context, args []string) error{
+        "login":   runLogin,
+        "upload":  runUpload,
+        "process": runProcess,
+        "status":  runStatus,
+        "jobs":    runJobs,
+        "history": runHistory,
+
+        "rotate-keys": runRotateKeys,
+    }
+
+    name := os.Args[1]
+    if name == "-h" || name == "--help" || name == "help" {
+        fmt.Print(usage)
+        return
+    }
+
+    command, ok := commands[name]
+    if !ok {
+        fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", name, usage)
+        os.Exit(2)
+    }
+
+    config.Load()
+    if err := logging.Setup(logging.Options{
+        Format: config.GlobalConfig.LogFormat,
+        Level:  config.GlobalConfig.LogLevel,
+    }); err != nil {
+        fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+        os.Exit(1)
+    }
+    config.Validate()
+    if err := configureBilibili(); err != nil {
+        fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+        os.Exit(1)
+    }
+
+    shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+        Exporter:    config.GlobalConfig.TraceExporter,
+        Protocol:    config.GlobalConfig.TraceProtocol,
+        ServiceName: config.GlobalConfig.ServiceName,
+        Output:      os.Stderr, // 标准输出留给命令结果
+    })
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+        os.Exit(1)
+    }
+
+    // Ctrl-C或SIGTERM时取消ctx：FFmpeg被终止，上传保存断点，再次执行同一命令从断点继续
+    signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+    defer stopSignals()
+
+    // 整个命令一条trace，上传和转码各阶段的span挂在它下面
+    ctx, span := otel.Tracer("bilibili-uploader/cli").Start(signalCtx, "bilibili-uploader "+name)
+    err = command(ctx, os.Args[2:])
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+    if err := shutdownTracing(context.Background()); err != nil {
+        fmt.Fprintf(os.Stderr, "⚠️  导出链路追踪数据失败: %v\n", err)
+    }
+
+    if path := os.Getenv("METRICS_FILE"); path != "" {
+        if err := writeMetricsFile(path); err != nil {
+            fmt.Fprintf(os.Stderr, "⚠️  写入指标文件失败: %v\n", err)
+        }
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+        os.Exit(1)
+    }
+}
+
+// ===== 子命令 =====
+
+// runLogin 登录并保存凭证
+func runLogin(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("login", flag.ExitOnError)
+    token := fs.String("token", "", "直接保存已有的访问令牌")
+    code := fs.String("code", "", "OAuth授权码（不提供时交互输入）")
+    qr := fs.Bool("qr", false, "在终端显示二维码，使用B站App扫码登录")
+    cookie := fs.String("cookie", "", "导入浏览器Cookie，如 \"SESSDATA=...; bili_jct=...; DedeUserID=...\"")
+    parseArgs(fs, args)
+
+    if *qr {
+        return loginWithQRCode(ctx)
+    }
+    if *cookie != "" {
+        cred, err := services.ParseCookieString(*cookie)
+        if err != nil {
+            return err
+        }
+        return saveWebLogin(ctx, cred)
+    }
+
+    oauth := services.NewBilibiliOAuth(
+        config.GlobalConfig.BilibiliClientID,
+        config.GlobalConfig.BilibiliClientSecret,
+        config.GlobalConfig.BilibiliRedirectURI,
+    )
+
+    creds := &Credentials{AccessToken: *token}
+
+    if creds.AccessToken == "" {
+        if !config.IsBilibiliConfigured() {
+            return fmt.Errorf("未配置BILIBILI_CLIENT_ID/BILIBILI_CLIENT_SECRET，请使用 --token 登录")
+        }
+
+        authCode, codeVerifier := *code, ""
+        if authCode == "" {
+            state, err := services.RandomID(16)
+            if err != nil {
+                return err
+            }
+            pkce, err := services.NewPKCE()
+            if err != nil {
+                return err
+            }
+            codeVerifier = pkce.Verifier
+
+            fmt.Println("请在浏览器中打开以下地址完成授权:")
+            fmt.Printf("  %s\n\n", oauth.AuthURL(state, pkce.Challenge))
+            fmt.Print("授权后将回调地址（或其中的 code 参数）粘贴到这里: ")
+
+            line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+            if err != nil {
+                return fmt.Errorf("读取授权码失败: %v", err)
+            }
+            authCode, err = parseAuthCode(strings.TrimSpace(line), state)
+            if err != nil {
+                return err
+            }
+        }
+
+        tokenResp, err := oauth.ExchangeCode(ctx, authCode, codeVerifier)
+        if err != nil {
+            return err
+        }
+        creds.AccessToken = tokenResp.AccessToken
+        creds.RefreshToken = tokenResp.RefreshToken
+        if tokenResp.ExpiresIn > 0 {
+            creds.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+        }
+    }
+
+    userInfo, err := oauth.GetUserInfo(ctx, creds.AccessToken)
+    if err != nil {
+        return err
+    }
+    creds.UID = userInfo.UID
+    creds.Username = userInfo.Username
+
+    if err := saveCredentials(creds); err != nil {
+        return fmt.Errorf("保存凭证失败: %v", err)
+    }
+
+    fmt.Printf("✅ 登录成功: %s (UID %d)\n", creds.Username, creds.UID)
+    fmt.Printf("   凭证已保存到 %s\n", credentialsPath())
+    return nil
+}
+
+// loginWithQRCode 扫码登录并保存Cookie
+func loginWithQRCode(ctx context.Context) error {
+    qrLogin := services.NewQRLogin()
+    qr, err := qrLogin.Generate(ctx)
+    if err != nil {
+        return err
+    }
+
+    text, err := services.RenderQRCodeText(qr.URL)
+    if err != nil {
+        return err
+    }
+    fmt.Println("请使用B站App扫描二维码登录:")
+    fmt.Println(text)
+
+    lastStatus := ""
+    for {
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-time.After(2 * time.Second):
+        }
+
+        result, err := qrLogin.Poll(ctx, qr.Key)
+        if err != nil {
+            return err
+        }
+        if result.Status != lastStatus {
+            lastStatus = result.Status
+            switch result.Status {
+            case services.QRStatusScanned:
+                fmt.Println("📱 已扫码，请在手机上确认登录")
+            case services.QRStatusExpired:
+                return fmt.Errorf("二维码已过期，请重新执行 login --qr")
+            }
+        }
+        if result.Status != services.QRStatusConfirmed {
+            continue
+        }
+
+        cred := services.NewWebCredential(result.Cookies)
+        cred.RefreshToken = result.RefreshToken
+        return saveWebLogin(ctx, cred)
+    }
+}
+
+// saveWebLogin 校验并保存网页Cookie凭证
+func saveWebLogin(ctx context.Context, cred *services.WebCredential) error {
+    oauth := services.NewBilibiliOAuth("", "", "")
+    userInfo, err := services.CheckWebCredential(ctx, oauth.BaseURL, cred)
+    if err != nil {
+        return err
+    }
+
+    creds := &Credentials{
+        Web:      cred,
+        UID:      userInfo.UID,
+        Username: userInfo.Username,
+    }
+    if err := saveCredentials(creds); err != nil {
+        return fmt.Errorf("保存凭证失败: %v", err)
+    }
+
+    fmt.Printf("✅ 登录成功: %s (UID %d)\n", creds.Username, creds.UID)
+    fmt.Printf("   凭证已保存到 %s\n", credentialsPath())
+    return nil
+}
+
+// runUpload 上传视频
+func runUpload(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("upload", flag.ExitOnError)
+    title := fs.String("title", "", "视频标题（必填）")
+    desc := fs.String("desc", "", "视频简介")
+    tags := fs.String("tags", "", "标签，逗号分隔")
+    category := fs.Int("tid", 21, "分区ID")
+    copyright := fs.Int("copyright", 1, "1:自制 2:转载")
+    source := fs.String("source", "", "转载来源")
+    cover := fs.String("cover", "", "封面URL")
+    quality := fs.String("quality", "", "上传前转码: high, medium, low")
+    resolution := fs.String("resolution", "", "转码分辨率: 1080p, 720p, 480p")
+    files := parseArgs(fs, args)
+
+    if len(files) != 1 {
+        return fmt.Errorf("用法: bilibili-uploader upload <文件> --title 标题")
+    }
+    if *title == "" {
+        return fmt.Errorf("视频标题不能为空")
+    }
+
+    if _, err := os.Stat(files[0]); err != nil {
+        return fmt.Errorf("读取视频文件失败: %v", err)
+    }
+
+    creds, err := loadCredentials(ctx)
+    if err != nil {
+        return err
+    }
+
+    videoPath := files[0]
+    jobs := services.NewJobStore(filepath.Join(homeDir(), "jobs.json"))
+    services.SetMetricsSources(jobs, config.GlobalConfig.TempDir)
+    job := jobs.Create(videoPath, *title)
+    if traceID := tracing.TraceID(ctx); traceID != "" {
+        jobs.Update(job.ID, func(j *services.Job) {
+            j.TraceID = traceID
+        })
+    }
+
+    fail := func(stage string, err error) error {
+        status := services.JobStatusFailed
+        if ctx.Err() != nil {
+            status = services.JobStatusInterrupted
+            if stage == "upload" {
+                err = fmt.Errorf("%v（已保存上传进度，再次执行相同命令从断点继续）", err)
+            }
+        }
+        jobs.Update(job.ID, func(j *services.Job) {
+            j.Status = status
+            j.Stage = stage
+            j.Error = err.Error()
+        })
+        return err
+    }
+
+    if *quality != "" || *resolution != "" {
+        jobs.Update(job.ID, func(j *services.Job) {
+            j.Status = services.JobStatusProcessing
+            j.Stage = "process"
+        })
+        output, err := processFile(ctx, videoPath, os.TempDir(), services.ProcessOptions{
+            Quality:    *quality,
+            Resolution: *resolution,
+        })
+        if err != nil {
+            return fail("process", err)
+        }
+        defer os.Remove(output)
+        videoPath = output
+    }
+
+    params := services.VideoUploadParams{
+        Title:       *title,
+        Description: *desc,
+        Tags:        splitTags(*tags),
+        Category:    *category,
+        Cover:       *cover,
+        Source:      *source,
+        Copyright:   *copyright,
+    }
+
+    jobs.Update(job.ID, func(j *services.Job) {
+        j.Status = services.JobStatusUploading
+        j.Stage = "upload"
+    })
+    fmt.Printf("🚀 开始上传: %s\n", videoPath)
+
+    bvid, err := creds.uploader().UploadVideoContext(ctx, videoPath, params)
+    if err != nil {
+        return fail("upload", err)
+    }
+
+    jobs.Update(job.ID, func(j *services.Job) {
+        j.Status = services.JobStatusDone
+        j.Stage = ""
+        j.BVID = bvid
+    })
+
+    history := services.NewHistoryStore(filepath.Join(homeDir(), "history.json"))
+    if err := history.Add(services.HistoryRecord{
+        BVID:     bvid,
+        Title:    *title,
+        File:     files[0],
+        Category: *category,
+    }); err != nil {
+        fmt.Fprintf(os.Stderr, "⚠️  保存投稿历史失败: %v\n", err)
+    }
+
+    fmt.Printf("✅ 上传成功! BV号: %s\n", bvid)
+    fmt.Printf("   https://www.bilibili.com/video/%s\n", bvid)
+    return nil
+}
+
+// runProcess 转码视频
+func runProcess(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("process", flag.ExitOnError)
+    quality := fs.String("quality", "medium", "质量: high, medium, low")
+    resolution := fs.String("resolution", "", "分辨率: 1080p, 720p, 480p")
+    outDir := fs.String("out", config.GlobalConfig.ProcessedDir, "输出目录")
+    files := parseArgs(fs, args)
+
+    if len(files) != 1 {
+        return fmt.Errorf("用法: bilibili-uploader process <文件> [--quality high]")
+    }
+
+    output, err := processFile(ctx, files[0], *outDir, services.ProcessOptions{
+        Quality:    *quality,
+        Resolution: *resolution,
+    })
+    if err != nil {
+        return err
+    }
+
+    fmt.Printf("✅ 处理完成: %s\n", output)
+    return nil
+}
+
+// runStatus 查询稿件状态
+func runStatus(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("status", flag.ExitOnError)
+    asJSON := fs.Bool("json", false, "以JSON格式输出")
+    bvids := parseArgs(fs, args)
+
+    if len(bvids) != 1 {
+        return fmt.Errorf("用法: bilibili-uploader status <BV号>")
+    }
+
+    creds, err := loadCredentials(ctx)
+    if err != nil {
+        return err
+    }
+
+    status, err := creds.uploader().GetArchiveStatusContext(ctx, bvids[0])
+    if err != nil {
+        return err
+    }
+
+    if *asJSON {
+        return printJSON(status)
+    }
+
+    fmt.Printf("BV号: %s\n", status.BVID)
+    fmt.Printf("标题: %s\n", status.Title)
+    fmt.Printf("状态: %s (%d)\n", status.StateDesc, status.State)
+    if status.RejectReason != "" {
+        fmt.Printf("原因: %s\n", status.RejectReason)
+    }
+    return nil
+}
+
+// runJobs 列出后台任务
+func runJobs(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("jobs", flag.ExitOnError)
+    dataDir := fs.String("data-dir", homeDir(), "任务记录所在目录（查看服务端任务时指定DATA_DIR）")
+    asJSON := fs.Bool("json", false, "以JSON格式输出")
+    parseArgs(fs, args)
+
+    jobs := services.NewJobStore(filepath.Join(*dataDir, "jobs.json")).List()
+    if *asJSON {
+        return printJSON(jobs)
+    }
+
+    if len(jobs) == 0 {
+        fmt.Println("暂无任务")
+        return nil
+    }
+    for _, job := range jobs {
+        line := fmt.Sprintf("%s  %-10s  %s  %s", job.CreatedAt.Format("2006-01-02 15:04:05"), job.Status, job.ID, job.Source)
+        if job.BVID != "" {
+            line += "  -> " + job.BVID
+        }
+        if job.Error != "" {
+            line += fmt.Sprintf("  [%s] %s", job.Stage, job.Error)
+        }
+        fmt.Println(line)
+    }
+    return nil
+}
+
+// runHistory 列出投稿历史
+func runHistory(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("history", flag.ExitOnError)
+    asJSON := fs.Bool("json", false, "以JSON格式输出")
+    parseArgs(fs, args)
+
+    records := services.NewHistoryStore(filepath.Join(homeDir(), "history.json")).List()
+    if *asJSON {
+        return printJSON(records)
+    }
+
+    if len(records) == 0 {
+        fmt.Println("暂无投稿记录")
+        return nil
+    }
+    for _, record := range records {
+        fmt.Printf("%s  %s  %s\n", record.UploadedAt.Format("2006-01-02 15:04:05"), record.BVID, record.Title)
+    }
+    return nil
+}
+
+// runRotateKeys 用当前密钥重新加密服务端凭证，可在服务运行时执行
+func runRotateKeys(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+    dataDir := fs.String("data-dir", config.GlobalConfig.DataDir, "服务端数据目录（DATA_DIR）")
+    newKey := fs.Bool("new-key", false, "只生成一个新密钥并输出，不执行轮换")
+    parseArgs(fs, args)
+
+    if *newKey {
+        key, err := services.GenerateKey()
+        if err != nil {
+            return err
+        }
+        fmt.Println(key)
+        return nil
+    }
+
+    if config.GlobalConfig.CredentialKeys == "" {
+        return fmt.Errorf("未配置CREDENTIAL_KEYS")
+    }
+    keyring, err := services.ParseKeyring(config.GlobalConfig.CredentialKeys, config.GlobalConfig.CredentialKeyVersion)
+    if err != nil {
+        return fmt.Errorf("CREDENTIAL_KEYS配置错误: %v", err)
+    }
+
+    for _, name := range []string{services.TokenStoreFile, services.CredentialStoreFile} {
+        path := filepath.Join(*dataDir, name)
+        rotated, err := services.RotateSealedFile(path, keyring)
+        if err != nil {
+            return fmt.Errorf("轮换%s失败: %v", name, err)
+        }
+        fmt.Printf("✅ %s: 重新加密 %d 条记录（密钥版本 %d）\n", name, rotated, keyring.CurrentVersion())
+    }
+
+    fmt.Println("所有凭证已使用当前密钥加密，可以从CREDENTIAL_KEYS中移除旧密钥")
+    return nil
+}
+
+// ===== 辅助函数 =====
+
+// Credentials 本地保存的登录凭证
+type Credentials struct {
+    AccessToken  string                  `json:"access_token,omitempty"`
+    Web          *services.WebCredential `json:"web,omitempty"` // 扫码登录或导入的网页Cookie
+    RefreshToken string                  `json:"refresh_token,omitempty"`
+    ExpiresAt    time.Time               `json:"expires_at,omitempty"`
+    UID          int64                   `json:"uid"`
+    Username     string                  `json:"username"`
+}
+
+// uploader 根据凭证类型创建上传器
+func (c *Credentials) uploader() *services.BilibiliUploader {
+    var uploader *services.BilibiliUploader
+    if c.Web != nil {
+        uploader = services.NewBilibiliUploaderWithCookie(c.Web)
+    } else {
+        uploader = services.NewBilibiliUploaderWithAuth(services.NewTokenAuthenticator(
+            c.AccessToken,
+            config.GlobalConfig.BilibiliAppKey,
+            config.GlobalConfig.BilibiliAppSecret,
+        ))
+    }
+    uploader.PartRetries = config.GlobalConfig.UploadPartRetries
+    uploader.Checkpoints = services.NewCheckpointStore(filepath.Join(homeDir(), services.CheckpointStoreFile))
+    return uploader
+}
+
+// configureBilibili 按配置设置B站接口地址和出站HTTP客户端
+func configureBilibili() error {
+    cfg := config.GlobalConfig
+    client, err := services.NewHTTPClient(services.HTTPOptions{
+        Timeout:   cfg.HTTPTimeout,
+        ProxyURL:  cfg.HTTPProxy,
+        UserAgent: cfg.UserAgent,
+    })
+    if err != nil {
+        return fmt.Errorf("OUTBOUND_PROXY配置错误: %v", err)
+    }
+    services.SetBilibiliDefaults(services.BilibiliEndpoints{
+        API:      cfg.BilibiliAPIURL,
+        Member:   cfg.BilibiliMemberURL,
+        Passport: cfg.BilibiliPassportURL,
+    }, client)
+    return nil
+}
+
+// writeMetricsFile 把指标写入临时文件后改名，避免收集器读到写了一半的文件
+func writeMetricsFile(path string) error {
+    tmp := path + ".tmp"
+    file, err := os.Create(tmp)
+    if err != nil {
+        return err
+    }
+    if err := services.WriteMetrics(file); err != nil {
+        file.Close()
+        os.Remove(tmp)
+        return err
+    }
+    if err := file.Close(); err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    return os.Rename(tmp, path)
+}
+
+// homeDir 本地数据目录
+func homeDir() string {
+    if dir := os.Getenv("BILIBILI_UPLOADER_HOME"); dir != "" {
+        return dir
+    }
+    if dir, err := os.UserConfigDir(); err == nil {
+        return filepath.Join(dir, "bilibili-uploader")
+    }
+    return ".bilibili-uploader"
+}
+
+// credentialsPath 凭证文件路径
+func credentialsPath() string {
+    return filepath.Join(homeDir(), "credentials.json")
+}
+
+// loadCredentials 读取凭证，优先使用BILIBILI_ACCESS_TOKEN环境变量
+func loadCredentials(ctx context.Context) (*Credentials, error) {
+    if token := os.Getenv("BILIBILI_ACCESS_TOKEN"); token != "" {
+        return &Credentials{AccessToken: token}, nil
+    }
+
+    data, err := os.ReadFile(credentialsPath())
+    if os.IsNotExist(err) {
+        return nil, fmt.Errorf("尚未登录，请先执行 bilibili-uploader login")
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var creds Credentials
+    if err := json.Unmarshal(data, &creds); err != nil {
+        return nil, fmt.Errorf("凭证文件已损坏，请重新登录: %v", err)
+    }
+    if creds.Web == nil && !creds.ExpiresAt.IsZero() && time.Until(creds.ExpiresAt) < services.DefaultRefreshBefore {
+        if err := refreshCredentials(ctx, &creds); err != nil {
+            if time.Now().After(creds.ExpiresAt) {
+                return nil, fmt.Errorf("登录已过期，请重新执行 bilibili-uploader login: %v", err)
+            }
+            fmt.Fprintf(os.Stderr, "⚠️  刷新访问令牌失败，继续使用当前令牌: %v\n", err)
+        }
+    }
+    return &creds, nil
+}
+
+// refreshCredentials 使用refresh_token续期访问令牌并保存
+func refreshCredentials(ctx context.Context, creds *Credentials) error {
+    if creds.RefreshToken == "" {
+        return fmt.Errorf("没有refresh_token")
+    }
+
+    oauth := services.NewBilibiliOAuth(
+        config.GlobalConfig.BilibiliClientID,
+        config.GlobalConfig.BilibiliClientSecret,
+        config.GlobalConfig.BilibiliRedirectURI,
+    )
+    tokenResp, err := oauth.RefreshToken(ctx, creds.RefreshToken)
+    if err != nil {
+        return err
+    }
+
+    token := services.NewOAuthToken(creds.UID, tokenResp)
+    creds.AccessToken = token.AccessToken
+    creds.ExpiresAt = token.ExpiresAt
+    if token.RefreshToken != "" {
+        creds.RefreshToken = token.RefreshToken
+    }
+    return saveCredentials(creds)
+}
+
+// saveCredentials 保存凭证（仅当前用户可读）
+func saveCredentials(creds *Credentials) error {
+    if err := os.MkdirAll(homeDir(), 0700); err != nil {
+        return err
+    }
+    data, err := json.MarshalIndent(creds, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(credentialsPath(), data, 0600)
+}
+
+// processFile 转码单个文件，返回输出文件路径
+func processFile(ctx context.Context, inputPath, outDir string, options services.ProcessOptions) (string, error) {
+    if !services.CheckFFmpeg() {
+        return "", fmt.Errorf("未安装FFmpeg")
+    }
+    if err := os.MkdirAll(outDir, 0755); err != nil {
+        return "", err
+    }
+
+    processor := services.NewVideoProcessor(filepath.Dir(inputPath), outDir)
+    outputFile, err := processor.ProcessVideoContext(ctx, filepath.Base(inputPath), options)
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(outDir, outputFile), nil
+}
+
+// parseArgs 解析参数，允许位置参数和选项交错出现，返回位置参数
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+    var positional []string
+    for {
+        fs.Parse(args)
+        args = fs.Args()
+        if len(args) == 0 {
+            return positional
+        }
+        positional = append(positional, args[0])
+        args = args[1:]
+    }
+}
+
+// parseAuthCode 从粘贴的回调地址或授权码中取出code，回调地址中带state时校验state
+func parseAuthCode(input, state string) (string, error) {
+    if !strings.Contains(input, "code=") {
+        return input, nil
+    }
+
+    raw := input
+    if i := strings.Index(raw, "?"); i >= 0 {
+        raw = raw[i+1:]
+    }
+    query, err := url.ParseQuery(raw)
+    if err != nil {
+        return "", fmt.Errorf("解析回调地址失败: %v", err)
+    }
+    if got := query.Get("state"); got != "" && got != state {
+        return "", fmt.Errorf("state不匹配，请重新执行登录")
+    }
+    return query.Get("code"), nil
+}
+
+// splitTags 拆分逗号或空格分隔的标签
+func splitTags(tags string) []string {
+    return strings.FieldsFunc(tags, func(r rune) bool {
+        return r == ',' || r == '，' || r == ' '
+    })
+}
+
+// printJSON 以JSON格式输出
+func printJSON(v interface{}) error {
+    encoder := json.NewEncoder(os.Stdout)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(v)
+}
@@ -0,0 +1,262 @@
+// cmd/dev-oidc/main.go - 本地开发用的OIDC身份提供方
+//
+// 自动同意授权，签发RS256的ID Token，用于在没有真实身份提供方时测试OIDC登录：
+//
+//	go run ./cmd/dev-oidc --addr :9000
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=bilibili-uploader OIDC_CLIENT_SECRET=dev-secret go run .
+//
+// 授权地址带 login_hint 参数时以该值作为用户sub，便于模拟多个用户。
+package main
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "log"
+    "math/big"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v4"
+)
+
+// keyID 签名密钥的kid
+const keyID = "dev-oidc-1"
+
+// authCode 已签发、尚未使用的授权码
+type authCode struct {
+    clientID      string
+    redirectURI   string
+    nonce         string
+    codeChallenge string
+    subject       string
+    expiresAt     time.Time
+}
+
+// server 本地OIDC服务
+type server struct {
+    issuer       string
+    clientID     string
+    clientSecret string
+    email        string
+    name         string
+    defaultSub   string
+    key          *rsa.PrivateKey
+
+    mu    sync.Mutex
+    codes map[string]*authCode
+}
+
+func main() {
+    addr := flag.String("addr", ":9000", "监听地址")
+    issuer := flag.String("issuer", "http://localhost:9000", "issuer（需与OIDC_ISSUER一致）")
+    clientID := flag.String("client-id", "bilibili-uploader", "允许的client_id")
+    clientSecret := flag.String("client-secret", "dev-secret", "client_secret")
+    sub := flag.String("sub", "dev-user", "默认用户的sub")
+    email := flag.String("email", "dev@example.com", "用户邮箱")
+    name := flag.String("name", "dev", "用户名（preferred_username）")
+    flag.Parse()
+
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        log.Fatalf("生成签名密钥失败: %v", err)
+    }
+
+    s := &server{
+        issuer:       strings.TrimRight(*issuer, "/"),
+        clientID:     *clientID,
+        clientSecret: *clientSecret,
+        email:        *email,
+        name:         *name,
+        defaultSub:   *sub,
+        key:          key,
+        codes:        make(map[string]*authCode),
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+    mux.HandleFunc("/authorize", s.handleAuthorize)
+    mux.HandleFunc("/token", s.handleToken)
+    mux.HandleFunc("/jwks", s.handleJWKS)
+
+    log.Printf("🔑 本地OIDC服务: %s (client_id=%s)", s.issuer, s.clientID)
+    if err := http.ListenAndServe(*addr, mux); err != nil {
+        log.Fatalf("服务器启动失败: %v", err)
+    }
+}
+
+// handleDiscovery discovery文档
+func (s *server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "issuer":                                s.issuer,
+        "authorization_endpoint":                s.issuer + "/authorize",
+        "token_endpoint":                        s.issuer + "/token",
+        "jwks_uri":                              s.issuer + "/jwks",
+        "response_types_supported":              []string{"code"},
+        "subject_types_supported":               []string{"public"},
+        "id_token_signing_alg_values_supported": []string{"RS256"},
+        "code_challenge_methods_supported":      []string{"S256"},
+    })
+}
+
+// handleAuthorize 自动同意授权并带授权码跳转回客户端
+func (s *server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    if q.Get("client_id") != s.clientID {
+        http.Error(w, "unknown client_id", http.StatusBadRequest)
+        return
+    }
+    if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+        http.Error(w, "only response_type=code with S256 PKCE is supported", http.StatusBadRequest)
+        return
+    }
+    redirectURI, err := url.Parse(q.Get("redirect_uri"))
+    if err != nil || redirectURI.Scheme == "" {
+        http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+        return
+    }
+
+    subject := q.Get("login_hint")
+    if subject == "" {
+        subject = s.defaultSub
+    }
+
+    code := randomString()
+    s.mu.Lock()
+    s.codes[code] = &authCode{
+        clientID:      s.clientID,
+        redirectURI:   redirectURI.String(),
+        nonce:         q.Get("nonce"),
+        codeChallenge: q.Get("code_challenge"),
+        subject:       subject,
+        expiresAt:     time.Now().Add(time.Minute),
+    }
+    s.mu.Unlock()
+
+    params := redirectURI.Query()
+    params.Set("code", code)
+    params.Set("state", q.Get("state"))
+    redirectURI.RawQuery = params.Encode()
+    log.Printf("✅ 授权 sub=%s", subject)
+    http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// handleToken 校验客户端、redirect_uri和PKCE后签发ID Token
+func (s *server) handleToken(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        tokenError(w, "invalid_request", "表单解析失败")
+        return
+    }
+
+    clientID, clientSecret, ok := r.BasicAuth()
+    if !ok {
+        clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+    }
+    if clientID != s.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.clientSecret)) != 1 {
+        tokenError(w, "invalid_client", "client认证失败")
+        return
+    }
+    if r.PostForm.Get("grant_type") != "authorization_code" {
+        tokenError(w, "unsupported_grant_type", "只支持authorization_code")
+        return
+    }
+
+    // 授权码只能使用一次
+    s.mu.Lock()
+    code, ok := s.codes[r.PostForm.Get("code")]
+    delete(s.codes, r.PostForm.Get("code"))
+    s.mu.Unlock()
+    if !ok || time.Now().After(code.expiresAt) {
+        tokenError(w, "invalid_grant", "授权码无效或已过期")
+        return
+    }
+    if code.redirectURI != r.PostForm.Get("redirect_uri") {
+        tokenError(w, "invalid_grant", "redirect_uri不匹配")
+        return
+    }
+    sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+    if base64.RawURLEncoding.EncodeToString(sum[:]) != code.codeChallenge {
+        tokenError(w, "invalid_grant", "code_verifier校验失败")
+        return
+    }
+
+    now := time.Now()
+    claims := jwt.MapClaims{
+        "iss":                s.issuer,
+        "sub":                code.subject,
+        "aud":                code.clientID,
+        "iat":                now.Unix(),
+        "exp":                now.Add(10 * time.Minute).Unix(),
+        "nonce":              code.nonce,
+        "email":              s.email,
+        "name":               s.name,
+        "preferred_username": s.name,
+    }
+    if code.subject != s.defaultSub {
+        claims["preferred_username"] = code.subject
+        claims["name"] = code.subject
+    }
+    token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+    token.Header["kid"] = keyID
+    idToken, err := token.SignedString(s.key)
+    if err != nil {
+        tokenError(w, "server_error", err.Error())
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "access_token": randomString(),
+        "token_type":   "Bearer",
+        "expires_in":   600,
+        "id_token":     idToken,
+    })
+}
+
+// handleJWKS 签名公钥
+func (s *server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+    pub := s.key.PublicKey
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "keys": []map[string]string{{
+            "kid": keyID,
+            "kty": "RSA",
+            "alg": "RS256",
+            "use": "sig",
+            "n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+            "e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+        }},
+    })
+}
+
+// tokenError OAuth2格式的错误响应
+func tokenError(w http.ResponseWriter, code, description string) {
+    writeJSON(w, http.StatusBadRequest, map[string]string{
+        "error":             code,
+        "error_description": description,
+    })
+}
+
+// writeJSON 写入JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+// randomString 生成随机授权码
+func randomString() string {
+    b := make([]byte, 16)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
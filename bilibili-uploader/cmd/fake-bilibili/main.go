@@ -0,0 +1,53 @@
+// cmd/fake-bilibili/main.go - 独立运行的模拟B站接口
+//
+// 提供passport授权、nav、预上传、UPOS分片上传、封面、投稿和稿件状态接口，用于本地联调和集成测试：
+//
+//	go run ./cmd/fake-bilibili --addr 127.0.0.1:9080 --fail-parts 1 --part-delay 500ms
+//
+// 运行时可通过控制接口注入故障：
+//
+//	curl -X POST localhost:9080/_fake/faults -d '{"endpoint":"add","code":21540,"times":1}'
+//	curl -X POST localhost:9080/_fake/expire-tokens
+//	curl -X DELETE localhost:9080/_fake/faults
+package main
+
+import (
+    "bilibili-uploader/fakebili"
+    "flag"
+    "log"
+    "net/http"
+    "time"
+)
+
+func main() {
+    addr := flag.String("addr", "127.0.0.1:9080", "监听地址")
+    clientID := flag.String("client-id", "fake-client", "允许的client_id（需与BILIBILI_CLIENT_ID一致）")
+    clientSecret := flag.String("client-secret", "fake-secret", "client_secret")
+    uid := flag.Int64("uid", 10086, "授权用户的mid")
+    uname := flag.String("uname", "测试用户", "授权用户的昵称")
+    tokenTTL := flag.Duration("token-ttl", 30*24*time.Hour, "访问令牌有效期")
+    chunkSize := flag.Int64("chunk-size", 4*1024*1024, "分片大小（字节）")
+    reviewDelay := flag.Duration("review-delay", 10*time.Second, "投稿后处于审核中的时长")
+    partDelay := flag.Duration("part-delay", 0, "每个分片的额外延迟")
+    failParts := flag.Int("fail-parts", 0, "前N个分片返回503")
+    flag.Parse()
+
+    server := fakebili.New(fakebili.Options{
+        ClientID:     *clientID,
+        ClientSecret: *clientSecret,
+        UID:          *uid,
+        Username:     *uname,
+        TokenTTL:     *tokenTTL,
+        ChunkSize:    *chunkSize,
+        ReviewDelay:  *reviewDelay,
+    })
+    if *failParts > 0 {
+        server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Status: http.StatusServiceUnavailable, Times: *failParts})
+    }
+    if *partDelay > 0 {
+        server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Delay: *partDelay})
+    }
+
+    log.Printf("🧪 模拟B站接口: http://%s (client_id=%s, mid=%d)", *addr, *clientID, *uid)
+    log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -2,25 +2,93 @@
 package config
 
 import (
-    "log"
+    "log/slog"
     "os"
+    "strconv"
+    "strings"
+    "time"
     "github.com/joho/godotenv"
 )
 
 // Config 全局配置
 type Config struct {
     // 服务器配置
-    Port string
+    Port            string
+    ShutdownTimeout time.Duration // 收到SIGTERM后优雅退出的期限
+    
+    // 日志配置
+    LogFormat string // json或text
+    LogLevel  string // debug、info、warn、error
+    
+    // 链路追踪配置，OTLP地址等由OTEL_EXPORTER_OTLP_*环境变量直接配置
+    TraceExporter string // otlp、stdout、none
+    TraceProtocol string // OTLP协议：http/protobuf或grpc
+    ServiceName   string
     
     // B站OAuth配置
     BilibiliClientID     string
     BilibiliClientSecret string
     BilibiliRedirectURI  string
     
+    // B站App签名配置（可选，配置后令牌请求使用appkey签名）
+    BilibiliAppKey    string
+    BilibiliAppSecret string
+    
+    // B站接口地址（可指向本地的模拟服务）
+    BilibiliAPIURL      string
+    BilibiliMemberURL   string
+    BilibiliPassportURL string
+    BilibiliFake        bool // 在进程内启动模拟B站接口，并把接口地址指向它
+    
+    // 出站HTTP配置
+    HTTPTimeout time.Duration // 单个请求的总超时（含分片上传）
+    HTTPProxy   string        // 出站代理，为空时使用HTTP_PROXY/HTTPS_PROXY环境变量
+    UserAgent   string
+    
+    // 分片上传遇到网络错误或可重试的B站错误时的重试次数
+    UploadPartRetries int
+    
+    // 监控指标：配置后/metrics需要携带 Authorization: Bearer <METRICS_TOKEN>
+    MetricsToken string
+    
     // 文件存储配置
     UploadDir    string
     ProcessedDir string
     TempDir      string
+    DataDir      string
+    
+    // 监控目录配置（自动投稿）
+    WatchDirs        []string
+    WatchInterval    time.Duration
+    WatchSettleTime  time.Duration
+    WatchUserID      string // 监控目录任务所属的用户ID
+    WatchAccountID   string // 投稿使用的关联账号ID，为空时使用该用户最早关联的账号
+    
+    // 草稿有效期：已上传但未提交的文件在B站侧只保留有限时间
+    DraftTTL time.Duration
+    
+    // 用户默认的限流和配额（0表示不限制），工作区和账号的限制在QuotaFile中配置
+    RateLimitPerMinute  int
+    QuotaConcurrentJobs int
+    QuotaDailyUploads   int
+    QuotaDailyBytes     int64
+    QuotaFile           string // JSON格式的按用户/工作区/账号覆盖配置
+    
+    // OAuth令牌续期
+    TokenRefreshBefore time.Duration // 到期前多久主动刷新
+    TokenRenewInterval time.Duration // 后台检查间隔
+    
+    // 凭证加密密钥，格式 "1:base64密钥,2:base64密钥"
+    CredentialKeys            string
+    CredentialKeyVersion      int  // 当前加密使用的版本，0表示使用最大版本
+    AllowPlaintextCredentials bool // 未配置密钥时允许明文保存凭证（仅用于本地开发）
+    
+    // 本服务用户登录
+    AllowRegistration bool   // 是否允许自助注册密码账号
+    OIDCIssuer        string // 为空时不启用OIDC登录
+    OIDCClientID      string
+    OIDCClientSecret  string
+    OIDCRedirectURI   string
     
     // JWT密钥（用于生成自己的token）
     JWTSecret string
@@ -33,45 +101,115 @@ var GlobalConfig *Config
 func Load() {
     // 加载.env文件
     if err := godotenv.Load(); err != nil {
-        log.Println("未找到.env文件，使用环境变量")
+        slog.Debug("未找到.env文件，使用环境变量")
     }
     
     GlobalConfig = &Config{
         // 服务器配置
-        Port: getEnv("PORT", "8080"),
+        Port:            getEnv("PORT", "8080"),
+        ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+        
+        // 日志配置
+        LogFormat: getEnv("LOG_FORMAT", "text"),
+        LogLevel:  getEnv("LOG_LEVEL", "info"),
+        
+        // 链路追踪配置（沿用OpenTelemetry的标准环境变量名）
+        TraceExporter: getEnv("OTEL_TRACES_EXPORTER", "none"),
+        TraceProtocol: getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf"),
+        ServiceName:   getEnv("OTEL_SERVICE_NAME", "bilibili-uploader"),
         
         // B站OAuth配置
         BilibiliClientID:     getEnv("BILIBILI_CLIENT_ID", ""),
         BilibiliClientSecret: getEnv("BILIBILI_CLIENT_SECRET", ""),
         BilibiliRedirectURI:  getEnv("BILIBILI_REDIRECT_URI", "http://localhost:8080/api/auth/callback"),
         
+        // B站App签名配置
+        BilibiliAppKey:    getEnv("BILIBILI_APP_KEY", ""),
+        BilibiliAppSecret: getEnv("BILIBILI_APP_SECRET", ""),
+        
+        // B站接口地址
+        BilibiliAPIURL:      getEnv("BILIBILI_API_URL", "https://api.bilibili.com"),
+        BilibiliMemberURL:   getEnv("BILIBILI_MEMBER_URL", "https://member.bilibili.com"),
+        BilibiliPassportURL: getEnv("BILIBILI_PASSPORT_URL", "https://passport.bilibili.com"),
+        BilibiliFake:        getEnvBool("BILIBILI_FAKE", false),
+        
+        // 出站HTTP配置
+        HTTPTimeout: getEnvDuration("HTTP_TIMEOUT", 2*time.Minute),
+        HTTPProxy:   getEnv("OUTBOUND_PROXY", ""),
+        UserAgent:   getEnv("USER_AGENT", ""),
+        
+        // 分片重试
+        UploadPartRetries: getEnvInt("UPLOAD_PART_RETRIES", 2),
+        
+        // 监控指标
+        MetricsToken: getEnv("METRICS_TOKEN", ""),
+        
         // 文件存储配置  
         UploadDir:    getEnv("UPLOAD_DIR", "./uploads"),
         ProcessedDir: getEnv("PROCESSED_DIR", "./processed"),
         TempDir:      getEnv("TEMP_DIR", "./temp"),
+        DataDir:      getEnv("DATA_DIR", "./data"),
+        
+        // 监控目录配置
+        WatchDirs:        getEnvList("WATCH_DIRS"),
+        WatchInterval:    getEnvDuration("WATCH_INTERVAL", 5*time.Second),
+        WatchSettleTime:  getEnvDuration("WATCH_SETTLE_TIME", 30*time.Second),
+        WatchUserID:      getEnv("WATCH_USER_ID", ""),
+        WatchAccountID:   getEnv("WATCH_ACCOUNT_ID", ""),
+        
+        // 草稿有效期
+        DraftTTL: getEnvDuration("DRAFT_TTL", 24*time.Hour),
+        
+        // 限流和配额
+        RateLimitPerMinute:  getEnvInt("RATE_LIMIT_PER_MINUTE", 120),
+        QuotaConcurrentJobs: getEnvInt("QUOTA_CONCURRENT_JOBS", 2),
+        QuotaDailyUploads:   getEnvInt("QUOTA_DAILY_UPLOADS", 50),
+        QuotaDailyBytes:     getEnvInt64("QUOTA_DAILY_BYTES", 50<<30),
+        QuotaFile:           getEnv("QUOTA_FILE", ""),
+        
+        // OAuth令牌续期
+        TokenRefreshBefore: getEnvDuration("TOKEN_REFRESH_BEFORE", 30*time.Minute),
+        TokenRenewInterval: getEnvDuration("TOKEN_RENEW_INTERVAL", 5*time.Minute),
+        
+        // 凭证加密密钥
+        CredentialKeys:            getEnv("CREDENTIAL_KEYS", ""),
+        CredentialKeyVersion:      getEnvInt("CREDENTIAL_KEY_VERSION", 0),
+        AllowPlaintextCredentials: getEnvBool("ALLOW_PLAINTEXT_CREDENTIALS", false),
+        
+        // 本服务用户登录
+        AllowRegistration: getEnvBool("ALLOW_REGISTRATION", true),
+        OIDCIssuer:        getEnv("OIDC_ISSUER", ""),
+        OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+        OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+        OIDCRedirectURI:   getEnv("OIDC_REDIRECT_URI", "http://localhost:8080/api/users/oidc/callback"),
         
         // JWT密钥
         JWTSecret: getEnv("JWT_SECRET", "your-secret-key-change-this"),
     }
     
-    // 验证必要配置
-    validateConfig()
+    // 模拟B站接口不需要真实的OAuth应用
+    if GlobalConfig.BilibiliFake && GlobalConfig.BilibiliClientID == "" {
+        GlobalConfig.BilibiliClientID = "fake-client"
+        GlobalConfig.BilibiliClientSecret = "fake-secret"
+    }
 }
 
-// validateConfig 验证配置
-func validateConfig() {
+// Validate 检查必要配置并记录警告，在日志初始化之后调用
+func Validate() {
     if GlobalConfig.BilibiliClientID == "" {
-        log.Println("⚠️  警告: BILIBILI_CLIENT_ID 未设置")
-        log.Println("   请在.env文件中设置B站OAuth客户端ID")
-        log.Println("   申请地址: https://open.bilibili.com")
+        slog.Warn("BILIBILI_CLIENT_ID 未设置，请在.env文件中设置B站OAuth客户端ID", "apply_url", "https://open.bilibili.com")
     }
     
     if GlobalConfig.BilibiliClientSecret == "" {
-        log.Println("⚠️  警告: BILIBILI_CLIENT_SECRET 未设置")
+        slog.Warn("BILIBILI_CLIENT_SECRET 未设置")
+    }
+    
+    if GlobalConfig.CredentialKeys == "" && GlobalConfig.AllowPlaintextCredentials {
+        slog.Warn("CREDENTIAL_KEYS 未设置且已开启ALLOW_PLAINTEXT_CREDENTIALS，B站凭证将以明文保存", "hint", "bilibili-uploader rotate-keys --new-key")
     }
     
     if GlobalConfig.JWTSecret == "your-secret-key-change-this" {
-        log.Println("⚠️  警告: 使用默认JWT密钥，请修改JWT_SECRET")
+        slog.Warn("使用默认JWT密钥，请修改JWT_SECRET")
     }
 }
 
@@ -83,6 +221,78 @@ func getEnv(key, defaultValue string) string {
     return defaultValue
 }
 
+// getEnvList 获取逗号分隔的环境变量列表
+func getEnvList(key string) []string {
+    var list []string
+    for _, item := range strings.Split(os.Getenv(key), ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
+
+// getEnvDuration 获取时长类型的环境变量（如 30s、5m）
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil {
+        slog.Warn("环境变量格式错误，使用默认值", "key", key, "value", value, "default", defaultValue)
+        return defaultValue
+    }
+    return d
+}
+
+// getEnvInt 获取整数类型的环境变量
+func getEnvInt(key string, defaultValue int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        slog.Warn("环境变量格式错误，使用默认值", "key", key, "value", value, "default", defaultValue)
+        return defaultValue
+    }
+    return n
+}
+
+// getEnvInt64 获取int64类型的环境变量（如字节数）
+func getEnvInt64(key string, defaultValue int64) int64 {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    n, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+        slog.Warn("环境变量格式错误，使用默认值", "key", key, "value", value, "default", defaultValue)
+        return defaultValue
+    }
+    return n
+}
+
+// getEnvBool 获取布尔类型的环境变量（true/false/1/0）
+func getEnvBool(key string, defaultValue bool) bool {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        slog.Warn("环境变量格式错误，使用默认值", "key", key, "value", value, "default", defaultValue)
+        return defaultValue
+    }
+    return b
+}
+
+// IsOIDCConfigured 检查OIDC登录配置是否完整
+func IsOIDCConfigured() bool {
+    return GlobalConfig.OIDCIssuer != "" && GlobalConfig.OIDCClientID != ""
+}
+
 // IsBilibiliConfigured 检查B站配置是否完整
 func IsBilibiliConfigured() bool {
     return GlobalConfig.BilibiliClientID != "" && 
@@ -0,0 +1,473 @@
+package fakebili_test
+
+import (
+    "bilibili-uploader/fakebili"
+    "bilibili-uploader/services"
+    "bilibili-uploader/signing"
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "go.opentelemetry.io/otel"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    "go.opentelemetry.io/otel/sdk/trace/tracetest"
+    "go.opentelemetry.io/otel/trace/noop"
+)
+
+const testChunkSize = 1024
+
+// startServer 启动进程内的模拟服务器
+func startServer(t *testing.T) (*fakebili.Server, string) {
+    t.Helper()
+    server := fakebili.New(fakebili.Options{
+        ClientID:     "test-client",
+        ClientSecret: "test-secret",
+        ChunkSize:    testChunkSize,
+        ReviewDelay:  time.Hour,
+    })
+    baseURL, err := server.Start("127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("Start() error = %v", err)
+    }
+    t.Cleanup(func() { server.Close() })
+    return server, baseURL
+}
+
+// newUploader 指向模拟服务器的上传器
+func newUploader(baseURL string, auth services.Authenticator) *services.BilibiliUploader {
+    uploader := services.NewBilibiliUploaderWithAuth(auth)
+    uploader.BaseURL = baseURL
+    return uploader
+}
+
+// writeVideo 写入size字节的测试视频文件
+func writeVideo(t *testing.T, size int) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "test.mp4")
+    if err := os.WriteFile(path, []byte(strings.Repeat("v", size)), 0600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+var testParams = services.VideoUploadParams{
+    Title:     "测试视频",
+    Tags:      []string{"测试"},
+    Category:  21,
+    Copyright: 1,
+}
+
+func TestOAuthUploadAndSubmit(t *testing.T) {
+    server, baseURL := startServer(t)
+
+    oauth := services.NewBilibiliOAuth("test-client", "test-secret", "http://localhost/callback")
+    oauth.BaseURL = baseURL
+    oauth.PassportURL = baseURL
+
+    // 授权页自动同意，跳转回redirect_uri
+    verifier := "test-verifier-0123456789-0123456789-0123456789"
+    sum := sha256.Sum256([]byte(verifier))
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+    resp, err := client.Get(oauth.AuthURL("state-1", base64.RawURLEncoding.EncodeToString(sum[:])))
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    location, err := url.Parse(resp.Header.Get("Location"))
+    if err != nil || location.Query().Get("state") != "state-1" {
+        t.Fatalf("redirect = %q, want state-1", resp.Header.Get("Location"))
+    }
+
+    token, err := oauth.ExchangeCode(context.Background(), location.Query().Get("code"), verifier)
+    if err != nil || token.AccessToken == "" {
+        t.Fatalf("ExchangeCode() = %+v, %v", token, err)
+    }
+    user, err := oauth.GetUserInfo(context.Background(), token.AccessToken)
+    if err != nil || user.UID != 10086 {
+        t.Fatalf("GetUserInfo() = %+v, %v", user, err)
+    }
+
+    // 3个分片，最后一个不满
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: token.AccessToken})
+    bvid, err := uploader.UploadVideo(writeVideo(t, 2*testChunkSize+100), testParams)
+    if err != nil {
+        t.Fatalf("UploadVideo() error = %v", err)
+    }
+
+    archives := server.Archives()
+    if len(archives) != 1 || archives[0].BVID != bvid || archives[0].Tag != "测试" {
+        t.Fatalf("Archives() = %+v, want one archive %s", archives, bvid)
+    }
+    status, err := uploader.GetArchiveStatus(bvid)
+    if err != nil || status.State != fakebili.ArchiveStateReviewing {
+        t.Fatalf("GetArchiveStatus() = %+v, %v", status, err)
+    }
+}
+
+func TestExchangeCodeRejectsWrongVerifier(t *testing.T) {
+    _, baseURL := startServer(t)
+
+    oauth := services.NewBilibiliOAuth("test-client", "test-secret", "http://localhost/callback")
+    oauth.PassportURL = baseURL
+
+    sum := sha256.Sum256([]byte("right-verifier"))
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+    resp, err := client.Get(oauth.AuthURL("s", base64.RawURLEncoding.EncodeToString(sum[:])))
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    location, _ := url.Parse(resp.Header.Get("Location"))
+
+    token, err := oauth.ExchangeCode(context.Background(), location.Query().Get("code"), "wrong-verifier")
+    if err == nil && token.AccessToken != "" {
+        t.Fatalf("ExchangeCode() with wrong verifier issued a token")
+    }
+}
+
+func TestCookieUploadWithCover(t *testing.T) {
+    server, baseURL := startServer(t)
+
+    sessdata, biliJct, uid := server.IssueCookie()
+    cred := &services.WebCredential{SESSDATA: sessdata, BiliJct: biliJct, DedeUserID: uid}
+    if _, err := services.CheckWebCredential(context.Background(), baseURL, cred); err != nil {
+        t.Fatalf("CheckWebCredential() error = %v", err)
+    }
+
+    cover := filepath.Join(t.TempDir(), "cover.png")
+    png := []byte("\x89PNG\r\n\x1a\n0000IHDR")
+    if err := os.WriteFile(cover, png, 0600); err != nil {
+        t.Fatal(err)
+    }
+    params := testParams
+    params.Cover = cover
+
+    uploader := newUploader(baseURL, &services.CookieAuth{Credential: cred})
+    if _, err := uploader.UploadVideo(writeVideo(t, 100), params); err != nil {
+        t.Fatalf("UploadVideo() error = %v", err)
+    }
+    if archives := server.Archives(); len(archives) != 1 || !strings.HasPrefix(archives[0].Cover, "http://i0.hdslb.com/") {
+        t.Fatalf("Archives() = %+v, want uploaded cover", archives)
+    }
+}
+
+func TestCookieAuthSignsWbiRequests(t *testing.T) {
+    _, baseURL := startServer(t)
+
+    cred := &services.WebCredential{SESSDATA: "sess", BiliJct: "jct", DedeUserID: "1"}
+    fetch := signing.NavKeyFetcher(baseURL, nil)
+    auth := &services.CookieAuth{Credential: cred, Signer: signing.NewWbiSigner(fetch)}
+
+    req, _ := http.NewRequest("GET", baseURL+"/x/space/wbi/arc/search?mid=1&ps=30", nil)
+    if err := auth.Authenticate(req); err != nil {
+        t.Fatalf("Authenticate() error = %v", err)
+    }
+    query := req.URL.Query()
+    wts, err := strconv.ParseInt(query.Get("wts"), 10, 64)
+    if err != nil || query.Get("w_rid") == "" {
+        t.Fatalf("query = %q, want wts and w_rid", req.URL.RawQuery)
+    }
+    imgKey, subKey, err := fetch()
+    if err != nil {
+        t.Fatalf("NavKeyFetcher() error = %v", err)
+    }
+    want := signing.SignWbi(url.Values{"mid": {"1"}, "ps": {"30"}}, imgKey, subKey, time.Unix(wts, 0))
+    if req.URL.RawQuery != want {
+        t.Fatalf("query = %q, want %q", req.URL.RawQuery, want)
+    }
+
+    // 非WBI接口不签名
+    req, _ = http.NewRequest("GET", baseURL+"/x/web/archive/view?bvid=BV1", nil)
+    if err := auth.Authenticate(req); err != nil {
+        t.Fatalf("Authenticate() error = %v", err)
+    }
+    if req.URL.Query().Has("w_rid") {
+        t.Fatalf("query = %q, want unsigned", req.URL.RawQuery)
+    }
+}
+
+func TestExpiredTokenIsRefreshed(t *testing.T) {
+    server, baseURL := startServer(t)
+
+    oauth := services.NewBilibiliOAuth("test-client", "test-secret", "")
+    oauth.PassportURL = baseURL
+
+    accessToken, refreshToken := server.IssueToken()
+    store, err := services.NewTokenStore("", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    store.Save(&services.OAuthToken{
+        UID:          10086,
+        AccessToken:  accessToken,
+        RefreshToken: refreshToken,
+        ExpiresAt:    time.Now().Add(24 * time.Hour),
+    })
+    server.ExpireTokens()
+
+    auth := &services.RefreshingAuth{UID: 10086, Store: store, OAuth: oauth}
+    if _, err := newUploader(baseURL, auth).UploadVideo(writeVideo(t, 100), testParams); err != nil {
+        t.Fatalf("UploadVideo() with expired token error = %v", err)
+    }
+    if token, _ := store.Get(10086); token.AccessToken == accessToken {
+        t.Fatalf("access token was not refreshed")
+    }
+}
+
+func TestFaults(t *testing.T) {
+    tests := []struct {
+        name      string
+        fault     fakebili.Fault
+        wantErr   string
+        wantClass services.ErrorClass
+    }{
+        {
+            name:      "分片返回503",
+            fault:     fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Status: http.StatusServiceUnavailable, Skip: 1, Times: 1},
+            wantErr:   "上传分片2失败",
+            wantClass: services.ErrorClassRetryable,
+        },
+        {
+            name:      "预上传返回500",
+            fault:     fakebili.Fault{Endpoint: fakebili.EndpointPreupload, Status: http.StatusInternalServerError},
+            wantErr:   "预上传失败",
+            wantClass: services.ErrorClassRetryable,
+        },
+        {
+            name:      "投稿过于频繁",
+            fault:     fakebili.Fault{Endpoint: fakebili.EndpointAdd, Code: fakebili.CodeTooFrequent},
+            wantErr:   "code=21540",
+            wantClass: services.ErrorClassRetryable,
+        },
+        {
+            name:      "风控",
+            fault:     fakebili.Fault{Endpoint: fakebili.EndpointAdd, Code: fakebili.CodeRiskControl},
+            wantErr:   "code=-352",
+            wantClass: services.ErrorClassRetryable,
+        },
+        {
+            name:      "未登录",
+            fault:     fakebili.Fault{Endpoint: fakebili.EndpointPreupload, Status: http.StatusUnauthorized},
+            wantErr:   "status=401",
+            wantClass: services.ErrorClassReauth,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            server, baseURL := startServer(t)
+            accessToken, _ := server.IssueToken()
+            server.AddFault(tt.fault)
+
+            uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+            _, err := uploader.UploadVideo(writeVideo(t, 2*testChunkSize), testParams)
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Fatalf("UploadVideo() error = %v, want %q", err, tt.wantErr)
+            }
+            if biliErr, ok := services.AsBilibiliError(err); !ok || biliErr.Class() != tt.wantClass {
+                t.Fatalf("UploadVideo() error class = %+v, want %s", biliErr, tt.wantClass)
+            }
+            if len(server.Archives()) != 0 {
+                t.Fatalf("archive submitted despite fault")
+            }
+        })
+    }
+}
+
+func TestPartRetry(t *testing.T) {
+    server, baseURL := startServer(t)
+    accessToken, _ := server.IssueToken()
+    server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Status: http.StatusServiceUnavailable, Skip: 1, Times: 1})
+
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+    uploader.PartRetries = 1
+    if _, err := uploader.UploadVideo(writeVideo(t, 2*testChunkSize), testParams); err != nil {
+        t.Fatalf("UploadVideo() error = %v", err)
+    }
+    if len(server.Archives()) != 1 {
+        t.Fatalf("archives = %d, want 1", len(server.Archives()))
+    }
+
+    var metrics bytes.Buffer
+    if err := services.WriteMetrics(&metrics); err != nil {
+        t.Fatalf("WriteMetrics() error = %v", err)
+    }
+    for _, want := range []string{
+        `bilibili_uploader_bilibili_api_responses_total{code="http_503",endpoint="upos_part"}`,
+        "bilibili_uploader_upload_part_retries_total",
+        `bilibili_uploader_uploads_total{result="success"}`,
+    } {
+        if !strings.Contains(metrics.String(), want) {
+            t.Errorf("metrics missing %s", want)
+        }
+    }
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+    server, baseURL := startServer(t)
+    accessToken, _ := server.IssueToken()
+    video := writeVideo(t, 3*testChunkSize)
+
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+    uploader.Checkpoints = services.NewCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+
+    // 第2片失败：保存已完成1片的断点
+    server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Status: http.StatusServiceUnavailable, Skip: 1, Times: 1})
+    if _, err := uploader.UploadVideo(video, testParams); err == nil {
+        t.Fatalf("UploadVideo() succeeded despite part fault")
+    }
+    checkpoint, ok := uploader.Checkpoints.Get(video)
+    if !ok || checkpoint.Parts != 1 {
+        t.Fatalf("checkpoint = %+v, %v, want 1 part done", checkpoint, ok)
+    }
+
+    // 继续上传时不再预上传和初始化
+    server.ClearFaults()
+    server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointPreupload, Status: http.StatusInternalServerError})
+    server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposInit, Status: http.StatusInternalServerError})
+    if _, err := uploader.UploadVideo(video, testParams); err != nil {
+        t.Fatalf("resumed UploadVideo() error = %v", err)
+    }
+    if len(server.Archives()) != 1 {
+        t.Fatalf("archives = %d, want 1", len(server.Archives()))
+    }
+    if _, ok := uploader.Checkpoints.Get(video); ok {
+        t.Errorf("checkpoint kept after successful upload")
+    }
+}
+
+func TestCancelledUploadSavesCheckpoint(t *testing.T) {
+    server, baseURL := startServer(t)
+    accessToken, _ := server.IssueToken()
+    server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Delay: time.Second})
+    video := writeVideo(t, 2*testChunkSize)
+
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+    uploader.Checkpoints = services.NewCheckpointStore("")
+
+    ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    _, err := uploader.UploadVideoContext(ctx, video, testParams)
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("UploadVideoContext() error = %v, want deadline exceeded", err)
+    }
+    if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+        t.Errorf("cancelled upload took %v", elapsed)
+    }
+    if checkpoint, ok := uploader.Checkpoints.Get(video); !ok || checkpoint.Parts != 0 {
+        t.Errorf("checkpoint = %+v, %v, want saved with 0 parts", checkpoint, ok)
+    }
+}
+
+func TestUploadSpans(t *testing.T) {
+    recorder := tracetest.NewSpanRecorder()
+    otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+    t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+    server, baseURL := startServer(t)
+    accessToken, _ := server.IssueToken()
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+
+    ctx, parent := otel.Tracer("test").Start(context.Background(), "POST /api/upload")
+    if _, err := uploader.UploadVideoContext(ctx, writeVideo(t, 2*testChunkSize), testParams); err != nil {
+        t.Fatalf("UploadVideoContext() error = %v", err)
+    }
+    parent.End()
+
+    counts := map[string]int{}
+    for _, span := range recorder.Ended() {
+        if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+            t.Errorf("span %s not in request trace", span.Name())
+        }
+        name := span.Name()
+        if strings.HasPrefix(name, "HTTP ") {
+            name = strings.Join(strings.Fields(name)[:2], " ")
+        }
+        counts[name]++
+    }
+    want := map[string]int{
+        "bilibili.upload_video":  1,
+        "bilibili.upload_file":   1,
+        "bilibili.preupload":     1,
+        "bilibili.upos_init":     1,
+        "bilibili.upload_parts":  1,
+        "bilibili.upload_part":   2,
+        "bilibili.upos_complete": 1,
+        "bilibili.submit":        1,
+        "HTTP PUT":               2,
+    }
+    for name, n := range want {
+        if counts[name] != n {
+            t.Errorf("%s spans = %d, want %d (all: %v)", name, counts[name], n, counts)
+        }
+    }
+}
+
+func TestSlowPartDelaysUpload(t *testing.T) {
+    server, baseURL := startServer(t)
+    accessToken, _ := server.IssueToken()
+    server.AddFault(fakebili.Fault{Endpoint: fakebili.EndpointUposPart, Delay: 100 * time.Millisecond})
+
+    start := time.Now()
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+    if _, err := uploader.UploadVideo(writeVideo(t, 2*testChunkSize), testParams); err != nil {
+        t.Fatalf("UploadVideo() error = %v", err)
+    }
+    if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+        t.Fatalf("upload took %v, want at least 200ms for 2 slow parts", elapsed)
+    }
+}
+
+func TestSubmitValidation(t *testing.T) {
+    server, baseURL := startServer(t)
+    accessToken, _ := server.IssueToken()
+    uploader := newUploader(baseURL, &services.BearerAuth{AccessToken: accessToken})
+
+    info, err := uploader.UploadFile(writeVideo(t, 100))
+    if err != nil {
+        t.Fatalf("UploadFile() error = %v", err)
+    }
+
+    invalid := testParams
+    invalid.Category = 99999
+    if _, err := uploader.Submit(info.Filename, invalid); err == nil || !strings.Contains(err.Error(), "code=21012") {
+        t.Fatalf("Submit() with invalid tid error = %v", err)
+    }
+    if _, err := uploader.Submit("n-not-uploaded", testParams); err == nil || !strings.Contains(err.Error(), "code=21020") {
+        t.Fatalf("Submit() with unknown filename error = %v", err)
+    }
+    if _, err := uploader.Submit(info.Filename, testParams); err != nil {
+        t.Fatalf("Submit() error = %v", err)
+    }
+    _, err = uploader.Submit(info.Filename, testParams)
+    if err == nil || !strings.Contains(err.Error(), "code=21070") {
+        t.Fatalf("Submit() with duplicated title error = %v", err)
+    }
+    if biliErr, ok := services.AsBilibiliError(err); !ok || !biliErr.UserError() || biliErr.Endpoint != "add" {
+        t.Fatalf("Submit() with duplicated title error = %+v, want user error from add", biliErr)
+    }
+}
+
+func TestExchangeCodeReturnsBilibiliError(t *testing.T) {
+    _, baseURL := startServer(t)
+
+    oauth := services.NewBilibiliOAuth("test-client", "test-secret", "http://localhost/callback")
+    oauth.PassportURL = baseURL
+
+    _, err := oauth.ExchangeCode(context.Background(), "unknown-code", "")
+    biliErr, ok := services.AsBilibiliError(err)
+    if !ok || biliErr.Code != fakebili.CodeBadRequest || biliErr.Message == "" || biliErr.HTTPStatus != http.StatusBadRequest {
+        t.Fatalf("ExchangeCode() with unknown code error = %v, want BilibiliError with message", err)
+    }
+}
@@ -0,0 +1,207 @@
+// fakebili/faults.go - 故障注入
+package fakebili
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// 可注入故障的接口名称
+const (
+    EndpointAuthorize    = "authorize"
+    EndpointToken        = "token"
+    EndpointRefresh      = "refresh"
+    EndpointRevoke       = "revoke"
+    EndpointNav          = "nav"
+    EndpointPreupload    = "preupload"
+    EndpointUposInit     = "upos_init"
+    EndpointUposPart     = "upos_part"
+    EndpointUposComplete = "upos_complete"
+    EndpointCover        = "cover"
+    EndpointAdd          = "add"
+    EndpointArchive      = "archive"
+)
+
+// Fault 一条故障规则：匹配的请求先等待Delay，再按Status或Code返回错误
+// Status和Code都为0时只延迟，请求照常处理（用于模拟慢速分片）
+type Fault struct {
+    Endpoint string        `json:"endpoint"`         // 接口名称，为空时匹配所有接口
+    Delay    time.Duration `json:"delay,omitempty"`  // 处理前的等待时间（JSON中为纳秒）
+    Status   int           `json:"status,omitempty"` // HTTP状态码，如500、503
+    Code     int           `json:"code,omitempty"`   // 业务码，如-352、21540（HTTP 200）
+    Times    int           `json:"times,omitempty"`  // 生效次数，0表示一直生效
+    Skip     int           `json:"skip,omitempty"`   // 先放过的请求数，如第3个分片才失败
+}
+
+// AddFault 添加故障规则，按添加顺序匹配第一条
+func (s *Server) AddFault(fault Fault) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    copied := fault
+    s.faults = append(s.faults, &copied)
+}
+
+// ClearFaults 清除所有故障规则
+func (s *Server) ClearFaults() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.faults = nil
+}
+
+// applyFault 对匹配的请求应用故障，已写入响应时返回true
+func (s *Server) applyFault(w http.ResponseWriter, r *http.Request) bool {
+    endpoint := endpointOf(r)
+
+    s.mu.Lock()
+    var fault Fault
+    matched := false
+    for i, f := range s.faults {
+        if f.Endpoint != "" && f.Endpoint != endpoint {
+            continue
+        }
+        if f.Skip > 0 {
+            f.Skip--
+            continue
+        }
+        fault = *f
+        matched = true
+        if f.Times > 0 {
+            f.Times--
+            if f.Times == 0 {
+                s.faults = append(s.faults[:i], s.faults[i+1:]...)
+            }
+        }
+        break
+    }
+    s.mu.Unlock()
+
+    if !matched {
+        return false
+    }
+    if fault.Delay > 0 {
+        select {
+        case <-time.After(fault.Delay):
+        case <-r.Context().Done():
+            return true
+        }
+    }
+
+    switch {
+    case fault.Status != 0:
+        if strings.HasPrefix(endpoint, "upos_") {
+            writeJSON(w, fault.Status, map[string]interface{}{"OK": 0, "message": http.StatusText(fault.Status)})
+        } else {
+            w.WriteHeader(fault.Status)
+            w.Write([]byte(http.StatusText(fault.Status)))
+        }
+        return true
+    case fault.Code != 0:
+        if strings.HasPrefix(endpoint, "upos_") {
+            writeJSON(w, http.StatusOK, map[string]interface{}{"OK": 0, "message": codeMessages[fault.Code]})
+        } else {
+            writeCode(w, http.StatusOK, fault.Code)
+        }
+        return true
+    }
+    return false
+}
+
+// endpointOf 请求对应的接口名称
+func endpointOf(r *http.Request) string {
+    switch r.URL.Path {
+    case "/register/pc_oauth2.html":
+        return EndpointAuthorize
+    case "/api/oauth2/access_token":
+        return EndpointToken
+    case "/api/oauth2/refresh_token":
+        return EndpointRefresh
+    case "/x/passport-login/revoke":
+        return EndpointRevoke
+    case "/x/web-interface/nav":
+        return EndpointNav
+    case "/preupload":
+        return EndpointPreupload
+    case "/x/vu/web/cover/up":
+        return EndpointCover
+    case "/x/vu/web/add":
+        return EndpointAdd
+    case "/x/web/archive/view":
+        return EndpointArchive
+    }
+    if strings.HasPrefix(r.URL.Path, "/ugcboss/") {
+        switch {
+        case r.Method == http.MethodPut:
+            return EndpointUposPart
+        case r.URL.Query().Has("uploads"):
+            return EndpointUposInit
+        default:
+            return EndpointUposComplete
+        }
+    }
+    return ""
+}
+
+// handleFaults 控制接口：GET列出、POST添加（单条或数组）、DELETE清除故障规则
+func (s *Server) handleFaults(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        s.mu.Lock()
+        list := make([]Fault, 0, len(s.faults))
+        for _, f := range s.faults {
+            list = append(list, *f)
+        }
+        s.mu.Unlock()
+        writeJSON(w, http.StatusOK, list)
+
+    case http.MethodPost:
+        var raw json.RawMessage
+        if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        var faults []Fault
+        if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+            if err := json.Unmarshal(raw, &faults); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+        } else {
+            var fault Fault
+            if err := json.Unmarshal(raw, &fault); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            faults = append(faults, fault)
+        }
+        for _, fault := range faults {
+            s.AddFault(fault)
+        }
+        w.WriteHeader(http.StatusNoContent)
+
+    case http.MethodDelete:
+        s.ClearFaults()
+        w.WriteHeader(http.StatusNoContent)
+
+    default:
+        w.WriteHeader(http.StatusMethodNotAllowed)
+    }
+}
+
+// handleExpireTokens 控制接口：使已签发的令牌全部过期
+func (s *Server) handleExpireTokens(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+    s.ExpireTokens()
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// handleArchives 控制接口：列出已提交的稿件
+func (s *Server) handleArchives(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, s.Archives())
+}
@@ -0,0 +1,839 @@
+// fakebili/server.go - 本地模拟的B站接口，用于集成测试
+//
+// 实现passport授权和令牌接口、nav用户信息、预上传、UPOS分片上传、封面上传、
+// 投稿和稿件状态查询，响应体与B站接口一致，可通过Faults注入慢速、5xx、令牌过期和业务错误。
+// 所有域名（api、member、passport、UPOS）由同一个服务提供，把各BaseURL指向它即可。
+package fakebili
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 模拟服务器返回的业务码
+const (
+    CodeOK              = 0
+    CodeBadRequest      = -400  // 请求错误
+    CodeNotLoggedIn     = -101  // 账号未登录
+    CodeCSRFFailed      = -111  // csrf校验失败
+    CodeNotFound        = -404  // 啥都木有
+    CodeRiskControl     = -352  // 风控校验失败
+    CodeTooFrequent     = 21540 // 投稿过于频繁
+    CodeTitleDuplicated = 21070 // 标题重复
+    CodeTidInvalid      = 21012 // 分区不存在
+    CodeVideoNotFound   = 21020 // 视频文件不存在或未上传完成
+)
+
+// codeMessages 业务码对应的提示
+var codeMessages = map[int]string{
+    CodeBadRequest:      "请求错误",
+    CodeNotLoggedIn:     "账号未登录",
+    CodeCSRFFailed:      "csrf 校验失败",
+    CodeNotFound:        "啥都木有",
+    CodeRiskControl:     "风控校验失败",
+    CodeTooFrequent:     "投稿过于频繁，请稍后再试",
+    CodeTitleDuplicated: "标题重复，请修改后再提交",
+    CodeTidInvalid:      "分区不存在",
+    CodeVideoNotFound:   "视频文件不存在或未上传完成",
+}
+
+// validTids 允许投稿的分区
+var validTids = map[int]bool{
+    17: true, 21: true, 27: true, 95: true, 122: true, 138: true, 171: true, 201: true, 207: true, 208: true,
+}
+
+// 稿件状态
+const (
+    ArchiveStateOpen      = 0   // 开放浏览
+    ArchiveStateReviewing = -30 // 审核中
+)
+
+// Options 模拟服务器配置
+type Options struct {
+    ClientID     string        // 允许的client_id，为空时不校验
+    ClientSecret string        // client_secret，为空时不校验
+    UID          int64         // 授权用户的mid
+    Username     string        // 授权用户的昵称
+    TokenTTL     time.Duration // 访问令牌有效期
+    ChunkSize    int64         // 预上传返回的分片大小
+    ReviewDelay  time.Duration // 投稿后处于审核中的时长
+}
+
+// credential 已签发的访问令牌或SESSDATA
+type credential struct {
+    uid       int64
+    username  string
+    csrf      string // Cookie登录时的bili_jct
+    expiresAt time.Time
+}
+
+// authCode 已签发、尚未使用的授权码
+type authCode struct {
+    redirectURI   string
+    codeChallenge string
+    expiresAt     time.Time
+}
+
+// upload UPOS上传状态
+type upload struct {
+    auth     string
+    name     string
+    size     int64
+    uploadID string
+    parts    map[int]int64 // partNumber -> 字节数
+    complete bool
+}
+
+// Archive 已提交的稿件
+type Archive struct {
+    AID         int64     `json:"aid"`
+    BVID        string    `json:"bvid"`
+    UID         int64     `json:"mid"`
+    Title       string    `json:"title"`
+    Desc        string    `json:"desc"`
+    Tag         string    `json:"tag"`
+    Tid         int       `json:"tid"`
+    Copyright   int       `json:"copyright"`
+    Source      string    `json:"source"`
+    Cover       string    `json:"cover"`
+    Filename    string    `json:"filename"`
+    SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// Server 模拟的B站接口
+type Server struct {
+    opts Options
+    mux  *http.ServeMux
+
+    mu          sync.Mutex
+    faults      []*Fault
+    codes       map[string]*authCode
+    credentials map[string]*credential // 访问令牌或SESSDATA
+    refresh     map[string]int64       // refresh_token -> uid
+    uploads     map[string]*upload     // UPOS路径（/ugcboss/xxx.mp4）
+    archives    map[string]*Archive    // bvid
+    nextAID     int64
+
+    listener net.Listener
+    httpSrv  *http.Server
+}
+
+// New 创建模拟服务器，未设置的选项使用默认值
+func New(opts Options) *Server {
+    if opts.UID == 0 {
+        opts.UID = 10086
+    }
+    if opts.Username == "" {
+        opts.Username = "测试用户"
+    }
+    if opts.TokenTTL <= 0 {
+        opts.TokenTTL = 30 * 24 * time.Hour
+    }
+    if opts.ChunkSize <= 0 {
+        opts.ChunkSize = 4 * 1024 * 1024
+    }
+
+    s := &Server{
+        opts:        opts,
+        mux:         http.NewServeMux(),
+        codes:       make(map[string]*authCode),
+        credentials: make(map[string]*credential),
+        refresh:     make(map[string]int64),
+        uploads:     make(map[string]*upload),
+        archives:    make(map[string]*Archive),
+        nextAID:     1000000,
+    }
+
+    // passport
+    s.mux.HandleFunc("/register/pc_oauth2.html", s.handleAuthorize)
+    s.mux.HandleFunc("/api/oauth2/access_token", s.handleAccessToken)
+    s.mux.HandleFunc("/api/oauth2/refresh_token", s.handleRefreshToken)
+    s.mux.HandleFunc("/x/passport-login/revoke", s.handleRevoke)
+    // api
+    s.mux.HandleFunc("/x/web-interface/nav", s.handleNav)
+    // member
+    s.mux.HandleFunc("/preupload", s.handlePreupload)
+    s.mux.HandleFunc("/x/vu/web/cover/up", s.handleCover)
+    s.mux.HandleFunc("/x/vu/web/add", s.handleAdd)
+    s.mux.HandleFunc("/x/web/archive/view", s.handleArchiveView)
+    // UPOS
+    s.mux.HandleFunc("/ugcboss/", s.handleUpos)
+    // 测试控制接口
+    s.mux.HandleFunc("/_fake/faults", s.handleFaults)
+    s.mux.HandleFunc("/_fake/expire-tokens", s.handleExpireTokens)
+    s.mux.HandleFunc("/_fake/archives", s.handleArchives)
+    return s
+}
+
+// Start 在进程内监听addr（如 127.0.0.1:0），返回服务地址
+func (s *Server) Start(addr string) (string, error) {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return "", fmt.Errorf("监听%s失败: %v", addr, err)
+    }
+    s.listener = listener
+    s.httpSrv = &http.Server{Handler: s}
+    go s.httpSrv.Serve(listener)
+    return s.URL(), nil
+}
+
+// URL 服务地址（Start之后可用）
+func (s *Server) URL() string {
+    if s.listener == nil {
+        return ""
+    }
+    return "http://" + s.listener.Addr().String()
+}
+
+// Close 停止监听
+func (s *Server) Close() error {
+    if s.httpSrv == nil {
+        return nil
+    }
+    return s.httpSrv.Close()
+}
+
+// ServeHTTP 先应用匹配的故障，再交给对应接口处理
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if !strings.HasPrefix(r.URL.Path, "/_fake/") && s.applyFault(w, r) {
+        return
+    }
+    s.mux.ServeHTTP(w, r)
+}
+
+// IssueToken 直接签发访问令牌和refresh_token，跳过浏览器授权
+func (s *Server) IssueToken() (accessToken, refreshToken string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.issueTokenLocked(s.opts.UID)
+}
+
+// IssueCookie 直接签发网页登录Cookie（SESSDATA、bili_jct、DedeUserID）
+func (s *Server) IssueCookie() (sessdata, biliJct, dedeUserID string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    sessdata = randomHex(16)
+    biliJct = randomHex(16)
+    s.credentials[sessdata] = &credential{
+        uid:       s.opts.UID,
+        username:  s.opts.Username,
+        csrf:      biliJct,
+        expiresAt: time.Now().Add(s.opts.TokenTTL),
+    }
+    return sessdata, biliJct, strconv.FormatInt(s.opts.UID, 10)
+}
+
+// ExpireTokens 使已签发的访问令牌和Cookie全部过期（refresh_token仍可使用）
+func (s *Server) ExpireTokens() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    expired := time.Now().Add(-time.Second)
+    for _, cred := range s.credentials {
+        cred.expiresAt = expired
+    }
+}
+
+// Archives 按提交顺序列出已提交的稿件
+func (s *Server) Archives() []Archive {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]Archive, 0, len(s.archives))
+    for _, archive := range s.archives {
+        list = append(list, *archive)
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].AID < list[j].AID
+    })
+    return list
+}
+
+// issueTokenLocked 签发令牌（调用方需持有锁）
+func (s *Server) issueTokenLocked(uid int64) (string, string) {
+    accessToken := randomHex(16)
+    refreshToken := randomHex(16)
+    s.credentials[accessToken] = &credential{
+        uid:       uid,
+        username:  s.opts.Username,
+        expiresAt: time.Now().Add(s.opts.TokenTTL),
+    }
+    s.refresh[refreshToken] = uid
+    return accessToken, refreshToken
+}
+
+// tokenBody 令牌接口的响应
+func (s *Server) tokenBody(accessToken, refreshToken string) map[string]interface{} {
+    return map[string]interface{}{
+        "access_token":  accessToken,
+        "refresh_token": refreshToken,
+        "expires_in":    int(s.opts.TokenTTL / time.Second),
+        "token_type":    "Bearer",
+    }
+}
+
+// handleAuthorize 授权页面：自动同意并带授权码跳转回redirect_uri
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    if s.opts.ClientID != "" && query.Get("client_id") != s.opts.ClientID {
+        http.Error(w, "unknown client_id", http.StatusBadRequest)
+        return
+    }
+    redirect, err := url.Parse(query.Get("redirect_uri"))
+    if err != nil || redirect.Scheme == "" {
+        http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+        return
+    }
+    if method := query.Get("code_challenge_method"); method != "" && method != "S256" {
+        http.Error(w, "unsupported code_challenge_method", http.StatusBadRequest)
+        return
+    }
+
+    code := randomHex(16)
+    s.mu.Lock()
+    s.codes[code] = &authCode{
+        redirectURI:   redirect.String(),
+        codeChallenge: query.Get("code_challenge"),
+        expiresAt:     time.Now().Add(5 * time.Minute),
+    }
+    s.mu.Unlock()
+
+    params := redirect.Query()
+    params.Set("code", code)
+    params.Set("state", query.Get("state"))
+    redirect.RawQuery = params.Encode()
+    http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// handleAccessToken 用授权码换取令牌，校验client、redirect_uri和PKCE
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        writeCode(w, http.StatusMethodNotAllowed, CodeBadRequest)
+        return
+    }
+    if !s.checkClient(w, r) {
+        return
+    }
+    if r.PostForm.Get("grant_type") != "authorization_code" {
+        writeCode(w, http.StatusBadRequest, CodeBadRequest)
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    code, ok := s.codes[r.PostForm.Get("code")]
+    delete(s.codes, r.PostForm.Get("code"))
+    if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": CodeBadRequest, "message": "授权码无效或已过期"})
+        return
+    }
+    if code.codeChallenge != "" {
+        sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+        if base64.RawURLEncoding.EncodeToString(sum[:]) != code.codeChallenge {
+            writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": CodeBadRequest, "message": "code_verifier校验失败"})
+            return
+        }
+    }
+
+    accessToken, refreshToken := s.issueTokenLocked(s.opts.UID)
+    writeJSON(w, http.StatusOK, s.tokenBody(accessToken, refreshToken))
+}
+
+// handleRefreshToken 用refresh_token换取新令牌，旧refresh_token随之失效
+func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        writeCode(w, http.StatusMethodNotAllowed, CodeBadRequest)
+        return
+    }
+    if !s.checkClient(w, r) {
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    uid, ok := s.refresh[r.PostForm.Get("refresh_token")]
+    if !ok {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": CodeNotLoggedIn, "message": "refresh_token无效"})
+        return
+    }
+    delete(s.refresh, r.PostForm.Get("refresh_token"))
+
+    accessToken, refreshToken := s.issueTokenLocked(uid)
+    writeJSON(w, http.StatusOK, s.tokenBody(accessToken, refreshToken))
+}
+
+// handleRevoke 注销访问令牌
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+    token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if token == "" {
+        r.ParseForm()
+        token = r.Form.Get("access_key")
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, ok := s.credentials[token]; !ok {
+        writeCode(w, http.StatusOK, CodeNotLoggedIn)
+        return
+    }
+    delete(s.credentials, token)
+    writeData(w, nil)
+}
+
+// handleNav 当前登录用户信息
+func (s *Server) handleNav(w http.ResponseWriter, r *http.Request) {
+    cred, ok := s.authenticate(r)
+    if !ok {
+        writeJSON(w, http.StatusOK, map[string]interface{}{
+            "code":    CodeNotLoggedIn,
+            "message": codeMessages[CodeNotLoggedIn],
+            "ttl":     1,
+            "data":    map[string]interface{}{"isLogin": false, "wbi_img": navWbiImg},
+        })
+        return
+    }
+    writeData(w, map[string]interface{}{
+        "isLogin":         true,
+        "mid":             cred.uid,
+        "uname":           cred.username,
+        "face":            "https://i0.hdslb.com/bfs/face/member/noface.jpg",
+        "email_verified":  1,
+        "mobile_verified": 1,
+        "vipStatus":       0,
+        "wbi_img":         navWbiImg,
+    })
+}
+
+// navWbiImg nav接口返回的WBI密钥图片地址，未登录时也会返回
+var navWbiImg = map[string]string{
+    "img_url": "https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+    "sub_url": "https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png",
+}
+
+// handlePreupload 预上传：分配UPOS路径和上传凭证
+func (s *Server) handlePreupload(w http.ResponseWriter, r *http.Request) {
+    if _, ok := s.requireLogin(w, r); !ok {
+        return
+    }
+    query := r.URL.Query()
+    size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+    if err != nil || size <= 0 || query.Get("name") == "" {
+        writeJSON(w, http.StatusOK, map[string]interface{}{"OK": 0, "message": "参数错误"})
+        return
+    }
+
+    name := "n" + strconv.FormatInt(time.Now().UnixNano()%1e12, 10) + randomHex(4)
+    path := "/ugcboss/" + name + ".mp4"
+    auth := "ak=1494471752&cdn=%2F%2Fupos-cs-upcdnbda2.bilivideo.com&os=upos&sign=" + randomHex(16)
+
+    s.mu.Lock()
+    s.uploads[path] = &upload{
+        auth:  auth,
+        name:  query.Get("name"),
+        size:  size,
+        parts: make(map[int]int64),
+    }
+    s.mu.Unlock()
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "OK":          1,
+        "auth":        auth,
+        "biz_id":      time.Now().UnixNano() % 1e9,
+        "chunk_size":  s.opts.ChunkSize,
+        "chunk_retry": 10,
+        "threads":     3,
+        "timeout":     600,
+        "endpoint":    "http://" + r.Host,
+        "endpoints":   []string{"//upos-cs-upcdnbda2.bilivideo.com", "//upos-cs-upcdnqn.bilivideo.com"},
+        "upos_uri":    "upos:/" + path,
+    })
+}
+
+// handleUpos UPOS分片上传：POST ?uploads初始化，PUT上传分片，POST ?uploadId合并
+func (s *Server) handleUpos(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    up, ok := s.uploads[r.URL.Path]
+    s.mu.Unlock()
+    if !ok {
+        writeJSON(w, http.StatusNotFound, map[string]interface{}{"OK": 0, "message": "no such upload"})
+        return
+    }
+    if r.Header.Get("X-Upos-Auth") != up.auth {
+        writeJSON(w, http.StatusForbidden, map[string]interface{}{"OK": 0, "message": "auth failed"})
+        return
+    }
+
+    query := r.URL.Query()
+    switch {
+    case r.Method == http.MethodPost && query.Has("uploads"):
+        s.mu.Lock()
+        up.uploadID = randomHex(16)
+        uploadID := up.uploadID
+        s.mu.Unlock()
+        writeJSON(w, http.StatusOK, map[string]interface{}{
+            "OK":        1,
+            "bucket":    "ugcboss",
+            "key":       strings.TrimPrefix(r.URL.Path, "/ugcboss"),
+            "upload_id": uploadID,
+        })
+
+    case r.Method == http.MethodPut:
+        s.uploadPart(w, r, up)
+
+    case r.Method == http.MethodPost && query.Get("uploadId") != "":
+        s.completeUpload(w, r, up)
+
+    default:
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"OK": 0, "message": "bad request"})
+    }
+}
+
+// uploadPart 接收一个分片，校验upload_id和大小
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, up *upload) {
+    query := r.URL.Query()
+    partNumber, err := strconv.Atoi(query.Get("partNumber"))
+    data, readErr := io.ReadAll(r.Body)
+    if err != nil || partNumber < 1 || readErr != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"OK": 0, "message": "bad part"})
+        return
+    }
+    if size, err := strconv.ParseInt(query.Get("size"), 10, 64); err == nil && size != int64(len(data)) {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"OK": 0, "message": "size mismatch"})
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if up.uploadID == "" || query.Get("uploadId") != up.uploadID {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"OK": 0, "message": "no such uploadId"})
+        return
+    }
+    up.parts[partNumber] = int64(len(data))
+    w.WriteHeader(http.StatusOK)
+    io.WriteString(w, "MULTIPART_PUT_SUCCESS")
+}
+
+// completeUpload 合并分片，所有分片齐全且总大小一致时才成功
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, up *upload) {
+    var body struct {
+        Parts []struct {
+            PartNumber int    `json:"partNumber"`
+            ETag       string `json:"eTag"`
+        } `json:"parts"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"OK": 0, "message": "bad body"})
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if r.URL.Query().Get("uploadId") != up.uploadID {
+        writeJSON(w, http.StatusBadRequest, map[string]interface{}{"OK": 0, "message": "no such uploadId"})
+        return
+    }
+    var total int64
+    for _, part := range body.Parts {
+        size, ok := up.parts[part.PartNumber]
+        if !ok {
+            writeJSON(w, http.StatusOK, map[string]interface{}{"OK": 0, "message": fmt.Sprintf("part %d missing", part.PartNumber)})
+            return
+        }
+        total += size
+    }
+    if total != up.size {
+        writeJSON(w, http.StatusOK, map[string]interface{}{"OK": 0, "message": fmt.Sprintf("size mismatch: %d/%d", total, up.size)})
+        return
+    }
+    up.complete = true
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "OK":       1,
+        "bucket":   "ugcboss",
+        "key":      strings.TrimPrefix(r.URL.Path, "/ugcboss"),
+        "location": "upos:/" + r.URL.Path,
+    })
+}
+
+// handleCover 上传封面（data URI），返回图片地址
+func (s *Server) handleCover(w http.ResponseWriter, r *http.Request) {
+    cred, ok := s.requireLogin(w, r)
+    if !ok {
+        return
+    }
+    r.ParseForm()
+    if !s.checkCSRF(w, r, cred) {
+        return
+    }
+
+    cover := r.PostForm.Get("cover")
+    _, encoded, found := strings.Cut(cover, ";base64,")
+    data, err := base64.StdEncoding.DecodeString(encoded)
+    if !strings.HasPrefix(cover, "data:image/") || !found || err != nil || len(data) == 0 {
+        writeCode(w, http.StatusOK, CodeBadRequest)
+        return
+    }
+    sum := sha256.Sum256(data)
+    writeData(w, map[string]interface{}{
+        "url": "http://i0.hdslb.com/bfs/archive/" + hex.EncodeToString(sum[:20]) + ".jpg",
+    })
+}
+
+// handleAdd 提交稿件：校验分区、标题和视频文件，返回aid和bvid
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
+    cred, ok := s.requireLogin(w, r)
+    if !ok {
+        return
+    }
+    r.ParseForm()
+    if !s.checkCSRF(w, r, cred) {
+        return
+    }
+
+    var body struct {
+        Copyright int    `json:"copyright"`
+        Source    string `json:"source"`
+        Title     string `json:"title"`
+        Tid       int    `json:"tid"`
+        Tag       string `json:"tag"`
+        Desc      string `json:"desc"`
+        Cover     string `json:"cover"`
+        Videos    []struct {
+            Filename string `json:"filename"`
+            Title    string `json:"title"`
+        } `json:"videos"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Title) == "" || len(body.Videos) == 0 {
+        writeCode(w, http.StatusOK, CodeBadRequest)
+        return
+    }
+    if body.Copyright == 2 && strings.TrimSpace(body.Source) == "" {
+        writeJSON(w, http.StatusOK, map[string]interface{}{"code": CodeBadRequest, "message": "转载稿件需要填写来源"})
+        return
+    }
+    if !validTids[body.Tid] {
+        writeCode(w, http.StatusOK, CodeTidInvalid)
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for _, video := range body.Videos {
+        up, ok := s.uploads["/ugcboss/"+video.Filename+".mp4"]
+        if !ok || !up.complete {
+            writeCode(w, http.StatusOK, CodeVideoNotFound)
+            return
+        }
+    }
+    for _, archive := range s.archives {
+        if archive.UID == cred.uid && archive.Title == body.Title {
+            writeCode(w, http.StatusOK, CodeTitleDuplicated)
+            return
+        }
+    }
+
+    s.nextAID++
+    archive := &Archive{
+        AID:         s.nextAID,
+        BVID:        bvid(s.nextAID),
+        UID:         cred.uid,
+        Title:       body.Title,
+        Desc:        body.Desc,
+        Tag:         body.Tag,
+        Tid:         body.Tid,
+        Copyright:   body.Copyright,
+        Source:      body.Source,
+        Cover:       body.Cover,
+        Filename:    body.Videos[0].Filename,
+        SubmittedAt: time.Now(),
+    }
+    s.archives[archive.BVID] = archive
+
+    writeData(w, map[string]interface{}{
+        "aid":  archive.AID,
+        "bvid": archive.BVID,
+    })
+}
+
+// handleArchiveView 稿件状态：提交后ReviewDelay内为审核中，之后开放浏览
+func (s *Server) handleArchiveView(w http.ResponseWriter, r *http.Request) {
+    cred, ok := s.requireLogin(w, r)
+    if !ok {
+        return
+    }
+
+    s.mu.Lock()
+    archive, ok := s.archives[r.URL.Query().Get("bvid")]
+    var copied Archive
+    if ok {
+        copied = *archive
+    }
+    s.mu.Unlock()
+    if !ok || copied.UID != cred.uid {
+        writeCode(w, http.StatusOK, CodeNotFound)
+        return
+    }
+
+    state, stateDesc := ArchiveStateOpen, "开放浏览"
+    if time.Since(copied.SubmittedAt) < s.opts.ReviewDelay {
+        state, stateDesc = ArchiveStateReviewing, "审核中"
+    }
+    writeData(w, map[string]interface{}{
+        "archive": map[string]interface{}{
+            "aid":           copied.AID,
+            "bvid":          copied.BVID,
+            "title":         copied.Title,
+            "desc":          copied.Desc,
+            "tag":           copied.Tag,
+            "tid":           copied.Tid,
+            "cover":         copied.Cover,
+            "copyright":     copied.Copyright,
+            "state":         state,
+            "state_desc":    stateDesc,
+            "reject_reason": "",
+            "ptime":         copied.SubmittedAt.Unix(),
+        },
+        "videos": []map[string]interface{}{
+            {"filename": copied.Filename, "title": copied.Title, "xcode_state": 6},
+        },
+    })
+}
+
+// checkClient 校验令牌接口的client_id和client_secret
+func (s *Server) checkClient(w http.ResponseWriter, r *http.Request) bool {
+    if err := r.ParseForm(); err != nil {
+        writeCode(w, http.StatusBadRequest, CodeBadRequest)
+        return false
+    }
+    if (s.opts.ClientID != "" && r.PostForm.Get("client_id") != s.opts.ClientID) ||
+        (s.opts.ClientSecret != "" && r.PostForm.Get("client_secret") != s.opts.ClientSecret) {
+        writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"code": CodeBadRequest, "message": "client认证失败"})
+        return false
+    }
+    return true
+}
+
+// authenticate 按Bearer令牌、access_key参数或SESSDATA Cookie识别用户
+func (s *Server) authenticate(r *http.Request) (*credential, bool) {
+    token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if token == "" {
+        token = r.URL.Query().Get("access_key")
+    }
+    if token == "" {
+        if cookie, err := r.Cookie("SESSDATA"); err == nil {
+            token, _ = url.QueryUnescape(cookie.Value)
+        }
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    cred, ok := s.credentials[token]
+    if !ok || time.Now().After(cred.expiresAt) {
+        return nil, false
+    }
+    copied := *cred
+    return &copied, true
+}
+
+// requireLogin member接口的登录校验，令牌无效或过期时返回401
+func (s *Server) requireLogin(w http.ResponseWriter, r *http.Request) (*credential, bool) {
+    cred, ok := s.authenticate(r)
+    if !ok {
+        writeCode(w, http.StatusUnauthorized, CodeNotLoggedIn)
+        return nil, false
+    }
+    return cred, true
+}
+
+// checkCSRF Cookie登录的写请求需要携带与bili_jct一致的csrf
+func (s *Server) checkCSRF(w http.ResponseWriter, r *http.Request, cred *credential) bool {
+    if cred.csrf == "" {
+        return true
+    }
+    csrf := r.URL.Query().Get("csrf")
+    if csrf == "" {
+        csrf = r.PostForm.Get("csrf")
+    }
+    if csrf != cred.csrf {
+        writeCode(w, http.StatusOK, CodeCSRFFailed)
+        return false
+    }
+    return true
+}
+
+// writeJSON 写入JSON响应
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+    w.Header().Set("Content-Type", "application/json; charset=utf-8")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(body)
+}
+
+// writeData 写入成功响应 {"code":0,"message":"0","ttl":1,"data":...}
+func writeData(w http.ResponseWriter, data interface{}) {
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "code":    CodeOK,
+        "message": "0",
+        "ttl":     1,
+        "data":    data,
+    })
+}
+
+// writeCode 写入业务错误响应
+func writeCode(w http.ResponseWriter, status, code int) {
+    message, ok := codeMessages[code]
+    if !ok {
+        message = strconv.Itoa(code)
+    }
+    writeJSON(w, status, map[string]interface{}{
+        "code":    code,
+        "message": message,
+        "ttl":     1,
+    })
+}
+
+// bvid 由aid生成BV号（B站的av/bv互转算法）
+func bvid(aid int64) string {
+    const (
+        table  = "FcwAPNKTMug3GV5Lj7EJnHpWsx4tb8haYeviqBz6rkCy12mUSDQX9RdoZf"
+        xorKey = 23442827791579
+        maxAID = 1 << 51
+    )
+    result := []byte("BV1000000000")
+    tmp := (maxAID | aid) ^ xorKey
+    for i := len(result) - 1; tmp > 0 && i >= 3; i-- {
+        result[i] = table[tmp%int64(len(table))]
+        tmp /= int64(len(table))
+    }
+    result[3], result[9] = result[9], result[3]
+    result[4], result[7] = result[7], result[4]
+    return string(result)
+}
+
+// randomHex 随机十六进制字符串
+func randomHex(n int) string {
+    buf := make([]byte, n)
+    rand.Read(buf)
+    return hex.EncodeToString(buf)
+}
@@ -0,0 +1,206 @@
+// handlers/accounts.go - 关联B站账号管理
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "path/filepath"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 账号凭证状态
+const (
+    AccountStatusActive     = "active"     // 凭证有效
+    AccountStatusExpired    = "expired"    // 令牌已过期且无法续期，需要重新授权
+    AccountStatusMissing    = "missing"    // 服务端没有该账号的凭证
+    AccountStatusSimulation = "simulation" // 模拟模式的开发者身份
+)
+
+var (
+    accountStoreOnce sync.Once
+    accountStore     *services.AccountStore
+)
+
+// accounts 用户关联的B站账号
+func accounts() *services.AccountStore {
+    accountStoreOnce.Do(func() {
+        accountStore = services.NewAccountStore(
+            filepath.Join(config.GlobalConfig.DataDir, "accounts.json"),
+        )
+    })
+    return accountStore
+}
+
+// AccountForRequest 取出当前用户的关联账号，accountID为空时使用登录时的账号（用户登录时为最早关联的账号）
+func AccountForRequest(c *gin.Context, accountID string) (*services.LinkedAccount, error) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        return nil, fmt.Errorf("请求未经过认证")
+    }
+    if accountID == "" {
+        accountID = identity.AccountID
+    }
+    if accountID == "" {
+        // 用户登录的会话没有默认账号，使用最早关联的账号
+        linked := accounts().List(identity.OwnerID)
+        if len(linked) == 0 {
+            return nil, fmt.Errorf("当前用户还没有关联B站账号")
+        }
+        accountID = linked[0].ID
+    }
+
+    account, ok := accounts().Get(identity.OwnerID, accountID)
+    if !ok {
+        return nil, fmt.Errorf("账号 %s 不存在或未关联到当前用户", accountID)
+    }
+    // 工作区API密钥只能使用该工作区的账号
+    if identity.WorkspaceID != "" {
+        if workspace, ok := workspaces().Get(identity.WorkspaceID); !ok || !workspace.HasAccount(account.ID) {
+            return nil, fmt.Errorf("账号 %s 不在API密钥所属的工作区中", accountID)
+        }
+    }
+    setLogAttrs(c, "account_id", account.ID)
+    return account, nil
+}
+
+// AccountHandler 关联账号处理器
+type AccountHandler struct{}
+
+// NewAccountHandler 创建关联账号处理器
+func NewAccountHandler() *AccountHandler {
+    return &AccountHandler{}
+}
+
+// ListAccounts 列出当前用户关联的账号及凭证状态
+func (h *AccountHandler) ListAccounts(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
+        return
+    }
+
+    list := make([]gin.H, 0)
+    for _, account := range accounts().List(identity.OwnerID) {
+        status, expiresAt := accountStatus(&account)
+        item := gin.H{
+            "id":        account.ID,
+            "uid":       account.UID,
+            "username":  account.Username,
+            "kind":      account.Kind,
+            "status":    status,
+            "defaults":  account.Defaults,
+            "linked_at": account.LinkedAt,
+            "current":   account.ID == identity.AccountID,
+        }
+        if !expiresAt.IsZero() {
+            item["expires_at"] = expiresAt
+        }
+        list = append(list, item)
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":  true,
+        "accounts": list,
+    })
+}
+
+// UpdateAccountDefaults 更新账号的默认投稿设置
+func (h *AccountHandler) UpdateAccountDefaults(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
+        return
+    }
+
+    var defaults services.AccountDefaults
+    if err := c.ShouldBindJSON(&defaults); err != nil || defaults.Category < 0 || defaults.BandwidthLimit < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    if err := accounts().SetDefaults(identity.OwnerID, c.Param("id"), defaults); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":  true,
+        "message":  "默认设置已保存",
+        "defaults": defaults,
+    })
+}
+
+// UnlinkAccount 解除账号关联，无人使用的B站凭证同时在B站注销
+func (h *AccountHandler) UnlinkAccount(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
+        return
+    }
+
+    account, err := accounts().Unlink(identity.OwnerID, c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    // 从工作区中移除该账号
+    for _, workspace := range workspaces().WithAccount(account.ID) {
+        if err := workspaces().RemoveAccount(workspace.ID, account.ID); err != nil {
+            slog.ErrorContext(c.Request.Context(), "从工作区移除账号失败", "workspace_id", workspace.ID, "error", err)
+        }
+    }
+
+    // 使用该账号登录的会话随之失效
+    for _, session := range sessions().List(identity.OwnerID) {
+        if session.AccountID == account.ID {
+            if err := revokeSession(&session); err != nil {
+                slog.ErrorContext(c.Request.Context(), "注销会话失败", "session_id", session.ID, "error", err)
+            }
+        }
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":          true,
+        "message":          "已解除关联",
+        "bilibili_revoked": revokeBilibiliIfUnused(c.Request.Context(), account.UID, account.Kind),
+    })
+}
+
+// accountStatus 根据服务端保存的凭证判断账号状态
+func accountStatus(account *services.LinkedAccount) (status string, expiresAt time.Time) {
+    switch account.Kind {
+    case services.SessionKindOAuth:
+        token, ok := oauthTokens().Get(account.UID)
+        if !ok {
+            return AccountStatusMissing, time.Time{}
+        }
+        if !token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt) && token.RefreshToken == "" {
+            return AccountStatusExpired, token.ExpiresAt
+        }
+        return AccountStatusActive, token.ExpiresAt
+    case services.SessionKindCookie:
+        if _, ok := webCredentials().Get(account.UID); !ok {
+            return AccountStatusMissing, time.Time{}
+        }
+        return AccountStatusActive, time.Time{}
+    case services.SessionKindDev:
+        return AccountStatusSimulation, time.Time{}
+    }
+    return AccountStatusMissing, time.Time{}
+}
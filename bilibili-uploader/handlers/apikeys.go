@@ -0,0 +1,276 @@
+// handlers/apikeys.go - 机器客户端的API密钥
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "errors"
+    "fmt"
+    "net/http"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// defaultAPIKeyTTL 未指定有效期时API密钥的有效期
+const defaultAPIKeyTTL = 90 * 24 * time.Hour
+
+var (
+    apiKeyStoreOnce sync.Once
+    apiKeyStore     *services.APIKeyStore
+)
+
+// apiKeys API密钥存储
+func apiKeys() *services.APIKeyStore {
+    apiKeyStoreOnce.Do(func() {
+        apiKeyStore = services.NewAPIKeyStore(
+            filepath.Join(config.GlobalConfig.DataDir, "api_keys.json"),
+        )
+    })
+    return apiKeyStore
+}
+
+// authenticateAPIKey 校验API密钥；工作区密钥的创建者离开工作区后密钥随之失效
+func authenticateAPIKey(plaintext string) (*Identity, string, string) {
+    key, err := apiKeys().Authenticate(plaintext)
+    if err != nil {
+        if errors.Is(err, services.ErrAPIKeyExpired) {
+            return nil, AuthErrTokenExpired, "API密钥已过期"
+        }
+        return nil, AuthErrInvalidToken, "API密钥无效"
+    }
+
+    user, ok := users().Get(key.UserID)
+    if !ok {
+        return nil, AuthErrSessionRevoked, "API密钥所属用户不存在"
+    }
+    if key.WorkspaceID != "" {
+        workspace, ok := workspaces().Get(key.WorkspaceID)
+        if !ok || workspace.RoleOf(key.UserID) == "" {
+            return nil, AuthErrSessionRevoked, "API密钥所属工作区已不可用"
+        }
+    }
+
+    return &Identity{
+        OwnerID:     key.UserID,
+        Username:    user.Name(),
+        Kind:        services.SessionKindUser,
+        ExpiresAt:   key.ExpiresAt,
+        APIKeyID:    key.ID,
+        WorkspaceID: key.WorkspaceID,
+        Scopes:      key.Scopes,
+    }, "", ""
+}
+
+// RequireScope 通过API密钥访问时要求具备全部指定权限，登录会话不受限制
+func RequireScope(scopes ...string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        identity, ok := CurrentIdentity(c)
+        if !ok {
+            abortAuth(c, AuthErrMissingToken, "请先登录")
+            return
+        }
+        if identity.APIKey() {
+            for _, scope := range scopes {
+                if !identity.hasScope(scope) {
+                    c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+                        "success": false,
+                        "code":    AuthErrInsufficientScope,
+                        "message": fmt.Sprintf("API密钥缺少权限: %s", scope),
+                    })
+                    return
+                }
+            }
+        }
+        c.Next()
+    }
+}
+
+// RequireSession 只允许登录会话访问（账号、会话和密钥管理等），拒绝API密钥
+func RequireSession() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        identity, ok := CurrentIdentity(c)
+        if !ok {
+            abortAuth(c, AuthErrMissingToken, "请先登录")
+            return
+        }
+        if identity.APIKey() {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+                "success": false,
+                "code":    AuthErrSessionRequired,
+                "message": "该接口不能使用API密钥访问",
+            })
+            return
+        }
+        c.Next()
+    }
+}
+
+// RejectAPIKey 用于登录和关联B站账号的接口：这些接口不要求登录，但已登录时会把账号关联到当前用户并签发浏览器会话，
+// 携带API密钥的请求一律拒绝，避免任意权限的密钥关联账号或换取会话
+func RejectAPIKey() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if strings.HasPrefix(tokenFromRequest(c), services.APIKeyPrefix) {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+                "success": false,
+                "code":    AuthErrSessionRequired,
+                "message": errAPIKeySession.Error(),
+            })
+            return
+        }
+        c.Next()
+    }
+}
+
+// hasScope 身份是否具备指定权限
+func (i *Identity) hasScope(scope string) bool {
+    for _, s := range i.Scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// APIKeyHandler API密钥处理器
+type APIKeyHandler struct{}
+
+// NewAPIKeyHandler 创建API密钥处理器
+func NewAPIKeyHandler() *APIKeyHandler {
+    return &APIKeyHandler{}
+}
+
+// CreateKey 创建API密钥，明文只在响应中返回一次
+// 指定workspace_id时需为该工作区的发布者，密钥只能访问该工作区
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    var req struct {
+        Name        string   `json:"name" binding:"required"`
+        Scopes      []string `json:"scopes" binding:"required"`
+        WorkspaceID string   `json:"workspace_id"`
+        ExpiresIn   string   `json:"expires_in"` // 如 720h，默认90天
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    ttl := defaultAPIKeyTTL
+    if req.ExpiresIn != "" {
+        parsed, err := time.ParseDuration(req.ExpiresIn)
+        if err != nil || parsed <= 0 {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "success": false,
+                "message": "expires_in格式错误，如 720h",
+            })
+            return
+        }
+        ttl = parsed
+    }
+
+    if req.WorkspaceID != "" {
+        workspace, ok := workspaces().Get(req.WorkspaceID)
+        if !ok || !services.RoleAllows(workspace.RoleOf(identity.OwnerID), services.RolePublisher) {
+            c.JSON(http.StatusForbidden, gin.H{
+                "success": false,
+                "message": "只有工作区发布者可以创建工作区密钥",
+            })
+            return
+        }
+    }
+
+    plaintext, key, err := apiKeys().Create(&services.APIKey{
+        Name:        strings.TrimSpace(req.Name),
+        UserID:      identity.OwnerID,
+        WorkspaceID: req.WorkspaceID,
+        Scopes:      req.Scopes,
+    }, ttl)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    key.Hash = ""
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "密钥已创建，请立即保存，之后无法再次查看",
+        "api_key": plaintext,
+        "key":     key,
+    })
+}
+
+// ListKeys 列出自己创建的密钥和自己担任发布者的工作区的密钥
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "keys":    apiKeys().List(identity.OwnerID, publisherWorkspaceIDs(identity.OwnerID)),
+    })
+}
+
+// RevokeKey 吊销密钥（创建者或工作区发布者）
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    key, ok := apiKeys().Get(c.Param("id"))
+    allowed := ok && key.UserID == identity.OwnerID
+    if ok && !allowed && key.WorkspaceID != "" {
+        if workspace, found := workspaces().Get(key.WorkspaceID); found {
+            allowed = services.RoleAllows(workspace.RoleOf(identity.OwnerID), services.RolePublisher)
+        }
+    }
+    if !allowed {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "密钥不存在",
+        })
+        return
+    }
+
+    if err := apiKeys().Revoke(key.ID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "密钥已吊销",
+    })
+}
+
+// publisherWorkspaceIDs 用户担任发布者的工作区
+func publisherWorkspaceIDs(userID string) []string {
+    ids := make([]string, 0)
+    for _, workspace := range workspaces().ListForUser(userID) {
+        if services.RoleAllows(workspace.RoleOf(userID), services.RolePublisher) {
+            ids = append(ids, workspace.ID)
+        }
+    }
+    return ids
+}
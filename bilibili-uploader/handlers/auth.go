@@ -6,8 +6,10 @@ import (
     "bilibili-uploader/services"
     // "encoding/json"
     "fmt"
-    "log"
+    "html/template"
+    "log/slog"
     "net/http"
+    "sync"
     "time"
     
     "github.com/gin-gonic/gin"
@@ -22,33 +24,42 @@ type AuthHandler struct {
 // NewAuthHandler 创建认证处理器
 func NewAuthHandler() *AuthHandler {
     return &AuthHandler{
-        oauthService: services.NewBilibiliOAuth(
-            config.GlobalConfig.BilibiliClientID,
-            config.GlobalConfig.BilibiliClientSecret,
-            config.GlobalConfig.BilibiliRedirectURI,
-        ),
+        oauthService: newOAuthService(),
     }
 }
 
 // GetAuthURL 获取授权URL
 func (h *AuthHandler) GetAuthURL(c *gin.Context) {
-    // 生成state参数（防止CSRF攻击）
-    state := generateState()
+    // 浏览器绑定值：state只能由发起授权的同一浏览器使用
+    binding, err := c.Cookie(oauthBindingCookie)
+    if err != nil || binding == "" {
+        binding, err = services.RandomID(32)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "success": false,
+                "message": "生成授权参数失败",
+            })
+            return
+        }
+    }
     
-    // 保存state到session或缓存（这里简化处理，实际应该用Redis等）
-    c.SetCookie("oauth_state", state, 3600, "/", "", false, true)
+    // state和PKCE code_verifier保存在服务端，一次性使用
+    state, pkce, err := oauthStates().Issue(binding)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成授权参数失败",
+        })
+        return
+    }
     
-    // 构建授权URL
-    authURL := fmt.Sprintf(
-        "https://passport.bilibili.com/register/pc_oauth2.html?client_id=%s&response_type=code&redirect_uri=%s&scope=video-upload&state=%s",
-        config.GlobalConfig.BilibiliClientID,
-        config.GlobalConfig.BilibiliRedirectURI,
-        state,
-    )
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(oauthBindingCookie, binding, int(services.DefaultOAuthStateTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
     
     c.JSON(http.StatusOK, gin.H{
-        "success": true,
-        "auth_url": authURL,
+        "success":  true,
+        "auth_url": h.oauthService.AuthURL(state.State, pkce.Challenge),
+        "state":    state.State,
     })
 }
 
@@ -66,9 +77,10 @@ func (h *AuthHandler) HandleCallback(c *gin.Context) {
         return
     }
     
-    // 验证state（防止CSRF）
-    savedState, err := c.Cookie("oauth_state")
-    if err != nil || savedState != state {
+    // 验证并消费state（防止CSRF和重放）
+    issued, err := consumeOAuthState(c, state)
+    if err != nil {
+        slog.WarnContext(c.Request.Context(), "state验证失败", "error", err)
         c.JSON(http.StatusBadRequest, gin.H{
             "success": false,
             "message": "state验证失败",
@@ -77,105 +89,95 @@ func (h *AuthHandler) HandleCallback(c *gin.Context) {
     }
     
     // 用授权码换取access token
-    tokenResp, err := h.oauthService.ExchangeCode(code)
+    tokenResp, err := h.oauthService.ExchangeCode(c.Request.Context(), code, issued.CodeVerifier)
     if err != nil {
-        log.Printf("换取token失败: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "success": false,
-            "message": "获取访问令牌失败",
-        })
+        RespondBilibiliError(c, "获取访问令牌失败", err, nil)
         return
     }
     
     // 获取用户信息
-    userInfo, err := h.oauthService.GetUserInfo(tokenResp.AccessToken)
+    userInfo, err := h.oauthService.GetUserInfo(c.Request.Context(), tokenResp.AccessToken)
     if err != nil {
-        log.Printf("获取用户信息失败: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "success": false,
-            "message": "获取用户信息失败",
-        })
+        RespondBilibiliError(c, "获取用户信息失败", err, nil)
         return
     }
     
-    // 生成内部JWT token（包含B站access token）
-    jwtToken, err := generateJWT(tokenResp.AccessToken, userInfo)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "success": false,
-            "message": "生成令牌失败",
-        })
-        return
+    // 服务端保存refresh_token，用于到期前自动续期
+    if err := oauthTokens().Save(services.NewOAuthToken(userInfo.UID, tokenResp)); err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存OAuth令牌失败", "bilibili_uid", userInfo.UID, "error", err)
     }
     
-    // 返回成功页面（带token）
-    html := fmt.Sprintf(`
-    <!DOCTYPE html>
-    <html>
-    <head>
-        <title>授权成功</title>
-        <style>
-            body { 
-                font-family: Arial; 
-                display: flex; 
-                justify-content: center; 
-                align-items: center; 
-                height: 100vh;
-                background: linear-gradient(135deg, #00a1d6, #0081c6);
-            }
-            .container {
-                background: white;
-                padding: 40px;
-                border-radius: 12px;
-                text-align: center;
-                box-shadow: 0 10px 30px rgba(0,0,0,0.2);
-            }
-            h1 { color: #4caf50; }
-            p { color: #666; margin: 20px 0; }
-            .btn {
-                display: inline-block;
-                padding: 12px 24px;
-                background: #00a1d6;
-                color: white;
-                text-decoration: none;
-                border-radius: 6px;
-                margin-top: 20px;
-            }
-        </style>
-    </head>
-    <body>
-        <div class="container">
-            <h1>✅ 授权成功！</h1>
-            <p>您已成功授权B站账号: <strong>%s</strong></p>
-            <p>现在可以使用一键投稿功能了</p>
-            <a href="/" class="btn">返回主页</a>
-        </div>
-        <script>
-            // 保存token到localStorage
-            localStorage.setItem('bilibili_token', '%s');
-            localStorage.setItem('bilibili_user', '%s');
-            
-            // 3秒后自动跳转
-            setTimeout(() => {
-                window.location.href = '/';
-            }, 3000);
-        </script>
-    </body>
-    </html>
-    `, userInfo.Username, jwtToken, userInfo.Username)
+    // 创建服务端会话，JWT中只包含会话ID，通过HttpOnly Cookie下发
+    if _, err := issueSession(c, userInfo, services.SessionKindOAuth, jwtTTLFor(tokenResp)); err != nil {
+        respondSessionError(c, err)
+        return
+    }
     
     c.Header("Content-Type", "text/html; charset=utf-8")
-    c.String(http.StatusOK, html)
+    authCallbackPage.Execute(c.Writer, gin.H{
+        "Username": userInfo.Username,
+    })
 }
 
+// authCallbackPage B站授权成功页，会话令牌只通过HttpOnly Cookie下发，不写入页面
+var authCallbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>授权成功</title>
+    <style>
+        body {
+            font-family: Arial;
+            display: flex;
+            justify-content: center;
+            align-items: center;
+            height: 100vh;
+            background: linear-gradient(135deg, #00a1d6, #0081c6);
+        }
+        .container {
+            background: white;
+            padding: 40px;
+            border-radius: 12px;
+            text-align: center;
+            box-shadow: 0 10px 30px rgba(0,0,0,0.2);
+        }
+        h1 { color: #4caf50; }
+        p { color: #666; margin: 20px 0; }
+        .btn {
+            display: inline-block;
+            padding: 12px 24px;
+            background: #00a1d6;
+            color: white;
+            text-decoration: none;
+            border-radius: 6px;
+            margin-top: 20px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>✅ 授权成功！</h1>
+        <p>您已成功授权B站账号: <strong>{{.Username}}</strong></p>
+        <p>现在可以使用一键投稿功能了</p>
+        <a href="/" class="btn">返回主页</a>
+    </div>
+    <script>
+        // 3秒后自动跳转
+        setTimeout(() => { window.location.href = '/'; }, 3000);
+    </script>
+</body>
+</html>
+`))
+
 // ExchangeToken 交换令牌（前端AJAX调用）
 func (h *AuthHandler) ExchangeToken(c *gin.Context) {
     var req struct {
         Code        string `json:"code"`
+        State       string `json:"state"`
         RedirectURI string `json:"redirect_uri"`
     }
     
-    if err := c.ShouldBindJSON(&req); err != nil {
+    if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.State == "" {
         c.JSON(http.StatusBadRequest, gin.H{
             "success": false,
             "message": "参数错误",
@@ -183,33 +185,39 @@ func (h *AuthHandler) ExchangeToken(c *gin.Context) {
         return
     }
     
-    // 交换token
-    tokenResp, err := h.oauthService.ExchangeCode(req.Code)
+    // 验证并消费state
+    issued, err := consumeOAuthState(c, req.State)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
+        c.JSON(http.StatusBadRequest, gin.H{
             "success": false,
-            "message": "获取访问令牌失败",
+            "message": "state验证失败",
         })
         return
     }
     
+    // 交换token
+    tokenResp, err := h.oauthService.ExchangeCode(c.Request.Context(), req.Code, issued.CodeVerifier)
+    if err != nil {
+        RespondBilibiliError(c, "获取访问令牌失败", err, nil)
+        return
+    }
+    
     // 获取用户信息
-    userInfo, err := h.oauthService.GetUserInfo(tokenResp.AccessToken)
+    userInfo, err := h.oauthService.GetUserInfo(c.Request.Context(), tokenResp.AccessToken)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "success": false,
-            "message": "获取用户信息失败",
-        })
+        RespondBilibiliError(c, "获取用户信息失败", err, nil)
         return
     }
     
-    // 生成JWT
-    jwtToken, err := generateJWT(tokenResp.AccessToken, userInfo)
+    // 服务端保存refresh_token
+    if err := oauthTokens().Save(services.NewOAuthToken(userInfo.UID, tokenResp)); err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存OAuth令牌失败", "bilibili_uid", userInfo.UID, "error", err)
+    }
+    
+    // 创建会话
+    jwtToken, err := issueSession(c, userInfo, services.SessionKindOAuth, jwtTTLFor(tokenResp))
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "success": false,
-            "message": "生成令牌失败",
-        })
+        respondSessionError(c, err)
         return
     }
     
@@ -221,58 +229,52 @@ func (h *AuthHandler) ExchangeToken(c *gin.Context) {
     })
 }
 
-// VerifyToken 验证令牌
+// VerifyToken 验证令牌（需经过AuthMiddleware）
 func (h *AuthHandler) VerifyToken(c *gin.Context) {
-    // 从Authorization header获取token
-    authHeader := c.GetHeader("Authorization")
-    if authHeader == "" {
-        c.JSON(http.StatusUnauthorized, gin.H{
-            "success": false,
-            "message": "未提供认证令牌",
-        })
-        return
-    }
-    
-    // 解析Bearer token
-    tokenString := ""
-    if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-        tokenString = authHeader[7:]
-    }
-    
-    // 验证JWT
-    claims, err := validateJWT(tokenString)
-    if err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{
-            "success": false,
-            "message": "令牌无效或已过期",
-        })
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
         return
     }
     
     c.JSON(http.StatusOK, gin.H{
-        "success":  true,
-        "username": claims.Username,
-        "uid":      claims.UID,
+        "success":    true,
+        "username":   identity.Username,
+        "uid":        identity.UID,
+        "kind":       identity.Kind,
+        "expires_at": identity.ExpiresAt,
     })
 }
 
-// JWTClaims JWT声明
+// JWTClaims JWT声明，只携带会话ID，B站凭证保存在服务端
 type JWTClaims struct {
-    BilibiliToken string `json:"bilibili_token"`
-    Username      string `json:"username"`
-    UID           int64  `json:"uid"`
+    SessionID string `json:"sid"`
     jwt.RegisteredClaims
 }
 
-// generateJWT 生成JWT令牌
-func generateJWT(bilibiliToken string, userInfo *services.UserInfo) (string, error) {
+// defaultJWTTTL JWT默认有效期（7天）
+const defaultJWTTTL = 7 * 24 * time.Hour
+
+// jwtTTLFor 计算JWT有效期：有refresh_token时服务端可续期，否则不超过B站令牌的有效期
+func jwtTTLFor(tokenResp *services.TokenResponse) time.Duration {
+    if tokenResp.RefreshToken != "" || tokenResp.ExpiresIn <= 0 {
+        return defaultJWTTTL
+    }
+    ttl := time.Duration(tokenResp.ExpiresIn) * time.Second
+    if ttl > defaultJWTTTL {
+        return defaultJWTTTL
+    }
+    return ttl
+}
+
+// generateJWT 为会话生成JWT令牌
+func generateJWT(session *services.Session) (string, error) {
     claims := JWTClaims{
-        BilibiliToken: bilibiliToken,
-        Username:      userInfo.Username,
-        UID:           userInfo.UID,
+        SessionID: session.ID,
         RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7天有效期
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
+            ID:        session.TokenID,
+            ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+            IssuedAt:  jwt.NewNumericDate(session.CreatedAt),
         },
     }
     
@@ -283,6 +285,10 @@ func generateJWT(bilibiliToken string, userInfo *services.UserInfo) (string, err
 // validateJWT 验证JWT令牌
 func validateJWT(tokenString string) (*JWTClaims, error) {
     token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+        // 只接受HS256，防止alg替换攻击
+        if token.Method != jwt.SigningMethodHS256 {
+            return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+        }
         return []byte(config.GlobalConfig.JWTSecret), nil
     })
     
@@ -297,31 +303,46 @@ func validateJWT(tokenString string) (*JWTClaims, error) {
     return nil, fmt.Errorf("invalid token")
 }
 
-// GetBilibiliToken 从JWT中提取B站token
+// GetBilibiliToken 获取当前身份有效的B站访问令牌（需经过AuthMiddleware）
 func GetBilibiliToken(c *gin.Context) (string, error) {
-    authHeader := c.GetHeader("Authorization")
-    if authHeader == "" {
-        return "", fmt.Errorf("no authorization header")
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        return "", fmt.Errorf("请求未经过认证")
     }
-    
-    tokenString := ""
-    if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-        tokenString = authHeader[7:]
+    if identity.Kind != services.SessionKindOAuth {
+        return "", fmt.Errorf("会话使用%s凭证，没有OAuth访问令牌", identity.Kind)
     }
     
-    claims, err := validateJWT(tokenString)
+    token, err := oauthTokens().Refresh(c.Request.Context(), newOAuthService(), identity.UID, false, config.GlobalConfig.TokenRefreshBefore)
     if err != nil {
         return "", err
     }
-    
-    return claims.BilibiliToken, nil
+    return token.AccessToken, nil
 }
 
-// generateState 生成随机state
-func generateState() string {
-    b := make([]byte, 16)
-    for i := range b {
-        b[i] = byte(65 + (time.Now().UnixNano() % 26))
+// oauthBindingCookie 将授权state绑定到浏览器的HttpOnly Cookie
+const oauthBindingCookie = "oauth_binding"
+
+var (
+    oauthStateStoreOnce sync.Once
+    oauthStateStore     *services.OAuthStateStore
+)
+
+// oauthStates 服务端保存的一次性授权state
+func oauthStates() *services.OAuthStateStore {
+    oauthStateStoreOnce.Do(func() {
+        oauthStateStore = services.NewOAuthStateStore(services.DefaultOAuthStateTTL)
+    })
+    return oauthStateStore
+}
+
+// consumeOAuthState 使用浏览器绑定Cookie校验并消费state
+func consumeOAuthState(c *gin.Context, state string) (*services.OAuthState, error) {
+    binding, _ := c.Cookie(oauthBindingCookie)
+    issued, err := oauthStates().Consume(state, binding)
+    if err != nil {
+        return nil, err
     }
-    return string(b)
-}
\ No newline at end of file
+    c.SetCookie(oauthBindingCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+    return issued, nil
+}
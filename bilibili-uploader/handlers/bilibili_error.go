@@ -0,0 +1,84 @@
+// handlers/bilibili_error.go - 把B站接口错误转换为客户端响应
+package handlers
+
+import (
+    "bilibili-uploader/services"
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "math"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+)
+
+// B站接口错误对应的错误码
+const (
+    BiliErrReauth    = "bilibili_reauth"    // B站凭证失效，需要重新授权或登录（403）
+    BiliErrRetryable = "bilibili_retryable" // 频率限制、风控或B站故障，稍后重试（503）
+    BiliErrRejected  = "bilibili_rejected"  // 稿件或参数被B站拒绝，需要修改（422）
+    BiliErrUpstream  = "bilibili_error"     // 其他B站接口或网络错误（502）
+    
+    ErrShuttingDown  = "shutting_down"           // 服务正在退出，请求被中断，稍后重试（503）
+    ErrNotConfigured = "bilibili_not_configured" // 未配置B站API，无法上传和投稿（503）
+)
+
+// shutdownRetryAfter 服务退出中断请求时建议的重试等待秒数
+const shutdownRetryAfter = 30
+
+// RespondBilibiliError 按错误分类返回状态码、错误码和提示，extra中的字段一并返回
+// 凭证失效用403而不是401，避免客户端误以为本服务的登录已过期
+func RespondBilibiliError(c *gin.Context, action string, err error, extra gin.H) {
+    slog.ErrorContext(c.Request.Context(), action, "error", err)
+
+    body := gin.H{
+        "success": false,
+        "code":    BiliErrUpstream,
+        "message": fmt.Sprintf("%s: %v", action, err),
+    }
+    status := http.StatusBadGateway
+
+    // 服务退出时取消了请求上下文，不是B站的问题
+    if errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil {
+        c.Header("Retry-After", strconv.Itoa(shutdownRetryAfter))
+        c.JSON(http.StatusServiceUnavailable, gin.H{
+            "success":     false,
+            "code":        ErrShuttingDown,
+            "message":     fmt.Sprintf("%s: 服务正在重启，请稍后重试", action),
+            "retry_after": shutdownRetryAfter,
+        })
+        return
+    }
+
+    if biliErr, ok := services.AsBilibiliError(err); ok {
+        body["message"] = fmt.Sprintf("%s: %s", action, biliErr.Hint())
+        if biliErr.Message != "" {
+            body["message"] = fmt.Sprintf("%s: %s（B站返回: %s）", action, biliErr.Hint(), biliErr.Message)
+        }
+        body["bilibili_code"] = biliErr.Code
+        body["bilibili_message"] = biliErr.Message
+        body["endpoint"] = biliErr.Endpoint
+
+        switch biliErr.Class() {
+        case services.ErrorClassReauth:
+            status = http.StatusForbidden
+            body["code"] = BiliErrReauth
+        case services.ErrorClassRetryable:
+            retryAfter := int(math.Ceil(biliErr.RetryAfter().Seconds()))
+            c.Header("Retry-After", strconv.Itoa(retryAfter))
+            status = http.StatusServiceUnavailable
+            body["code"] = BiliErrRetryable
+            body["retry_after"] = retryAfter
+        case services.ErrorClassUser:
+            status = http.StatusUnprocessableEntity
+            body["code"] = BiliErrRejected
+        }
+    }
+
+    for key, value := range extra {
+        body[key] = value
+    }
+    c.JSON(status, body)
+}
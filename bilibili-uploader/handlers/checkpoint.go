@@ -0,0 +1,77 @@
+// handlers/checkpoint.go - 上传断点和可续传的临时文件
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+var (
+    checkpointStoreOnce sync.Once
+    checkpointStore     *services.CheckpointStore
+)
+
+// UploadCheckpoints 服务端共享的上传断点存储（HTTP上传和监控目录共用一个文件）
+func UploadCheckpoints() *services.CheckpointStore {
+    checkpointStoreOnce.Do(func() {
+        checkpointStore = services.NewCheckpointStore(
+            filepath.Join(config.GlobalConfig.DataDir, services.CheckpointStoreFile),
+        )
+    })
+    return checkpointStore
+}
+
+// ResumableTempFile 把请求上传的临时文件按账号和内容哈希重命名；
+// 同一账号上传相同内容时，如果上次的上传被中断且断点仍有效，改用上次保留的文件，使重试从断点继续
+func ResumableTempFile(accountID, tempFile string) string {
+    sum, err := fileSHA256(tempFile)
+    if err != nil {
+        return tempFile
+    }
+    target := filepath.Join(filepath.Dir(tempFile), "resume_"+accountID+"_"+sum[:32]+filepath.Ext(tempFile))
+
+    if _, ok := UploadCheckpoints().Get(target); ok {
+        os.Remove(tempFile)
+        slog.Info("重试的上传与中断的上传内容相同，从断点继续", "account_id", accountID, "path", target)
+        return target
+    }
+    // 同名文件没有断点时可能正被其他请求上传，保留原临时文件
+    if _, err := os.Stat(target); err == nil {
+        return tempFile
+    }
+    if err := os.Rename(tempFile, target); err != nil {
+        return tempFile
+    }
+    return target
+}
+
+// ReleaseTempFile 删除上传结束的临时文件，上传中断并留下断点时保留，供重试时继续
+func ReleaseTempFile(tempFile string) {
+    if _, ok := UploadCheckpoints().Get(tempFile); ok {
+        slog.Info("上传已中断，保留临时文件以便从断点继续", "path", tempFile)
+        return
+    }
+    os.Remove(tempFile)
+}
+
+// fileSHA256 计算文件内容的SHA-256
+func fileSHA256(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,333 @@
+// handlers/credential.go - 网页Cookie凭证处理
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/logging"
+    "bilibili-uploader/services"
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "path/filepath"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    credentialStoresOnce sync.Once
+    credentialStoresErr  error
+    credentialStore      *services.CredentialStore
+    tokenStore           *services.TokenStore
+)
+
+// InitCredentialStores 加载加密保存的B站凭证，密钥缺失或解密失败时返回错误
+func InitCredentialStores() error {
+    credentialStoresOnce.Do(func() {
+        keyring, err := CredentialKeyring()
+        if err != nil {
+            credentialStoresErr = err
+            return
+        }
+
+        credentialStore, err = services.NewCredentialStore(
+            filepath.Join(config.GlobalConfig.DataDir, services.CredentialStoreFile), keyring,
+        )
+        if err != nil {
+            credentialStoresErr = err
+            return
+        }
+
+        tokenStore, credentialStoresErr = services.NewTokenStore(
+            filepath.Join(config.GlobalConfig.DataDir, services.TokenStoreFile), keyring,
+        )
+    })
+    return credentialStoresErr
+}
+
+// CredentialKeyring 按配置创建凭证加密密钥环，未配置密钥时返回错误，
+// 只有显式开启ALLOW_PLAINTEXT_CREDENTIALS才以明文保存
+func CredentialKeyring() (*services.Keyring, error) {
+    if config.GlobalConfig.CredentialKeys == "" {
+        if config.GlobalConfig.AllowPlaintextCredentials {
+            return services.PlaintextKeyring(), nil
+        }
+        return nil, fmt.Errorf("%w（可用 bilibili-uploader rotate-keys --new-key 生成密钥，本地开发可设置ALLOW_PLAINTEXT_CREDENTIALS=true）", services.ErrNoCredentialKey)
+    }
+    keyring, err := services.ParseKeyring(config.GlobalConfig.CredentialKeys, config.GlobalConfig.CredentialKeyVersion)
+    if err != nil {
+        return nil, fmt.Errorf("CREDENTIAL_KEYS配置错误: %v", err)
+    }
+    return keyring, nil
+}
+
+// mustInitCredentialStores 凭证无法读取时直接退出，避免以空凭证继续运行
+func mustInitCredentialStores() {
+    if err := InitCredentialStores(); err != nil {
+        logging.Fatal("加载B站凭证失败", "error", err)
+    }
+}
+
+// webCredentials 服务端保存的网页凭证
+func webCredentials() *services.CredentialStore {
+    mustInitCredentialStores()
+    return credentialStore
+}
+
+// oauthTokens 服务端保存的OAuth令牌（含refresh_token）
+func oauthTokens() *services.TokenStore {
+    mustInitCredentialStores()
+    return tokenStore
+}
+
+// newOAuthService 按配置创建OAuth服务
+func newOAuthService() *services.BilibiliOAuth {
+    return services.NewBilibiliOAuth(
+        config.GlobalConfig.BilibiliClientID,
+        config.GlobalConfig.BilibiliClientSecret,
+        config.GlobalConfig.BilibiliRedirectURI,
+    )
+}
+
+// StartTokenRenewal 启动OAuth令牌后台续期，返回停止函数
+func StartTokenRenewal() func() {
+    return oauthTokens().StartRenewal(
+        newOAuthService(),
+        config.GlobalConfig.TokenRenewInterval,
+        config.GlobalConfig.TokenRefreshBefore,
+    )
+}
+
+// ImportCookie 导入浏览器Cookie凭证
+func (h *AuthHandler) ImportCookie(c *gin.Context) {
+    var req struct {
+        Cookie     string `json:"cookie"` // 完整Cookie字符串
+        SESSDATA   string `json:"SESSDATA"`
+        BiliJct    string `json:"bili_jct"`
+        DedeUserID string `json:"DedeUserID"`
+    }
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    var cred *services.WebCredential
+    if req.Cookie != "" {
+        parsed, err := services.ParseCookieString(req.Cookie)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "success": false,
+                "message": err.Error(),
+            })
+            return
+        }
+        cred = parsed
+    } else {
+        cred = &services.WebCredential{
+            SESSDATA:   req.SESSDATA,
+            BiliJct:    req.BiliJct,
+            DedeUserID: req.DedeUserID,
+        }
+        if err := cred.Validate(); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "success": false,
+                "message": err.Error(),
+            })
+            return
+        }
+    }
+
+    userInfo, err := saveWebCredential(c.Request.Context(), h.oauthService.BaseURL, cred)
+    if services.IsRetryable(err) {
+        RespondBilibiliError(c, "校验Cookie失败", err, nil)
+        return
+    }
+    if err != nil {
+        slog.WarnContext(c.Request.Context(), "导入Cookie失败", "error", err)
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "success": false,
+            "message": "Cookie无效或已过期",
+        })
+        return
+    }
+
+    jwtToken, err := issueSession(c, userInfo, services.SessionKindCookie, defaultJWTTTL)
+    if err != nil {
+        respondSessionError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":      true,
+        "access_token": jwtToken,
+        "username":     userInfo.Username,
+        "uid":          userInfo.UID,
+    })
+}
+
+// saveWebCredential 通过nav接口校验并保存网页凭证
+func saveWebCredential(ctx context.Context, apiBaseURL string, cred *services.WebCredential) (*services.UserInfo, error) {
+    userInfo, err := services.CheckWebCredential(ctx, apiBaseURL, cred)
+    if err != nil {
+        return nil, err
+    }
+    if err := webCredentials().Save(cred); err != nil {
+        return nil, fmt.Errorf("保存凭证失败: %v", err)
+    }
+    slog.Info("已保存网页凭证", "bilibili_uid", userInfo.UID)
+    return userInfo, nil
+}
+
+var (
+    // errBilibiliNotConfigured 未配置B站开放平台应用时，OAuth授权的账号不能上传和投稿
+    errBilibiliNotConfigured = errors.New("未配置B站开放平台应用，OAuth授权的账号无法上传，请配置BILIBILI_CLIENT_ID和BILIBILI_CLIENT_SECRET、设置BILIBILI_FAKE=true，或改用扫码登录/导入Cookie的账号")
+
+    // errDevAccount 模拟模式的开发者身份没有B站凭证
+    errDevAccount = errors.New("模拟模式的开发者身份没有B站凭证，请扫码登录或导入Cookie后再上传")
+)
+
+// checkBilibiliAccount 账号能否调用B站接口：OAuth账号需要配置开放平台应用（或本地模拟服务），
+// 扫码登录和导入Cookie的账号使用网页凭证，不依赖应用配置
+func checkBilibiliAccount(account *services.LinkedAccount) error {
+    switch account.Kind {
+    case services.SessionKindOAuth:
+        if !config.IsBilibiliConfigured() {
+            return errBilibiliNotConfigured
+        }
+    case services.SessionKindDev:
+        return errDevAccount
+    }
+    return nil
+}
+
+// RequireBilibili 账号不能调用B站接口时返回503，返回false时已写入响应
+func RequireBilibili(c *gin.Context, account *services.LinkedAccount) bool {
+    err := checkBilibiliAccount(account)
+    if err == nil {
+        return true
+    }
+    c.JSON(http.StatusServiceUnavailable, gin.H{
+        "success": false,
+        "code":    ErrNotConfigured,
+        "message": err.Error(),
+    })
+    return false
+}
+
+// GetBilibiliUploader 为当前用户的关联账号创建上传器（需经过AuthMiddleware）
+// accountID为空时使用登录时的账号，并按账号设置应用上传限速
+func GetBilibiliUploader(c *gin.Context, accountID string) (*services.BilibiliUploader, *services.LinkedAccount, error) {
+    account, err := AccountForRequest(c, accountID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    uploader, err := uploaderFor(account)
+    if err != nil {
+        return nil, nil, err
+    }
+    return uploader, account, nil
+}
+
+// WatchUploader 为监控目录任务创建上传器，按用户ID和账号ID解析关联账号的凭证
+// accountID为空时使用该用户最早关联的账号；OAuth令牌由RefreshingAuth自动续期
+func WatchUploader(ownerID, accountID string) (*services.BilibiliUploader, *services.LinkedAccount, error) {
+    account, err := watchAccount(ownerID, accountID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    uploader, err := uploaderFor(account)
+    if err != nil {
+        return nil, nil, err
+    }
+    return uploader, account, nil
+}
+
+// CheckWatchAccount 启动监控目录前检查投稿账号能否调用B站接口；
+// 账号尚未关联时不报错，关联后投稿时再解析
+func CheckWatchAccount(ownerID, accountID string) error {
+    if ownerID == "" {
+        return fmt.Errorf("未配置WATCH_USER_ID")
+    }
+    account, err := watchAccount(ownerID, accountID)
+    if err != nil {
+        return nil
+    }
+    return checkBilibiliAccount(account)
+}
+
+// watchAccount 按用户ID和账号ID查找监控目录的投稿账号，accountID为空时使用最早关联的账号
+func watchAccount(ownerID, accountID string) (*services.LinkedAccount, error) {
+    if ownerID == "" {
+        return nil, fmt.Errorf("未配置WATCH_USER_ID")
+    }
+    if accountID == "" {
+        linked := accounts().List(ownerID)
+        if len(linked) == 0 {
+            return nil, fmt.Errorf("用户 %s 还没有关联B站账号", ownerID)
+        }
+        accountID = linked[0].ID
+    }
+    account, ok := accounts().Get(ownerID, accountID)
+    if !ok {
+        return nil, fmt.Errorf("账号 %s 不存在或未关联到用户 %s", accountID, ownerID)
+    }
+    return account, nil
+}
+
+// uploaderFor 为关联账号创建上传器，并按账号设置应用上传限速，中断的上传记录到共享的断点存储
+func uploaderFor(account *services.LinkedAccount) (*services.BilibiliUploader, error) {
+    auth, err := authenticatorFor(account)
+    if err != nil {
+        return nil, err
+    }
+    uploader := services.NewBilibiliUploaderWithAuth(auth)
+    uploader.BandwidthLimit = account.Defaults.BandwidthLimit
+    uploader.PartRetries = config.GlobalConfig.UploadPartRetries
+    uploader.Checkpoints = UploadCheckpoints()
+    return uploader, nil
+}
+
+// authenticatorFor 按账号的凭证类型选择认证方式
+func authenticatorFor(account *services.LinkedAccount) (services.Authenticator, error) {
+    if err := checkBilibiliAccount(account); err != nil {
+        return nil, err
+    }
+
+    switch account.Kind {
+    case services.SessionKindOAuth:
+        if _, ok := oauthTokens().Get(account.UID); !ok {
+            return nil, fmt.Errorf("未找到UID %d 的OAuth令牌", account.UID)
+        }
+        return &services.RefreshingAuth{
+            UID:           account.UID,
+            Store:         oauthTokens(),
+            OAuth:         newOAuthService(),
+            RefreshBefore: config.GlobalConfig.TokenRefreshBefore,
+            Wrap:          TokenAuthenticator,
+        }, nil
+    case services.SessionKindCookie:
+        cred, ok := webCredentials().Get(account.UID)
+        if !ok {
+            return nil, fmt.Errorf("未找到UID %d 的网页凭证", account.UID)
+        }
+        return &services.CookieAuth{Credential: cred}, nil
+    }
+    return nil, fmt.Errorf("未知的账号类型: %s", account.Kind)
+}
+
+// TokenAuthenticator 为访问令牌创建认证方式，配置了appkey时使用App签名
+func TokenAuthenticator(accessToken string) services.Authenticator {
+    return services.NewTokenAuthenticator(
+        accessToken,
+        config.GlobalConfig.BilibiliAppKey,
+        config.GlobalConfig.BilibiliAppSecret,
+    )
+}
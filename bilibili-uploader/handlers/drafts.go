@@ -0,0 +1,283 @@
+// handlers/drafts.go - 两阶段投稿：先上传为草稿，再单独提交
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "context"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "path/filepath"
+    "strings"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    draftStoreOnce sync.Once
+    draftStore     *services.DraftStore
+)
+
+// drafts 已上传未提交的草稿
+func drafts() *services.DraftStore {
+    draftStoreOnce.Do(func() {
+        draftStore = services.NewDraftStore(
+            filepath.Join(config.GlobalConfig.DataDir, "drafts.json"),
+            config.GlobalConfig.DraftTTL,
+        )
+    })
+    return draftStore
+}
+
+// DraftHandler 草稿处理器
+type DraftHandler struct{}
+
+// NewDraftHandler 创建草稿处理器
+func NewDraftHandler() *DraftHandler {
+    return &DraftHandler{}
+}
+
+// CreateDraft 预上传并分片上传视频，保存服务端文件名和投稿信息为草稿（不提交稿件）
+func (h *DraftHandler) CreateDraft(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    account, err := AccountForRequest(c, c.PostForm("account_id"))
+    if err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    if !RequireBilibili(c, account) {
+        return
+    }
+
+    release, ok := AcquireUpload(c, account, uploadSize(c))
+    if !ok {
+        return
+    }
+    defer release()
+
+    params, header, tempFile, ok := prepareUpload(c, account)
+    if !ok {
+        return
+    }
+    defer ReleaseTempFile(tempFile)
+
+    uploader, err := uploaderFor(account)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "success": false,
+            "message": "获取B站认证失败",
+        })
+        return
+    }
+    uploadInfo, err := uploader.UploadFileContext(c.Request.Context(), tempFile)
+    if err != nil {
+        RespondBilibiliError(c, "上传失败", err, nil)
+        return
+    }
+
+    draft, err := drafts().Create(&services.Draft{
+        UserID:    identity.OwnerID,
+        AccountID: account.ID,
+        Filename:  uploadInfo.Filename,
+        File:      header.Filename,
+        Params:    *params,
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "保存草稿失败",
+        })
+        return
+    }
+
+    slog.InfoContext(c.Request.Context(), "草稿已保存", "draft_id", draft.ID, "expires_at", draft.ExpiresAt)
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "视频已上传为草稿",
+        "draft":   draft,
+    })
+}
+
+// ListDrafts 列出当前用户未过期的草稿
+func (h *DraftHandler) ListDrafts(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "drafts":  drafts().List(identity.OwnerID),
+    })
+}
+
+// GetDraft 草稿详情
+func (h *DraftHandler) GetDraft(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    draft, err := drafts().Get(identity.OwnerID, c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "draft":   draft,
+    })
+}
+
+// UpdateDraft 修改草稿的投稿信息
+func (h *DraftHandler) UpdateDraft(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    var params services.VideoUploadParams
+    if err := c.ShouldBindJSON(&params); err != nil || strings.TrimSpace(params.Title) == "" || params.Category < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    if params.Copyright == 0 {
+        params.Copyright = 1 // 自制
+    }
+
+    draft, err := drafts().Update(identity.OwnerID, c.Param("id"), params)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "draft":   draft,
+    })
+}
+
+// DeleteDraft 删除草稿
+func (h *DraftHandler) DeleteDraft(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    if err := drafts().Delete(identity.OwnerID, c.Param("id")); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "草稿已删除",
+    })
+}
+
+// PublishDraft 提交草稿（submitVideo），失败时草稿保留，可修改后重试
+func (h *DraftHandler) PublishDraft(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    draft, err := drafts().Get(identity.OwnerID, c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    // 草稿账号需仍关联到当前用户，加入工作区的账号还需要发布者角色
+    account, err := AccountForRequest(c, draft.AccountID)
+    if err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    if err := RequirePublisher(c, account); err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    draft, err = drafts().BeginPublish(identity.OwnerID, draft.ID)
+    if err != nil {
+        c.JSON(http.StatusConflict, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    bvid, submitErr := submitDraft(c.Request.Context(), account, draft)
+    draft, err = drafts().Finish(draft.ID, bvid, submitErr)
+    if err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存草稿状态失败", "draft_id", draft.ID, "error", err)
+    }
+    if submitErr != nil {
+        RespondBilibiliError(c, "提交稿件失败", submitErr, gin.H{"draft": draft})
+        return
+    }
+
+    RecordUpload(c, account, services.HistoryRecord{
+        BVID:     bvid,
+        Title:    draft.Params.Title,
+        File:     draft.File,
+        Category: draft.Params.Category,
+    })
+
+    slog.InfoContext(c.Request.Context(), "草稿已提交", "draft_id", draft.ID, "bvid", bvid)
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "稿件已提交",
+        "bvid":    bvid,
+        "url":     fmt.Sprintf("https://www.bilibili.com/video/%s", bvid),
+        "draft":   draft,
+    })
+}
+
+// submitDraft 用草稿账号的凭证提交稿件
+func submitDraft(ctx context.Context, account *services.LinkedAccount, draft *services.Draft) (string, error) {
+    uploader, err := uploaderFor(account)
+    if err != nil {
+        return "", err
+    }
+    return uploader.SubmitContext(ctx, draft.Filename, draft.Params)
+}
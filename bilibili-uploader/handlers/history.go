@@ -0,0 +1,70 @@
+// handlers/history.go - 用户的投稿历史和后台任务
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "log/slog"
+    "net/http"
+    "path/filepath"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    historyStoreOnce sync.Once
+    historyStore     *services.HistoryStore
+
+    jobStoreOnce sync.Once
+    jobStore     *services.JobStore
+)
+
+// uploadHistory 网页投稿的历史记录
+func uploadHistory() *services.HistoryStore {
+    historyStoreOnce.Do(func() {
+        historyStore = services.NewHistoryStore(
+            filepath.Join(config.GlobalConfig.DataDir, "history.json"),
+        )
+    })
+    return historyStore
+}
+
+// Jobs 后台任务存储（监控目录投稿与接口共用）
+func Jobs() *services.JobStore {
+    jobStoreOnce.Do(func() {
+        jobStore = services.NewJobStore(
+            filepath.Join(config.GlobalConfig.DataDir, "jobs.json"),
+        )
+    })
+    return jobStore
+}
+
+// RecordUpload 记录当前用户用关联账号完成的投稿
+func RecordUpload(c *gin.Context, account *services.LinkedAccount, record services.HistoryRecord) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        return
+    }
+    record.UserID = identity.OwnerID
+    if account != nil {
+        record.AccountID = account.ID
+    }
+    if err := uploadHistory().Add(record); err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存投稿记录失败", "bvid", record.BVID, "error", err)
+    }
+}
+
+// ListJobs 列出当前用户的后台任务
+func (h *UploadHandler) ListJobs(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "data":    Jobs().ListForUser(identity.OwnerID),
+    })
+}
@@ -0,0 +1,82 @@
+// handlers/logging.go - 请求日志：request_id、访问日志和panic恢复
+package handlers
+
+import (
+    "bilibili-uploader/logging"
+    "bilibili-uploader/services"
+    "io"
+    "log/slog"
+    "net/http"
+    "regexp"
+    "runtime/debug"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID 接受调用方传入的请求ID的格式，避免把任意内容写入日志
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// RequestLogger 为每个请求分配request_id（沿用合法的X-Request-ID），写入请求上下文和响应头，
+// 请求结束后记录一条访问日志和请求指标；认证和选择账号后user_id、account_id也会写入上下文
+func RequestLogger() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+
+        requestID := c.GetHeader(RequestIDHeader)
+        if !validRequestID.MatchString(requestID) {
+            requestID, _ = services.RandomID(8)
+        }
+        c.Header(RequestIDHeader, requestID)
+        setLogAttrs(c, "request_id", requestID)
+
+        c.Next()
+
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+        status := c.Writer.Status()
+        duration := time.Since(start)
+        services.ObserveHTTPRequest(c.Request.Method, route, status, duration)
+        
+        level := slog.LevelInfo
+        switch {
+        case status >= 500:
+            level = slog.LevelError
+        case status >= 400:
+            level = slog.LevelWarn
+        }
+
+        // 只记录路径，查询参数中可能有授权码和state
+        slog.Log(c.Request.Context(), level, "HTTP请求",
+            "method", c.Request.Method,
+            "route", route,
+            "path", c.Request.URL.Path,
+            "status", status,
+            "duration_ms", duration.Milliseconds(),
+            "bytes", c.Writer.Size(),
+            "client_ip", c.ClientIP(),
+        )
+    }
+}
+
+// Recovery 捕获处理器中的panic，记录带request_id的错误日志并返回500
+func Recovery() gin.HandlerFunc {
+    return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+        slog.ErrorContext(c.Request.Context(), "处理请求时发生panic", "panic", recovered, "stack", string(debug.Stack()))
+        c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "服务器内部错误",
+        })
+    })
+}
+
+// setLogAttrs 在请求上下文中追加日志关联字段，并记录到请求的span上
+func setLogAttrs(c *gin.Context, args ...any) {
+    setSpanAttrs(c, args...)
+    c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+}
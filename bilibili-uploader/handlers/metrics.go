@@ -0,0 +1,29 @@
+// handlers/metrics.go - Prometheus指标接口
+package handlers
+
+import (
+    "bilibili-uploader/services"
+    "crypto/subtle"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+// MetricsHandler 输出Prometheus指标，token不为空时要求 Authorization: Bearer <token>
+func MetricsHandler(token string) gin.HandlerFunc {
+    handler := services.MetricsHandler()
+    return func(c *gin.Context) {
+        if token != "" {
+            given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+            if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+                c.JSON(http.StatusUnauthorized, gin.H{
+                    "success": false,
+                    "message": "需要有效的监控令牌",
+                })
+                return
+            }
+        }
+        handler.ServeHTTP(c.Writer, c.Request)
+    }
+}
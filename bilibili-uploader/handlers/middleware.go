@@ -0,0 +1,181 @@
+// handlers/middleware.go - 认证中间件
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "errors"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v4"
+)
+
+// identityKey 认证身份在gin上下文中的键
+const identityKey = "identity"
+
+// 认证失败的错误码
+const (
+    AuthErrMissingToken   = "missing_token"
+    AuthErrInvalidToken   = "invalid_token"
+    AuthErrTokenExpired   = "token_expired"
+    AuthErrSessionRevoked = "session_revoked"
+    AuthErrTokenRevoked   = "token_revoked"
+
+    AuthErrInsufficientScope = "insufficient_scope" // API密钥没有所需权限（403）
+    AuthErrSessionRequired   = "session_required"   // 接口只能通过登录会话访问（403）
+)
+
+// DevUID 模拟模式开发者身份的UID，不对应任何B站账号
+const DevUID int64 = -1
+
+// Identity 已认证的请求身份，由AuthMiddleware写入上下文
+type Identity struct {
+    SessionID string    `json:"session_id"`
+    TokenID   string    `json:"token_id"` // JWT的jti
+    OwnerID   string    `json:"owner_id"`
+    AccountID string    `json:"account_id"` // 登录使用的关联账号，请求未指定account_id时使用
+    UID       int64     `json:"uid"`
+    Username  string    `json:"username"`
+    Kind      string    `json:"kind"`
+    ExpiresAt time.Time `json:"expires_at"`
+
+    // 使用API密钥访问时设置
+    APIKeyID    string   `json:"api_key_id,omitempty"`
+    WorkspaceID string   `json:"workspace_id,omitempty"` // 密钥限定的工作区
+    Scopes      []string `json:"scopes,omitempty"`
+}
+
+// Dev 是否为模拟模式的开发者身份
+func (i *Identity) Dev() bool {
+    return i.Kind == services.SessionKindDev
+}
+
+// APIKey 是否通过API密钥访问
+func (i *Identity) APIKey() bool {
+    return i.APIKeyID != ""
+}
+
+// AuthMiddleware 校验JWT签名、有效期和服务端会话状态（或API密钥），检查每分钟请求数，并将身份写入上下文
+func AuthMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        identity, code, message := authenticate(c)
+        if identity == nil {
+            abortAuth(c, code, message)
+            return
+        }
+
+        // 认证通过后按用户（和API密钥的工作区）限流
+        if !allowRequest(c, identity) {
+            return
+        }
+
+        c.Set(identityKey, identity)
+        setLogAttrs(c, "user_id", identity.OwnerID)
+        if identity.APIKeyID != "" {
+            setLogAttrs(c, "api_key_id", identity.APIKeyID)
+        }
+        c.Next()
+    }
+}
+
+// authenticate 解析请求中的JWT，失败时返回错误码和提示
+func authenticate(c *gin.Context) (identity *Identity, code, message string) {
+    tokenString := tokenFromRequest(c)
+    if tokenString == "" {
+        return nil, AuthErrMissingToken, "请先登录B站账号"
+    }
+    if strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+        return authenticateAPIKey(tokenString)
+    }
+
+    claims, err := validateJWT(tokenString)
+    if err != nil {
+        if errors.Is(err, jwt.ErrTokenExpired) {
+            return nil, AuthErrTokenExpired, "登录已过期，请重新登录"
+        }
+        return nil, AuthErrInvalidToken, "令牌无效"
+    }
+
+    if revokedTokens().IsRevoked(claims.ID) {
+        return nil, AuthErrTokenRevoked, "令牌已注销，请重新登录"
+    }
+
+    // 会话被注销或过期时JWT随之失效；早期会话没有用户ID，需要重新登录
+    session, err := sessions().Get(claims.SessionID)
+    if err != nil || session.OwnerID == "" {
+        return nil, AuthErrSessionRevoked, "会话已失效，请重新登录"
+    }
+
+    return &Identity{
+        SessionID: session.ID,
+        TokenID:   claims.ID,
+        OwnerID:   session.OwnerID,
+        AccountID: session.AccountID,
+        UID:       session.UID,
+        Username:  session.Username,
+        Kind:      session.Kind,
+        ExpiresAt: session.ExpiresAt,
+    }, "", ""
+}
+
+// CurrentIdentity 读取AuthMiddleware写入的身份
+func CurrentIdentity(c *gin.Context) (*Identity, bool) {
+    value, ok := c.Get(identityKey)
+    if !ok {
+        return nil, false
+    }
+    identity, ok := value.(*Identity)
+    return identity, ok
+}
+
+// abortAuth 以统一格式返回401
+func abortAuth(c *gin.Context, code, message string) {
+    c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+        "success": false,
+        "code":    code,
+        "message": message,
+    })
+}
+
+// tokenFromRequest 读取请求中的JWT或API密钥（Authorization头优先，其次为X-API-Key头和会话Cookie）
+func tokenFromRequest(c *gin.Context) string {
+    if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+        return strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+    }
+    if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+        return strings.TrimSpace(apiKey)
+    }
+    if cookie, err := c.Cookie(sessionCookieName); err == nil {
+        return cookie
+    }
+    return ""
+}
+
+// DevLogin 模拟模式下签发开发者身份的会话，配置B站OAuth后不可用
+func (h *AuthHandler) DevLogin(c *gin.Context) {
+    if config.IsBilibiliConfigured() {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": "已配置B站OAuth，请使用真实账号登录",
+        })
+        return
+    }
+
+    devUser := &services.UserInfo{UID: DevUID, Username: "开发者（模拟）"}
+    jwtToken, err := issueSession(c, devUser, services.SessionKindDev, defaultJWTTTL)
+    if err != nil {
+        respondSessionError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":      true,
+        "access_token": jwtToken,
+        "username":     devUser.Username,
+        "uid":          devUser.UID,
+        "mode":         "simulation",
+    })
+}
@@ -0,0 +1,185 @@
+// handlers/qrcode.go - 扫码登录处理
+package handlers
+
+import (
+    "bilibili-uploader/services"
+    "encoding/base64"
+    "log/slog"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// qrCodeTTL B站登录二维码有效期
+const qrCodeTTL = 180 * time.Second
+
+// pendingQRCode 等待扫码的二维码
+type pendingQRCode struct {
+    url       string
+    createdAt time.Time
+}
+
+// QRLoginHandler 扫码登录处理器
+type QRLoginHandler struct {
+    qrLogin    *services.QRLogin
+    apiBaseURL string // 校验Cookie使用的nav接口地址
+
+    mu      sync.Mutex
+    pending map[string]*pendingQRCode
+}
+
+// NewQRLoginHandler 创建扫码登录处理器
+func NewQRLoginHandler() *QRLoginHandler {
+    return &QRLoginHandler{
+        qrLogin:    services.NewQRLogin(),
+        apiBaseURL: services.DefaultBilibiliEndpoints().API,
+        pending:    make(map[string]*pendingQRCode),
+    }
+}
+
+// CreateQRCode 申请登录二维码
+func (h *QRLoginHandler) CreateQRCode(c *gin.Context) {
+    qr, err := h.qrLogin.Generate(c.Request.Context())
+    if err != nil {
+        RespondBilibiliError(c, "申请登录二维码失败", err, nil)
+        return
+    }
+
+    png, err := services.RenderQRCodePNG(qr.URL, 256)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成二维码图片失败",
+        })
+        return
+    }
+
+    h.mu.Lock()
+    h.cleanupExpired()
+    h.pending[qr.Key] = &pendingQRCode{url: qr.URL, createdAt: time.Now()}
+    h.mu.Unlock()
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "qrcode_key": qr.Key,
+        "url":        qr.URL,
+        "image":      "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+        "expires_in": int(qrCodeTTL.Seconds()),
+    })
+}
+
+// GetQRCode 获取二维码图片（?format=text 返回终端可显示的文本）
+func (h *QRLoginHandler) GetQRCode(c *gin.Context) {
+    h.mu.Lock()
+    qr, ok := h.pending[c.Param("key")]
+    h.mu.Unlock()
+
+    if !ok || time.Since(qr.createdAt) > qrCodeTTL {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "二维码不存在或已过期",
+        })
+        return
+    }
+
+    if c.Query("format") == "text" {
+        text, err := services.RenderQRCodeText(qr.url)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "success": false,
+                "message": "生成二维码失败",
+            })
+            return
+        }
+        c.String(http.StatusOK, text)
+        return
+    }
+
+    png, err := services.RenderQRCodePNG(qr.url, 256)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成二维码图片失败",
+        })
+        return
+    }
+    c.Data(http.StatusOK, "image/png", png)
+}
+
+// PollQRCode 查询扫码状态，确认后保存Cookie并签发令牌
+func (h *QRLoginHandler) PollQRCode(c *gin.Context) {
+    key := c.Param("key")
+
+    h.mu.Lock()
+    _, ok := h.pending[key]
+    h.mu.Unlock()
+
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "二维码不存在或已过期",
+        })
+        return
+    }
+
+    result, err := h.qrLogin.Poll(c.Request.Context(), key)
+    if err != nil {
+        RespondBilibiliError(c, "查询扫码状态失败", err, nil)
+        return
+    }
+
+    if result.Status != services.QRStatusConfirmed {
+        if result.Status == services.QRStatusExpired {
+            h.mu.Lock()
+            delete(h.pending, key)
+            h.mu.Unlock()
+        }
+        c.JSON(http.StatusOK, gin.H{
+            "success": true,
+            "status":  result.Status,
+            "message": result.Message,
+        })
+        return
+    }
+
+    h.mu.Lock()
+    delete(h.pending, key)
+    h.mu.Unlock()
+
+    cred := services.NewWebCredential(result.Cookies)
+    cred.RefreshToken = result.RefreshToken
+    userInfo, err := saveWebCredential(c.Request.Context(), h.apiBaseURL, cred)
+    if err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存扫码登录凭证失败", "error", err)
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "保存登录凭证失败",
+        })
+        return
+    }
+
+    jwtToken, err := issueSession(c, userInfo, services.SessionKindCookie, defaultJWTTTL)
+    if err != nil {
+        respondSessionError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":      true,
+        "status":       result.Status,
+        "access_token": jwtToken,
+        "username":     userInfo.Username,
+        "uid":          userInfo.UID,
+    })
+}
+
+// cleanupExpired 清理过期二维码（调用方需持有锁）
+func (h *QRLoginHandler) cleanupExpired() {
+    for key, qr := range h.pending {
+        if time.Since(qr.createdAt) > qrCodeTTL {
+            delete(h.pending, key)
+        }
+    }
+}
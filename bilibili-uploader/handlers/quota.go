@@ -0,0 +1,216 @@
+// handlers/quota.go - 限流和上传配额
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "log/slog"
+    "math"
+    "net/http"
+    "path/filepath"
+    "strconv"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+// 超出限制时的错误码（429）
+const (
+    QuotaErrRateLimited   = "rate_limited"   // 每分钟请求数超限
+    QuotaErrQuotaExceeded = "quota_exceeded" // 并发任务或每日上传配额超限
+)
+
+var (
+    quotaTrackerOnce sync.Once
+    quotaTracker     *services.QuotaTracker
+)
+
+// quotas 用户、工作区和账号的用量记录
+func quotas() *services.QuotaTracker {
+    quotaTrackerOnce.Do(func() {
+        cfg := config.GlobalConfig
+        userDefault := services.QuotaLimits{
+            RequestsPerMinute: cfg.RateLimitPerMinute,
+            ConcurrentJobs:    cfg.QuotaConcurrentJobs,
+            DailyUploads:      cfg.QuotaDailyUploads,
+            DailyBytes:        cfg.QuotaDailyBytes,
+        }
+        policy, err := services.LoadQuotaPolicy(cfg.QuotaFile, userDefault)
+        if err != nil {
+            slog.Warn("加载配额配置失败，只使用默认配额", "error", err)
+            policy, _ = services.LoadQuotaPolicy("", userDefault)
+        }
+        quotaTracker = services.NewQuotaTracker(filepath.Join(cfg.DataDir, "quota_usage.json"), policy)
+    })
+    return quotaTracker
+}
+
+// requestSubjects 请求身份对应的配额主体：用户，以及API密钥所属的工作区
+func requestSubjects(identity *Identity) []services.QuotaSubject {
+    subjects := []services.QuotaSubject{{Kind: services.QuotaUser, ID: identity.OwnerID}}
+    if identity.WorkspaceID != "" {
+        subjects = append(subjects, services.QuotaSubject{Kind: services.QuotaWorkspace, ID: identity.WorkspaceID})
+    }
+    return subjects
+}
+
+// allowRequest 检查用户（和API密钥所属工作区）的每分钟请求数，超限时已写入429
+func allowRequest(c *gin.Context, identity *Identity) bool {
+    if err := quotas().AllowRequest(requestSubjects(identity)...); err != nil {
+        abortQuota(c, QuotaErrRateLimited, err)
+        return false
+    }
+    return true
+}
+
+// LimitUploadBody 上传接口在解析表单之前按Content-Length检查用户的上传配额，
+// 并把请求体限制在今日剩余流量之内，避免超额的请求体被完整读取到内存或临时文件
+// 账号和工作区的配额需要表单中的account_id，由处理函数中的AcquireUpload检查
+func LimitUploadBody() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        identity, ok := CurrentIdentity(c)
+        if !ok {
+            abortAuth(c, AuthErrMissingToken, "请先登录")
+            return
+        }
+
+        subjects := requestSubjects(identity)
+        remaining := quotas().RemainingBytes(subjects...)
+        size := c.Request.ContentLength
+        if size < 0 {
+            if remaining >= 0 {
+                c.AbortWithStatusJSON(http.StatusLengthRequired, gin.H{
+                    "success": false,
+                    "message": "上传请求需要Content-Length",
+                })
+                return
+            }
+            size = 0
+        }
+        if err := quotas().CheckUpload(size, subjects...); err != nil {
+            abortQuota(c, QuotaErrQuotaExceeded, err)
+            return
+        }
+        if remaining >= 0 {
+            c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, remaining)
+        }
+        c.Next()
+    }
+}
+
+// AcquireUpload 检查并占用用户、账号和账号所在工作区的上传配额，超限时已写入429
+// 每日用量按接收的上传计算，返回的release在上传结束后调用
+func AcquireUpload(c *gin.Context, account *services.LinkedAccount, size int64) (func(), bool) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return nil, false
+    }
+
+    setLogAttrs(c, "account_id", account.ID)
+
+    // 账号的每分钟请求数只统计实际调用B站的上传
+    accountSubject := services.QuotaSubject{Kind: services.QuotaAccount, ID: account.ID}
+    if err := quotas().AllowRequest(accountSubject); err != nil {
+        abortQuota(c, QuotaErrRateLimited, err)
+        return nil, false
+    }
+
+    subjects := []services.QuotaSubject{
+        {Kind: services.QuotaUser, ID: identity.OwnerID},
+        accountSubject,
+    }
+    for _, workspace := range workspaces().WithAccount(account.ID) {
+        subjects = append(subjects, services.QuotaSubject{Kind: services.QuotaWorkspace, ID: workspace.ID})
+    }
+
+    release, err := quotas().AcquireUpload(size, subjects...)
+    if err != nil {
+        abortQuota(c, QuotaErrQuotaExceeded, err)
+        return nil, false
+    }
+    return release, true
+}
+
+// AcquireJob 为转码等任务占用用户和账号的并发名额，超限时已写入429
+func AcquireJob(c *gin.Context, account *services.LinkedAccount) (func(), bool) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return nil, false
+    }
+
+    subjects := append(requestSubjects(identity), services.QuotaSubject{Kind: services.QuotaAccount, ID: account.ID})
+    release, err := quotas().AcquireJob(subjects...)
+    if err != nil {
+        abortQuota(c, QuotaErrQuotaExceeded, err)
+        return nil, false
+    }
+    return release, true
+}
+
+// abortQuota 以统一格式返回429，Retry-After为秒数
+func abortQuota(c *gin.Context, code string, err *services.QuotaError) {
+    retryAfter := int(math.Ceil(err.RetryAfter.Seconds()))
+    if retryAfter < 1 {
+        retryAfter = 1
+    }
+    c.Header("Retry-After", strconv.Itoa(retryAfter))
+    c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+        "success":     false,
+        "code":        code,
+        "message":     err.Error(),
+        "retry_after": retryAfter,
+    })
+}
+
+// QuotaHandler 配额处理器
+type QuotaHandler struct{}
+
+// NewQuotaHandler 创建配额处理器
+func NewQuotaHandler() *QuotaHandler {
+    return &QuotaHandler{}
+}
+
+// GetQuota 当前用户、其关联账号和所在工作区的限制及用量
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    accountList := make([]gin.H, 0)
+    for _, account := range accounts().List(identity.OwnerID) {
+        item := quotaView(services.QuotaAccount, account.ID)
+        item["username"] = account.Username
+        accountList = append(accountList, item)
+    }
+
+    workspaceList := make([]gin.H, 0)
+    for _, workspace := range workspaces().ListForUser(identity.OwnerID) {
+        if identity.WorkspaceID != "" && identity.WorkspaceID != workspace.ID {
+            continue
+        }
+        item := quotaView(services.QuotaWorkspace, workspace.ID)
+        item["name"] = workspace.Name
+        workspaceList = append(workspaceList, item)
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "user":       quotaView(services.QuotaUser, identity.OwnerID),
+        "accounts":   accountList,
+        "workspaces": workspaceList,
+    })
+}
+
+// quotaView 主体的限制和用量
+func quotaView(kind, id string) gin.H {
+    subject := services.QuotaSubject{Kind: kind, ID: id}
+    return gin.H{
+        "id":     id,
+        "limits": quotas().Policy().Limits(kind, id),
+        "usage":  quotas().Usage(subject),
+    }
+}
@@ -0,0 +1,312 @@
+// handlers/session.go - 登录会话
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "context"
+    "errors"
+    "log/slog"
+    "net/http"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// sessionCookieName 保存会话令牌的HttpOnly Cookie
+const sessionCookieName = "bilibili_session"
+
+var (
+    sessionStoreOnce sync.Once
+    sessionStore     *services.SessionStore
+
+    revocationListOnce sync.Once
+    revocationList     *services.RevocationList
+)
+
+// sessions 服务端会话存储
+func sessions() *services.SessionStore {
+    sessionStoreOnce.Do(func() {
+        sessionStore = services.NewSessionStore(
+            filepath.Join(config.GlobalConfig.DataDir, "sessions.json"),
+        )
+    })
+    return sessionStore
+}
+
+// revokedTokens 已吊销的JWT（按jti）
+func revokedTokens() *services.RevocationList {
+    revocationListOnce.Do(func() {
+        revocationList = services.NewRevocationList(
+            filepath.Join(config.GlobalConfig.DataDir, "revoked_tokens.json"),
+        )
+    })
+    return revocationList
+}
+
+// errAPIKeySession API密钥不能关联B站账号或换取浏览器会话
+var errAPIKeySession = errors.New("API密钥不能用于登录或关联B站账号，请使用登录会话")
+
+// issueSession B站账号登录：关联账号并签发会话
+// 已登录时把新账号关联到当前用户；否则登录以该B站账号为身份的用户（没有时自动创建），
+// 不会登录到只是关联了该账号的其他用户，共用的频道账号需要先登录本地账号再关联
+// 只有登录会话可以关联账号，携带API密钥时返回errAPIKeySession
+func issueSession(c *gin.Context, userInfo *services.UserInfo, kind string, ttl time.Duration) (string, error) {
+    if strings.HasPrefix(tokenFromRequest(c), services.APIKeyPrefix) {
+        return "", errAPIKeySession
+    }
+
+    ownerID := ""
+    if current, _, _ := authenticate(c); current != nil && !current.Dev() {
+        ownerID = current.OwnerID
+    } else {
+        user, err := users().LoginBilibili(userInfo.UID, userInfo.Username)
+        if err != nil {
+            return "", err
+        }
+        ownerID = user.ID
+    }
+
+    account, err := accounts().Link(ownerID, userInfo.UID, userInfo.Username, kind)
+    if err != nil {
+        return "", err
+    }
+
+    return createSession(c, &services.Session{
+        OwnerID:   ownerID,
+        AccountID: account.ID,
+        UID:       userInfo.UID,
+        Username:  userInfo.Username,
+        Kind:      kind,
+    }, ttl)
+}
+
+// respondSessionError 签发会话失败时的响应，携带API密钥时返回403
+func respondSessionError(c *gin.Context, err error) {
+    if errors.Is(err, errAPIKeySession) {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "code":    AuthErrSessionRequired,
+            "message": err.Error(),
+        })
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{
+        "success": false,
+        "message": "生成令牌失败",
+    })
+}
+
+// issueUserSession 本服务用户登录：签发不绑定B站账号的会话
+func issueUserSession(c *gin.Context, user *services.User) (string, error) {
+    return createSession(c, &services.Session{
+        OwnerID:  user.ID,
+        Username: user.Name(),
+        Kind:     services.SessionKindUser,
+    }, defaultJWTTTL)
+}
+
+// createSession 创建会话并签发只含会话ID的JWT，同时写入HttpOnly Cookie
+func createSession(c *gin.Context, session *services.Session, ttl time.Duration) (string, error) {
+    tokenID, err := services.RandomID(16)
+    if err != nil {
+        return "", err
+    }
+
+    session.TokenID = tokenID
+    session.UserAgent = c.Request.UserAgent()
+    session.IP = c.ClientIP()
+    created, err := sessions().Create(session, ttl)
+    if err != nil {
+        return "", err
+    }
+
+    jwtToken, err := generateJWT(created)
+    if err != nil {
+        return "", err
+    }
+
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(sessionCookieName, jwtToken, int(ttl.Seconds()), "/", "", c.Request.TLS != nil, true)
+    return jwtToken, nil
+}
+
+// Logout 注销当前会话；all为true时注销该用户的全部会话并解除全部账号关联，
+// 解除关联后无人使用的B站账号同时在B站注销令牌/Cookie并删除服务端凭证
+func (h *AuthHandler) Logout(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
+        return
+    }
+
+    var req struct {
+        All bool `json:"all"`
+    }
+    c.ShouldBindJSON(&req)
+
+    revoked := 0
+    if req.All {
+        for _, session := range sessions().List(identity.OwnerID) {
+            if err := revokeSession(&session); err != nil {
+                slog.ErrorContext(c.Request.Context(), "注销会话失败", "session_id", session.ID, "error", err)
+                continue
+            }
+            revoked++
+        }
+    } else {
+        if err := revokedTokens().Revoke(identity.TokenID, identity.ExpiresAt); err != nil {
+            slog.ErrorContext(c.Request.Context(), "吊销令牌失败", "error", err)
+        }
+        if err := sessions().Revoke(identity.SessionID); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "success": false,
+                "message": "注销会话失败",
+            })
+            return
+        }
+        revoked = 1
+    }
+
+    bilibiliRevoked := 0
+    if req.All {
+        for _, account := range accounts().List(identity.OwnerID) {
+            if _, err := accounts().Unlink(identity.OwnerID, account.ID); err != nil {
+                slog.ErrorContext(c.Request.Context(), "解除账号关联失败", "account_id", account.ID, "error", err)
+                continue
+            }
+            if revokeBilibiliIfUnused(c.Request.Context(), account.UID, account.Kind) {
+                bilibiliRevoked++
+            }
+        }
+    }
+
+    c.SetCookie(sessionCookieName, "", -1, "/", "", c.Request.TLS != nil, true)
+    c.JSON(http.StatusOK, gin.H{
+        "success":          true,
+        "message":          "已退出登录",
+        "revoked":          revoked,
+        "bilibili_revoked": bilibiliRevoked,
+    })
+}
+
+// ListSessions 列出当前用户的有效会话
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
+        return
+    }
+
+    list := make([]gin.H, 0)
+    for _, session := range sessions().List(identity.OwnerID) {
+        list = append(list, gin.H{
+            "id":           session.ID,
+            "kind":         session.Kind,
+            "user_agent":   session.UserAgent,
+            "ip":           session.IP,
+            "created_at":   session.CreatedAt,
+            "last_seen_at": session.LastSeenAt,
+            "expires_at":   session.ExpiresAt,
+            "current":      session.ID == identity.SessionID,
+        })
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":  true,
+        "sessions": list,
+    })
+}
+
+// RevokeSession 注销当前用户的指定会话（如其他设备上的登录）
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录B站账号")
+        return
+    }
+
+    id := c.Param("id")
+    var target *services.Session
+    for _, session := range sessions().List(identity.OwnerID) {
+        if session.ID == id {
+            copied := session
+            target = &copied
+            break
+        }
+    }
+    if target == nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "会话不存在或已失效",
+        })
+        return
+    }
+
+    if err := revokeSession(target); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "注销会话失败",
+        })
+        return
+    }
+
+    if target.ID == identity.SessionID {
+        c.SetCookie(sessionCookieName, "", -1, "/", "", c.Request.TLS != nil, true)
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "会话已注销",
+    })
+}
+
+// revokeSession 注销会话并吊销其JWT
+func revokeSession(session *services.Session) error {
+    if err := revokedTokens().Revoke(session.TokenID, session.ExpiresAt); err != nil {
+        return err
+    }
+    return sessions().Revoke(session.ID)
+}
+
+// revokeBilibiliIfUnused B站账号已无人关联且没有同类型的有效会话时，在B站注销凭证并删除服务端副本
+func revokeBilibiliIfUnused(ctx context.Context, uid int64, kind string) bool {
+    if accounts().IsLinked(uid, kind) {
+        return false
+    }
+    for _, session := range sessions().List("") {
+        if session.UID == uid && session.Kind == kind {
+            return false
+        }
+    }
+
+    switch kind {
+    case services.SessionKindOAuth:
+        token, ok := oauthTokens().Get(uid)
+        if !ok {
+            return false
+        }
+        if err := newOAuthService().RevokeToken(ctx, TokenAuthenticator(token.AccessToken)); err != nil {
+            slog.WarnContext(ctx, "在B站注销令牌失败", "bilibili_uid", uid, "error", err)
+        }
+        if err := oauthTokens().Delete(uid); err != nil {
+            slog.ErrorContext(ctx, "删除OAuth令牌失败", "bilibili_uid", uid, "error", err)
+        }
+        return true
+    case services.SessionKindCookie:
+        cred, ok := webCredentials().Get(uid)
+        if !ok {
+            return false
+        }
+        if err := services.LogoutWebCredential(ctx, services.DefaultBilibiliEndpoints().Passport, cred); err != nil {
+            slog.WarnContext(ctx, "在B站注销Cookie失败", "bilibili_uid", uid, "error", err)
+        }
+        if err := webCredentials().Delete(uid); err != nil {
+            slog.ErrorContext(ctx, "删除网页凭证失败", "bilibili_uid", uid, "error", err)
+        }
+        return true
+    }
+    return false
+}
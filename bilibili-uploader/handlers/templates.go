@@ -0,0 +1,123 @@
+// handlers/templates.go - 用户的投稿模板
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "fmt"
+    "net/http"
+    "path/filepath"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    templateStoreOnce sync.Once
+    templateStore     *services.TemplateStore
+)
+
+// templates 用户保存的投稿模板
+func templates() *services.TemplateStore {
+    templateStoreOnce.Do(func() {
+        templateStore = services.NewTemplateStore(
+            filepath.Join(config.GlobalConfig.DataDir, "templates.json"),
+        )
+    })
+    return templateStore
+}
+
+// TemplateForRequest 取出当前用户的投稿模板，templateID为空时返回nil
+func TemplateForRequest(c *gin.Context, templateID string) (*services.UploadTemplate, error) {
+    if templateID == "" {
+        return nil, nil
+    }
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        return nil, fmt.Errorf("请求未经过认证")
+    }
+    template, ok := templates().Get(identity.OwnerID, templateID)
+    if !ok {
+        return nil, fmt.Errorf("模板 %s 不存在", templateID)
+    }
+    return template, nil
+}
+
+// TemplateHandler 投稿模板处理器
+type TemplateHandler struct{}
+
+// NewTemplateHandler 创建投稿模板处理器
+func NewTemplateHandler() *TemplateHandler {
+    return &TemplateHandler{}
+}
+
+// ListTemplates 列出当前用户的模板
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":   true,
+        "templates": templates().List(identity.OwnerID),
+    })
+}
+
+// SaveTemplate 新建模板，路径带id时更新
+func (h *TemplateHandler) SaveTemplate(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    var template services.UploadTemplate
+    if err := c.ShouldBindJSON(&template); err != nil || template.Category < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+    template.ID = c.Param("id")
+    template.UserID = identity.OwnerID
+
+    saved, err := templates().Save(&template)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":  true,
+        "message":  "模板已保存",
+        "template": saved,
+    })
+}
+
+// DeleteTemplate 删除模板
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    if err := templates().Delete(identity.OwnerID, c.Param("id")); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "模板已删除",
+    })
+}
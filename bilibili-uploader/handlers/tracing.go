@@ -0,0 +1,66 @@
+// handlers/tracing.go - 链路追踪：为每个请求创建服务端span
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/propagation"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// tracer 处理器层的Tracer
+var tracer = otel.Tracer("bilibili-uploader/handlers")
+
+// Tracing 为每个请求创建服务端span，沿用请求头中的traceparent，span名为"方法 路由模板"
+// 需要放在RequestLogger之前，使访问日志和之后的业务日志带上trace_id
+func Tracing() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+
+        ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+        ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+            trace.WithSpanKind(trace.SpanKindServer),
+            trace.WithAttributes(
+                semconv.HTTPRequestMethodKey.String(c.Request.Method),
+                semconv.HTTPRoute(route),
+                semconv.URLPath(c.Request.URL.Path),
+                semconv.ClientAddress(c.ClientIP()),
+            ),
+        )
+        defer span.End()
+        c.Request = c.Request.WithContext(ctx)
+
+        c.Next()
+
+        status := c.Writer.Status()
+        span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+        if status >= http.StatusInternalServerError {
+            span.SetStatus(codes.Error, http.StatusText(status))
+        }
+        for _, err := range c.Errors {
+            span.RecordError(err.Err)
+        }
+    }
+}
+
+// setSpanAttrs 把日志关联字段（user_id、account_id等）同时记录到当前请求的span上
+func setSpanAttrs(c *gin.Context, args ...any) {
+    span := trace.SpanFromContext(c.Request.Context())
+    if !span.IsRecording() {
+        return
+    }
+    for i := 0; i+1 < len(args); i += 2 {
+        if key, ok := args[i].(string); ok {
+            span.SetAttributes(attribute.String(key, fmt.Sprint(args[i+1])))
+        }
+    }
+}
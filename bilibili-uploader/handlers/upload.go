@@ -6,7 +6,7 @@ import (
     "bilibili-uploader/services"
     "fmt"
     "io"
-    "log"
+    "log/slog"
     "net/http"
     "os"
     "path/filepath"
@@ -27,10 +27,10 @@ func NewUploadHandler() *UploadHandler {
     return &UploadHandler{}
 }
 
-// UploadToBilibili 上传视频到B站
+// UploadToBilibili 上传视频到B站，路由需在前面挂载LimitUploadBody以便在解析表单前检查配额
 func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
-    // 从context获取B站token
-    bilibiliToken, err := GetBilibiliToken(c)
+    // 按account_id选择关联账号的B站凭证
+    uploader, account, err := GetBilibiliUploader(c, c.PostForm("account_id"))
     if err != nil {
         c.JSON(http.StatusUnauthorized, gin.H{
             "success": false,
@@ -38,6 +38,13 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
         })
         return
     }
+    if err := RequirePublisher(c, account); err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
     
     // 解析multipart form
     err = c.Request.ParseMultipartForm(32 << 20) // 32MB
@@ -60,6 +67,23 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
     }
     defer file.Close()
     
+    // 检查上传配额（并发数、每日次数和流量）
+    release, ok := AcquireUpload(c, account, header.Size)
+    if !ok {
+        return
+    }
+    defer release()
+    
+    // 用户指定的投稿模板
+    template, err := TemplateForRequest(c, c.PostForm("template_id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    
     // 获取视频信息
     title := c.PostForm("title")
     description := c.PostForm("desc")
@@ -75,8 +99,8 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
         return
     }
     
-    // 解析分区ID
-    category := 21 // 默认日常分区
+    // 解析分区ID（未填写时使用账号默认分区）
+    category := 0
     if categoryStr != "" {
         if cat, err := strconv.Atoi(categoryStr); err == nil {
             category = cat
@@ -89,15 +113,18 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
         tagList = strings.Split(tags, " ")
     }
     
-    log.Printf("📤 开始上传到B站:")
-    log.Printf("   文件: %s (%.2f MB)", header.Filename, float64(header.Size)/(1024*1024))
-    log.Printf("   标题: %s", title)
-    log.Printf("   分区: %d", category)
-    log.Printf("   标签: %v", tagList)
+    ctx := c.Request.Context()
+    slog.InfoContext(ctx, "收到上传请求",
+        "file", header.Filename,
+        "size", header.Size,
+        "title_len", len([]rune(title)),
+        "tags", len(tagList),
+        "bilibili_uid", account.UID,
+    )
     
     // 保存到临时文件
     tempDir := config.GlobalConfig.TempDir
-    tempFile := filepath.Join(tempDir, fmt.Sprintf("upload_%d_%s", time.Now().Unix(), header.Filename))
+    tempFile := filepath.Join(tempDir, fmt.Sprintf("upload_%d_%s", time.Now().Unix(), filepath.Base(header.Filename)))
     
     dst, err := os.Create(tempFile)
     if err != nil {
@@ -108,11 +135,11 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
         return
     }
     defer dst.Close()
-    defer os.Remove(tempFile) // 上传完成后删除临时文件
     
     // 复制文件内容
     written, err := io.Copy(dst, file)
     if err != nil {
+        os.Remove(tempFile)
         c.JSON(http.StatusInternalServerError, gin.H{
             "success": false,
             "message": "保存文件失败",
@@ -120,7 +147,9 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
         return
     }
     
-    log.Printf("✅ 临时文件已保存: %s (%d bytes)", tempFile, written)
+    slog.DebugContext(ctx, "临时文件已保存", "path", tempFile, "bytes", written)
+    tempFile = ResumableTempFile(account.ID, tempFile)
+    defer ReleaseTempFile(tempFile) // 上传完成后删除临时文件，中断时保留
     
     // 创建上传参数
     uploadParams := services.VideoUploadParams{
@@ -128,35 +157,45 @@ func (h *UploadHandler) UploadToBilibili(c *gin.Context) {
         Description: description,
         Tags:        tagList,
         Category:    category,
-        Copyright:   1, // 自制
+    }
+    // 模板优先，其次为账号默认设置
+    if template != nil {
+        template.Apply(&uploadParams)
+    }
+    account.Defaults.Apply(&uploadParams)
+    if uploadParams.Copyright == 0 {
+        uploadParams.Copyright = 1 // 自制
+    }
+    if uploadParams.Category == 0 {
+        uploadParams.Category = 21 // 默认日常分区
     }
     
     // 使用进度回调上传
     progressChan := make(chan float64, 100)
     go func() {
         for progress := range progressChan {
-            log.Printf("📊 上传进度: %.2f%%", progress)
+            slog.DebugContext(ctx, "上传进度", "percent", progress)
             // 可以通过WebSocket发送给前端
         }
     }()
     
-    // 创建上传器并执行上传
-    uploader := services.NewBilibiliUploader(bilibiliToken)
-    
-    log.Println("🚀 开始执行B站API上传...")
-    bvid, err := uploader.UploadVideo(tempFile, uploadParams)
+    // 执行上传
+    slog.InfoContext(ctx, "开始上传到B站", "tid", uploadParams.Category)
+    bvid, err := uploader.UploadVideoContext(ctx, tempFile, uploadParams)
     close(progressChan)
     
     if err != nil {
-        log.Printf("❌ 上传失败: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "success": false,
-            "message": fmt.Sprintf("上传失败: %v", err),
-        })
+        RespondBilibiliError(c, "上传失败", err, nil)
         return
     }
     
-    log.Printf("🎉 上传成功! BV号: %s", bvid)
+    slog.InfoContext(ctx, "上传成功", "bvid", bvid)
+    RecordUpload(c, account, services.HistoryRecord{
+        BVID:     bvid,
+        Title:    title,
+        File:     header.Filename,
+        Category: uploadParams.Category,
+    })
     
     // 返回成功结果
     c.JSON(http.StatusOK, gin.H{
@@ -182,28 +221,17 @@ func (h *UploadHandler) UploadProgress(c *gin.Context) {
     })
 }
 
-// GetUploadHistory 获取上传历史
+// GetUploadHistory 获取当前用户的上传历史
 func (h *UploadHandler) GetUploadHistory(c *gin.Context) {
-    // 从数据库或缓存获取用户的上传历史
-    // 这里返回模拟数据
-    history := []gin.H{
-        {
-            "bvid": "BV1xx411c7mD",
-            "title": "测试视频1",
-            "upload_time": "2024-01-01 12:00:00",
-            "status": "published",
-        },
-        {
-            "bvid": "BV1xx411c7mE", 
-            "title": "测试视频2",
-            "upload_time": "2024-01-02 13:00:00",
-            "status": "reviewing",
-        },
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
     }
     
     c.JSON(http.StatusOK, gin.H{
         "success": true,
-        "data": history,
+        "data": uploadHistory().ListForUser(identity.OwnerID),
     })
 }
 
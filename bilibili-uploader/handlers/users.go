@@ -0,0 +1,352 @@
+// handlers/users.go - 本服务用户：注册、密码登录、OIDC登录
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "fmt"
+    "html/template"
+    "log/slog"
+    "net/http"
+    "path/filepath"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    userStoreOnce sync.Once
+    userStore     *services.UserStore
+
+    oidcProviderOnce sync.Once
+    oidcProvider     *services.OIDCProvider
+)
+
+// users 本服务的用户
+func users() *services.UserStore {
+    userStoreOnce.Do(func() {
+        userStore = services.NewUserStore(
+            filepath.Join(config.GlobalConfig.DataDir, "users.json"),
+        )
+    })
+    return userStore
+}
+
+// oidc 配置的OIDC提供方，未配置时返回nil
+func oidc() *services.OIDCProvider {
+    oidcProviderOnce.Do(func() {
+        if config.IsOIDCConfigured() {
+            oidcProvider = services.NewOIDCProvider(
+                config.GlobalConfig.OIDCIssuer,
+                config.GlobalConfig.OIDCClientID,
+                config.GlobalConfig.OIDCClientSecret,
+                config.GlobalConfig.OIDCRedirectURI,
+            )
+        }
+    })
+    return oidcProvider
+}
+
+// UserHandler 用户处理器
+type UserHandler struct{}
+
+// NewUserHandler 创建用户处理器
+func NewUserHandler() *UserHandler {
+    return &UserHandler{}
+}
+
+// credentialsRequest 用户名密码
+type credentialsRequest struct {
+    Username string `json:"username" binding:"required"`
+    Password string `json:"password" binding:"required"`
+}
+
+// Register 注册用户并直接登录
+func (h *UserHandler) Register(c *gin.Context) {
+    if !config.GlobalConfig.AllowRegistration {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": "未开放注册",
+        })
+        return
+    }
+
+    var req credentialsRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    user, err := users().Register(req.Username, req.Password)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    h.respondLogin(c, user)
+}
+
+// Login 用户名密码登录
+func (h *UserHandler) Login(c *gin.Context) {
+    var req credentialsRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    user, err := users().Authenticate(req.Username, req.Password)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    h.respondLogin(c, user)
+}
+
+// ChangePassword 设置或修改密码（通过B站或OIDC创建的用户可借此启用密码登录）
+// 已有密码时需要提供当前密码，修改后注销该用户的其他会话
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    var req struct {
+        CurrentPassword string `json:"current_password"`
+        Password        string `json:"password" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    if err := users().VerifyPassword(identity.OwnerID, req.CurrentPassword); err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    if err := users().SetPassword(identity.OwnerID, req.Password); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    revoked := 0
+    for _, session := range sessions().List(identity.OwnerID) {
+        if session.ID == identity.SessionID {
+            continue
+        }
+        if err := revokeSession(&session); err != nil {
+            slog.ErrorContext(c.Request.Context(), "注销会话失败", "session_id", session.ID, "error", err)
+            continue
+        }
+        revoked++
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "密码已更新",
+        "revoked": revoked,
+    })
+}
+
+// Me 当前用户及其关联的B站账号
+func (h *UserHandler) Me(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    user, ok := users().Get(identity.OwnerID)
+    if !ok {
+        abortAuth(c, AuthErrSessionRevoked, "用户不存在，请重新登录")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":  true,
+        "user":     userView(user),
+        "accounts": accounts().List(user.ID),
+        "session":  identity,
+    })
+}
+
+// OIDCLogin 跳转到OIDC提供方登录
+func (h *UserHandler) OIDCLogin(c *gin.Context) {
+    provider := oidc()
+    if provider == nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "未配置OIDC登录",
+        })
+        return
+    }
+
+    binding, err := services.RandomID(32)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成授权参数失败",
+        })
+        return
+    }
+
+    // 与B站授权共用一次性state存储，nonce写入ID Token防止重放
+    state, pkce, err := oauthStates().Issue(binding)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成授权参数失败",
+        })
+        return
+    }
+
+    authURL, err := provider.AuthURL(state.State, state.Nonce, pkce.Challenge)
+    if err != nil {
+        slog.ErrorContext(c.Request.Context(), "构建OIDC授权地址失败", "error", err)
+        c.JSON(http.StatusBadGateway, gin.H{
+            "success": false,
+            "message": "OIDC提供方不可用",
+        })
+        return
+    }
+
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(oauthBindingCookie, binding, int(services.DefaultOAuthStateTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+    c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback OIDC回调：校验ID Token后登录（不存在时创建用户）
+func (h *UserHandler) OIDCCallback(c *gin.Context) {
+    provider := oidc()
+    if provider == nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "未配置OIDC登录",
+        })
+        return
+    }
+
+    if errCode := c.Query("error"); errCode != "" {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": fmt.Sprintf("OIDC登录失败: %s", errCode),
+        })
+        return
+    }
+
+    issued, err := consumeOAuthState(c, c.Query("state"))
+    if err != nil {
+        slog.WarnContext(c.Request.Context(), "state验证失败", "error", err)
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "state验证失败",
+        })
+        return
+    }
+
+    claims, err := provider.Exchange(c.Query("code"), issued.CodeVerifier, issued.Nonce)
+    if err != nil {
+        slog.WarnContext(c.Request.Context(), "OIDC登录失败", "error", err)
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "success": false,
+            "message": "OIDC登录失败",
+        })
+        return
+    }
+
+    name := claims.PreferredUsername
+    if name == "" {
+        name = claims.Name
+    }
+    user, err := users().LoginOIDC(claims.Issuer, claims.Subject, name, claims.Email)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "创建用户失败",
+        })
+        return
+    }
+
+    if _, err := issueUserSession(c, user); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成令牌失败",
+        })
+        return
+    }
+
+    c.Header("Content-Type", "text/html; charset=utf-8")
+    oidcCallbackPage.Execute(c.Writer, gin.H{
+        "Name": user.Name(),
+    })
+}
+
+// respondLogin 签发用户会话并返回令牌
+func (h *UserHandler) respondLogin(c *gin.Context, user *services.User) {
+    jwtToken, err := issueUserSession(c, user)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "生成令牌失败",
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":      true,
+        "access_token": jwtToken,
+        "user":         userView(user),
+    })
+}
+
+// userView 返回给前端的用户信息（不含密码哈希）
+func userView(user *services.User) gin.H {
+    return gin.H{
+        "id":            user.ID,
+        "username":      user.Username,
+        "display_name":  user.Name(),
+        "email":         user.Email,
+        "has_password":  user.PasswordHash != "",
+        "oidc":          user.OIDCSubject != "",
+        "created_at":    user.CreatedAt,
+        "last_login_at": user.LastLoginAt,
+    }
+}
+
+// oidcCallbackPage OIDC登录成功页，与B站授权回调一样只通过HttpOnly Cookie下发会话令牌
+var oidcCallbackPage = template.Must(template.New("oidc").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>登录成功</title></head>
+<body style="font-family: Arial; text-align: center; padding-top: 20vh;">
+    <h1>✅ 登录成功</h1>
+    <p>欢迎，<strong>{{.Name}}</strong></p>
+    <a href="/">返回主页</a>
+    <script>
+        setTimeout(() => { window.location.href = '/'; }, 2000);
+    </script>
+</body>
+</html>
+`))
@@ -0,0 +1,626 @@
+// handlers/workspaces.go - 团队工作区、成员角色和稿件审核
+package handlers
+
+import (
+    "bilibili-uploader/config"
+    "bilibili-uploader/services"
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "mime/multipart"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+var (
+    workspaceStoreOnce sync.Once
+    workspaceStore     *services.WorkspaceStore
+
+    submissionStoreOnce sync.Once
+    submissionStore     *services.SubmissionStore
+)
+
+// workspaces 团队工作区
+func workspaces() *services.WorkspaceStore {
+    workspaceStoreOnce.Do(func() {
+        workspaceStore = services.NewWorkspaceStore(
+            filepath.Join(config.GlobalConfig.DataDir, "workspaces.json"),
+        )
+    })
+    return workspaceStore
+}
+
+// submissions 工作区的待审核稿件
+func submissions() *services.SubmissionStore {
+    submissionStoreOnce.Do(func() {
+        submissionStore = services.NewSubmissionStore(
+            filepath.Join(config.GlobalConfig.DataDir, "submissions.json"),
+        )
+    })
+    return submissionStore
+}
+
+// RequirePublisher 账号加入工作区后，只有这些工作区的发布者可以直接投稿，其他人需提交审核
+func RequirePublisher(c *gin.Context, account *services.LinkedAccount) error {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        return fmt.Errorf("请求未经过认证")
+    }
+    for _, workspace := range workspaces().WithAccount(account.ID) {
+        if !services.RoleAllows(workspace.RoleOf(identity.OwnerID), services.RolePublisher) {
+            return fmt.Errorf("账号 %s 属于工作区「%s」，需要发布者审核后才能投稿", account.Username, workspace.Name)
+        }
+    }
+    return nil
+}
+
+// WorkspaceHandler 工作区处理器
+type WorkspaceHandler struct{}
+
+// NewWorkspaceHandler 创建工作区处理器
+func NewWorkspaceHandler() *WorkspaceHandler {
+    return &WorkspaceHandler{}
+}
+
+// CreateWorkspace 创建工作区，创建者成为发布者
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    var req struct {
+        Name string `json:"name" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    workspace, err := workspaces().Create(req.Name, identity.OwnerID)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":   true,
+        "workspace": workspaceView(workspace, identity.OwnerID),
+    })
+}
+
+// ListWorkspaces 列出当前用户所在的工作区
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return
+    }
+
+    list := make([]gin.H, 0)
+    for _, workspace := range workspaces().ListForUser(identity.OwnerID) {
+        if identity.WorkspaceID != "" && identity.WorkspaceID != workspace.ID {
+            continue
+        }
+        list = append(list, workspaceView(workspace, identity.OwnerID))
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "workspaces": list,
+    })
+}
+
+// GetWorkspace 工作区详情
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+    workspace, identity, ok := workspaceForRequest(c, services.RoleViewer)
+    if !ok {
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":   true,
+        "workspace": workspaceView(workspace, identity.OwnerID),
+    })
+}
+
+// SetMember 添加成员或修改成员角色（发布者）
+func (h *WorkspaceHandler) SetMember(c *gin.Context) {
+    workspace, _, ok := workspaceForRequest(c, services.RolePublisher)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Username string `json:"username" binding:"required"`
+        Role     string `json:"role" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    user, ok := users().Lookup(req.Username)
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "用户不存在",
+        })
+        return
+    }
+
+    if err := workspaces().SetMember(workspace.ID, user.ID, req.Role); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "成员已更新",
+    })
+}
+
+// RemoveMember 移除成员（发布者）
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+    workspace, _, ok := workspaceForRequest(c, services.RolePublisher)
+    if !ok {
+        return
+    }
+
+    if err := workspaces().RemoveMember(workspace.ID, c.Param("user_id")); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "成员已移除",
+    })
+}
+
+// AddAccount 把自己关联的B站账号加入工作区（发布者）
+func (h *WorkspaceHandler) AddAccount(c *gin.Context) {
+    workspace, identity, ok := workspaceForRequest(c, services.RolePublisher)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        AccountID string `json:"account_id" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    if _, ok := accounts().Get(identity.OwnerID, req.AccountID); !ok {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": "只能加入自己关联的账号",
+        })
+        return
+    }
+
+    if err := workspaces().AddAccount(workspace.ID, req.AccountID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "账号已加入工作区",
+    })
+}
+
+// RemoveAccount 从工作区移除账号（发布者）
+func (h *WorkspaceHandler) RemoveAccount(c *gin.Context) {
+    workspace, _, ok := workspaceForRequest(c, services.RolePublisher)
+    if !ok {
+        return
+    }
+
+    if err := workspaces().RemoveAccount(workspace.ID, c.Param("account_id")); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "账号已移出工作区",
+    })
+}
+
+// UploadSubmission 上传视频并提交审核（编辑），只做预上传和分片上传，不提交稿件
+func (h *WorkspaceHandler) UploadSubmission(c *gin.Context) {
+    workspace, identity, ok := workspaceForRequest(c, services.RoleEditor)
+    if !ok {
+        return
+    }
+
+    accountID := c.PostForm("account_id")
+    account, ok := accounts().Find(accountID)
+    if !ok || !workspace.HasAccount(accountID) {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "账号不在工作区中",
+        })
+        return
+    }
+    if !RequireBilibili(c, account) {
+        return
+    }
+
+    release, ok := AcquireUpload(c, account, uploadSize(c))
+    if !ok {
+        return
+    }
+    defer release()
+
+    params, header, tempFile, ok := prepareUpload(c, account)
+    if !ok {
+        return
+    }
+    defer ReleaseTempFile(tempFile)
+
+    uploader, err := uploaderFor(account)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "success": false,
+            "message": "获取B站认证失败",
+        })
+        return
+    }
+    uploadInfo, err := uploader.UploadFileContext(c.Request.Context(), tempFile)
+    if err != nil {
+        RespondBilibiliError(c, "上传失败", err, nil)
+        return
+    }
+
+    submission, err := submissions().Create(&services.Submission{
+        WorkspaceID: workspace.ID,
+        AccountID:   account.ID,
+        SubmittedBy: identity.OwnerID,
+        Filename:    uploadInfo.Filename,
+        File:        header.Filename,
+        Params:      *params,
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "保存稿件失败",
+        })
+        return
+    }
+
+    slog.InfoContext(c.Request.Context(), "稿件已提交审核", "submission_id", submission.ID)
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "message":    "视频已上传，等待发布者审核",
+        "submission": submission,
+    })
+}
+
+// ListSubmissions 列出工作区的稿件（查看者），可按status过滤
+func (h *WorkspaceHandler) ListSubmissions(c *gin.Context) {
+    workspace, _, ok := workspaceForRequest(c, services.RoleViewer)
+    if !ok {
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":     true,
+        "submissions": submissions().List(workspace.ID, c.Query("status")),
+    })
+}
+
+// UpdateSubmission 修改待审核稿件的投稿信息（编辑）
+func (h *WorkspaceHandler) UpdateSubmission(c *gin.Context) {
+    workspace, _, ok := workspaceForRequest(c, services.RoleEditor)
+    if !ok {
+        return
+    }
+
+    var params services.VideoUploadParams
+    if err := c.ShouldBindJSON(&params); err != nil || strings.TrimSpace(params.Title) == "" {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "参数错误",
+        })
+        return
+    }
+
+    submission, err := submissions().UpdateParams(workspace.ID, c.Param("sid"), params)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "submission": submission,
+    })
+}
+
+// ApproveSubmission 审核通过并提交稿件（发布者）
+func (h *WorkspaceHandler) ApproveSubmission(c *gin.Context) {
+    h.review(c, services.ReviewApprove)
+}
+
+// RejectSubmission 驳回稿件（发布者）
+func (h *WorkspaceHandler) RejectSubmission(c *gin.Context) {
+    h.review(c, services.ReviewReject)
+}
+
+// review 记录审核意见，通过时调用submitVideo提交稿件
+func (h *WorkspaceHandler) review(c *gin.Context, decision string) {
+    workspace, identity, ok := workspaceForRequest(c, services.RolePublisher)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Comment string `json:"comment"`
+    }
+    c.ShouldBindJSON(&req)
+
+    submission, err := submissions().BeginReview(workspace.ID, c.Param("sid"), services.Review{
+        UserID:   identity.OwnerID,
+        Decision: decision,
+        Comment:  strings.TrimSpace(req.Comment),
+    })
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+
+    setLogAttrs(c, "submission_id", submission.ID, "account_id", submission.AccountID)
+    if decision == services.ReviewReject {
+        c.JSON(http.StatusOK, gin.H{
+            "success":    true,
+            "message":    "稿件已驳回",
+            "submission": submission,
+        })
+        return
+    }
+
+    bvid, submitErr := submitApproved(c.Request.Context(), workspace, submission)
+    submission, err = submissions().Finish(submission.ID, bvid, submitErr)
+    if err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存审核结果失败", "submission_id", submission.ID, "error", err)
+    }
+    if submitErr != nil {
+        RespondBilibiliError(c, "提交稿件失败", submitErr, gin.H{"submission": submission})
+        return
+    }
+
+    if err := uploadHistory().Add(services.HistoryRecord{
+        UserID:    submission.SubmittedBy,
+        AccountID: submission.AccountID,
+        BVID:      bvid,
+        Title:     submission.Params.Title,
+        File:      submission.File,
+        Category:  submission.Params.Category,
+    }); err != nil {
+        slog.ErrorContext(c.Request.Context(), "保存投稿记录失败", "bvid", bvid, "error", err)
+    }
+
+    slog.InfoContext(c.Request.Context(), "稿件审核通过并已提交", "submission_id", submission.ID, "bvid", bvid)
+    c.JSON(http.StatusOK, gin.H{
+        "success":    true,
+        "message":    "稿件已提交",
+        "bvid":       bvid,
+        "url":        fmt.Sprintf("https://www.bilibili.com/video/%s", bvid),
+        "submission": submission,
+    })
+}
+
+// submitApproved 用稿件账号的凭证提交稿件
+func submitApproved(ctx context.Context, workspace *services.Workspace, submission *services.Submission) (string, error) {
+    account, ok := accounts().Find(submission.AccountID)
+    if !ok || !workspace.HasAccount(account.ID) {
+        return "", fmt.Errorf("账号已不在工作区中")
+    }
+    uploader, err := uploaderFor(account)
+    if err != nil {
+        return "", err
+    }
+    return uploader.SubmitContext(ctx, submission.Filename, submission.Params)
+}
+
+// workspaceForRequest 取出路径中的工作区并检查当前用户角色，失败时已写入响应
+func workspaceForRequest(c *gin.Context, required string) (*services.Workspace, *Identity, bool) {
+    identity, ok := CurrentIdentity(c)
+    if !ok {
+        abortAuth(c, AuthErrMissingToken, "请先登录")
+        return nil, nil, false
+    }
+
+    workspace, ok := workspaces().Get(c.Param("id"))
+    role := ""
+    if ok && (identity.WorkspaceID == "" || identity.WorkspaceID == workspace.ID) {
+        role = workspace.RoleOf(identity.OwnerID)
+    }
+    if role == "" {
+        c.JSON(http.StatusNotFound, gin.H{
+            "success": false,
+            "message": "工作区不存在",
+        })
+        return nil, nil, false
+    }
+    if !services.RoleAllows(role, required) {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": fmt.Sprintf("需要%s及以上角色", required),
+        })
+        return nil, nil, false
+    }
+    setLogAttrs(c, "workspace_id", workspace.ID)
+    // 工作区的每分钟请求数（工作区API密钥的请求已在AuthMiddleware中计入）
+    if identity.WorkspaceID == "" {
+        if err := quotas().AllowRequest(services.QuotaSubject{Kind: services.QuotaWorkspace, ID: workspace.ID}); err != nil {
+            abortQuota(c, QuotaErrRateLimited, err)
+            return nil, nil, false
+        }
+    }
+    return workspace, identity, true
+}
+
+// workspaceView 返回给前端的工作区信息
+func workspaceView(workspace *services.Workspace, userID string) gin.H {
+    members := make([]gin.H, 0, len(workspace.Members))
+    for _, member := range workspace.Members {
+        item := gin.H{
+            "user_id":  member.UserID,
+            "role":     member.Role,
+            "added_at": member.AddedAt,
+        }
+        if user, ok := users().Get(member.UserID); ok {
+            item["username"] = user.Username
+            item["display_name"] = user.Name()
+        }
+        members = append(members, item)
+    }
+
+    linked := make([]gin.H, 0, len(workspace.AccountIDs))
+    for _, id := range workspace.AccountIDs {
+        if account, ok := accounts().Find(id); ok {
+            linked = append(linked, gin.H{
+                "id":       account.ID,
+                "uid":      account.UID,
+                "username": account.Username,
+                "kind":     account.Kind,
+            })
+        }
+    }
+
+    return gin.H{
+        "id":         workspace.ID,
+        "name":       workspace.Name,
+        "role":       workspace.RoleOf(userID),
+        "members":    members,
+        "accounts":   linked,
+        "created_at": workspace.CreatedAt,
+    }
+}
+
+// uploadSize 计入配额的上传大小，取请求体的Content-Length，不需要读取表单中的文件
+func uploadSize(c *gin.Context) int64 {
+    if c.Request.ContentLength < 0 {
+        return 0
+    }
+    return c.Request.ContentLength
+}
+
+// prepareUpload 读取表单中的视频和投稿信息，保存到临时文件，失败时已写入响应
+// 投稿信息依次套用template_id指定的模板和账号默认设置
+func prepareUpload(c *gin.Context, account *services.LinkedAccount) (*services.VideoUploadParams, *multipart.FileHeader, string, bool) {
+    file, header, err := c.Request.FormFile("video")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "获取视频文件失败",
+        })
+        return nil, nil, "", false
+    }
+    defer file.Close()
+
+    title := c.PostForm("title")
+    if title == "" {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": "视频标题不能为空",
+        })
+        return nil, nil, "", false
+    }
+
+    template, err := TemplateForRequest(c, c.PostForm("template_id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return nil, nil, "", false
+    }
+
+    params := &services.VideoUploadParams{
+        Title:       title,
+        Description: c.PostForm("desc"),
+    }
+    if tags := strings.TrimSpace(c.PostForm("tags")); tags != "" {
+        params.Tags = strings.Fields(tags)
+    }
+    if category, err := strconv.Atoi(c.PostForm("category")); err == nil {
+        params.Category = category
+    }
+    if template != nil {
+        template.Apply(params)
+    }
+    account.Defaults.Apply(params)
+    if params.Category == 0 {
+        params.Category = 21 // 默认日常分区
+    }
+    if params.Copyright == 0 {
+        params.Copyright = 1 // 自制
+    }
+
+    tempFile := filepath.Join(config.GlobalConfig.TempDir, fmt.Sprintf("upload_%d_%s", time.Now().UnixNano(), filepath.Base(header.Filename)))
+    dst, err := os.Create(tempFile)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "创建临时文件失败",
+        })
+        return nil, nil, "", false
+    }
+    defer dst.Close()
+
+    if _, err := io.Copy(dst, file); err != nil {
+        os.Remove(tempFile)
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "success": false,
+            "message": "保存文件失败",
+        })
+        return nil, nil, "", false
+    }
+
+    return params, header, ResumableTempFile(account.ID, tempFile), true
+}
@@ -0,0 +1,176 @@
+// logging/logging.go - 结构化日志：JSON或文本格式、日志级别和上下文中的关联字段
+package logging
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "strings"
+
+    "go.opentelemetry.io/otel/trace"
+)
+
+// 日志格式
+const (
+    FormatJSON = "json"
+    FormatText = "text"
+)
+
+// Options 日志配置
+type Options struct {
+    Format string    // json或text，为空时使用text
+    Level  string    // debug、info、warn、error，为空时使用info
+    Output io.Writer // 为空时写入标准错误
+}
+
+// New 创建带脱敏和上下文字段的日志记录器
+func New(opts Options) (*slog.Logger, error) {
+    level, err := ParseLevel(opts.Level)
+    if err != nil {
+        return nil, err
+    }
+    if opts.Output == nil {
+        opts.Output = os.Stderr
+    }
+
+    handlerOpts := &slog.HandlerOptions{
+        Level:       level,
+        ReplaceAttr: redactAttr,
+    }
+    var handler slog.Handler
+    switch strings.ToLower(opts.Format) {
+    case "", FormatText:
+        handler = slog.NewTextHandler(opts.Output, handlerOpts)
+    case FormatJSON:
+        handler = slog.NewJSONHandler(opts.Output, handlerOpts)
+    default:
+        return nil, fmt.Errorf("不支持的日志格式: %s（可选json、text）", opts.Format)
+    }
+
+    return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// Setup 创建日志记录器并设为默认，标准库log的输出也经由它（info级别）
+func Setup(opts Options) error {
+    logger, err := New(opts)
+    if err != nil {
+        return err
+    }
+    slog.SetDefault(logger)
+    return nil
+}
+
+// Fatal 记录error级别日志后退出进程，替代log.Fatalf
+func Fatal(msg string, args ...any) {
+    slog.Error(msg, args...)
+    os.Exit(1)
+}
+
+// ParseLevel 解析日志级别
+func ParseLevel(level string) (slog.Level, error) {
+    switch strings.ToLower(strings.TrimSpace(level)) {
+    case "debug":
+        return slog.LevelDebug, nil
+    case "", "info":
+        return slog.LevelInfo, nil
+    case "warn", "warning":
+        return slog.LevelWarn, nil
+    case "error":
+        return slog.LevelError, nil
+    }
+    return slog.LevelInfo, fmt.Errorf("不支持的日志级别: %s（可选debug、info、warn、error）", level)
+}
+
+// contextKey 上下文中关联字段的键
+type contextKey struct{}
+
+// With 在上下文中追加关联字段（如request_id、job_id、user_id、account_id），同名字段会被覆盖
+// 使用slog.InfoContext等带上下文的方法记录时自动附加这些字段
+func With(ctx context.Context, args ...any) context.Context {
+    added := argsToAttrs(args)
+    existing := Attrs(ctx)
+
+    attrs := make([]slog.Attr, 0, len(existing)+len(added))
+    for _, attr := range existing {
+        if !hasKey(added, attr.Key) {
+            attrs = append(attrs, attr)
+        }
+    }
+    attrs = append(attrs, added...)
+    return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// Attrs 上下文中的关联字段
+func Attrs(ctx context.Context) []slog.Attr {
+    if ctx == nil {
+        return nil
+    }
+    attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+    return attrs
+}
+
+// Value 上下文中指定关联字段的值，如request_id
+func Value(ctx context.Context, key string) string {
+    for _, attr := range Attrs(ctx) {
+        if attr.Key == key {
+            return attr.Value.String()
+        }
+    }
+    return ""
+}
+
+// argsToAttrs 把键值对参数转换为属性，与slog.Logger.With的参数格式相同
+func argsToAttrs(args []any) []slog.Attr {
+    record := slog.Record{}
+    record.Add(args...)
+
+    attrs := make([]slog.Attr, 0, record.NumAttrs())
+    record.Attrs(func(attr slog.Attr) bool {
+        attrs = append(attrs, attr)
+        return true
+    })
+    return attrs
+}
+
+// hasKey attrs中是否有指定键
+func hasKey(attrs []slog.Attr, key string) bool {
+    for _, attr := range attrs {
+        if attr.Key == key {
+            return true
+        }
+    }
+    return false
+}
+
+// contextHandler 记录日志时附加上下文中的关联字段，以及当前span的trace_id和span_id
+type contextHandler struct {
+    slog.Handler
+}
+
+// Handle 实现slog.Handler
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+    attrs := Attrs(ctx)
+    if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+        attrs = append(attrs[:len(attrs):len(attrs)],
+            slog.String("trace_id", spanContext.TraceID().String()),
+            slog.String("span_id", spanContext.SpanID().String()),
+        )
+    }
+    if len(attrs) > 0 {
+        record = record.Clone()
+        record.AddAttrs(attrs...)
+    }
+    return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs 实现slog.Handler
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup 实现slog.Handler
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+    return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,80 @@
+// logging/redact.go - 日志脱敏：令牌、Cookie和Authorization头
+package logging
+
+import (
+    "log/slog"
+    "regexp"
+    "strings"
+)
+
+// Redacted 替换敏感值的占位符
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys 值需要整体隐藏的字段名（小写，-替换为_）
+var sensitiveKeys = map[string]bool{
+    "authorization":  true,
+    "cookie":         true,
+    "set_cookie":     true,
+    "token":          true,
+    "access_token":   true,
+    "refresh_token":  true,
+    "access_key":     true,
+    "jwt":            true,
+    "sessdata":       true,
+    "bili_jct":       true,
+    "csrf":           true,
+    "password":       true,
+    "secret":         true,
+    "client_secret":  true,
+    "api_key":        true,
+    "x_api_key":      true,
+    "code_verifier":  true,
+    "x_upos_auth":    true,
+    "upos_auth":      true,
+    "credential_key": true,
+}
+
+// sensitivePatterns 字符串中需要隐藏的片段，第一个分组保留
+var sensitivePatterns = []*regexp.Regexp{
+    // Authorization: Bearer xxx
+    regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+    // Cookie和查询参数：SESSDATA=xxx; access_token=xxx&...
+    regexp.MustCompile(`(?i)\b((?:SESSDATA|bili_jct|DedeUserID__ckMd5|access_token|refresh_token|access_key|client_secret|code_verifier|csrf|biliCSRF|password)=)[^;&\s"]+`),
+    // JSON：{"access_token":"xxx"}
+    regexp.MustCompile(`(?i)("(?:access_token|refresh_token|access_key|client_secret|password|sessdata|bili_jct|token)"\s*:\s*")[^"]*`),
+    // API密钥bu_<id>_<secret>，保留id便于关联
+    regexp.MustCompile(`\b(bu_[A-Za-z0-9]+_)[A-Za-z0-9_\-]{8,}`),
+    // JWT
+    regexp.MustCompile(`()\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`),
+}
+
+// RedactString 隐藏字符串中的令牌、Cookie和Authorization值
+func RedactString(s string) string {
+    for _, pattern := range sensitivePatterns {
+        s = pattern.ReplaceAllString(s, "${1}"+Redacted)
+    }
+    return s
+}
+
+// IsSensitiveKey 字段名是否为敏感字段
+func IsSensitiveKey(key string) bool {
+    key = strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+    return sensitiveKeys[key] || strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "password")
+}
+
+// redactAttr slog.HandlerOptions.ReplaceAttr：敏感字段整体隐藏，其他字符串和错误按模式脱敏
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+    if attr.Key != slog.MessageKey && IsSensitiveKey(attr.Key) {
+        return slog.String(attr.Key, Redacted)
+    }
+
+    switch attr.Value.Kind() {
+    case slog.KindString:
+        return slog.String(attr.Key, RedactString(attr.Value.String()))
+    case slog.KindAny:
+        if err, ok := attr.Value.Any().(error); ok {
+            return slog.String(attr.Key, RedactString(err.Error()))
+        }
+    }
+    return attr
+}
@@ -3,15 +3,23 @@ package main
 
 import (
     "bilibili-uploader/config"
+    "bilibili-uploader/fakebili"
     "bilibili-uploader/handlers"
+    "bilibili-uploader/logging"
     "bilibili-uploader/services"
+    "bilibili-uploader/tracing"
+    "context"
     "fmt"
     "io"
-    "log"
+    "log/slog"
+    "net"
     "net/http"
     "os"
+    "os/signal"
     "path/filepath"
     "strings"
+    "sync"
+    "syscall"
     "time"
     
     "github.com/gin-contrib/cors"
@@ -22,36 +30,64 @@ func main() {
     // 加载配置
     config.Load()
     
+    // 结构化日志，标准库log的输出也经由slog
+    if err := logging.Setup(logging.Options{
+        Format: config.GlobalConfig.LogFormat,
+        Level:  config.GlobalConfig.LogLevel,
+    }); err != nil {
+        slog.Error("日志配置错误", "error", err)
+        os.Exit(1)
+    }
+    config.Validate()
+    
+    // 链路追踪：OTEL_TRACES_EXPORTER=otlp或stdout时导出span
+    shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+        Exporter:    config.GlobalConfig.TraceExporter,
+        Protocol:    config.GlobalConfig.TraceProtocol,
+        ServiceName: config.GlobalConfig.ServiceName,
+    })
+    if err != nil {
+        logging.Fatal("链路追踪配置错误", "error", err)
+    }
+    defer shutdownTracing(context.Background())
+    
     // 创建必要的目录
     createDirectories()
     
+    // B站接口地址和出站HTTP客户端
+    configureBilibili()
+    
+    // 加载加密保存的B站凭证，密钥缺失时拒绝启动
+    if err := handlers.InitCredentialStores(); err != nil {
+        logging.Fatal("加载B站凭证失败", "error", err)
+    }
+    
     // 检查B站配置
     if !config.IsBilibiliConfigured() {
-        log.Println("================================================")
-        log.Println("⚠️  B站OAuth未配置!")
-        log.Println("请按以下步骤操作:")
-        log.Println("1. 访问 https://open.bilibili.com 申请开发者")
-        log.Println("2. 创建应用，获取Client ID和Secret")
-        log.Println("3. 在.env文件中配置:")
-        log.Println("   BILIBILI_CLIENT_ID=你的ClientID")
-        log.Println("   BILIBILI_CLIENT_SECRET=你的Secret")
-        log.Println("================================================")
-        log.Println("📌 现在以模拟模式运行...")
+        slog.Warn("B站OAuth未配置，OAuth授权登录不可用，扫码登录和导入Cookie的账号仍可上传",
+            "hint", "在 https://open.bilibili.com 创建应用，并在.env文件中配置BILIBILI_CLIENT_ID和BILIBILI_CLIENT_SECRET")
     }
     
-    // 创建Gin路由器
-    router := gin.Default()
+    // Gin的调试输出（路由列表等）不是结构化日志，只在debug级别且未设置GIN_MODE时保留
+    if os.Getenv(gin.EnvGinMode) == "" && !strings.EqualFold(config.GlobalConfig.LogLevel, "debug") {
+        gin.SetMode(gin.ReleaseMode)
+    }
+    
+    // 创建Gin路由器：链路追踪、请求日志（带request_id和trace_id）和panic恢复
+    router := gin.New()
+    router.Use(handlers.Tracing(), handlers.RequestLogger(), handlers.Recovery())
     
     // 配置CORS
     corsConfig := cors.DefaultConfig()
     corsConfig.AllowOrigins = []string{"*"}
     corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-    corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Cookie"}
+    corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Cookie", "X-API-Key", handlers.RequestIDHeader, "traceparent", "tracestate"}
+    corsConfig.ExposeHeaders = []string{handlers.RequestIDHeader, "Retry-After"}
     corsConfig.AllowCredentials = true
     router.Use(cors.New(corsConfig))
     
-    // 设置文件上传大小限制 (4GB)
-    router.MaxMultipartMemory = 4 << 30
+    // 表单中超过32MB的部分写入临时文件；上传大小由LimitUploadBody按配额限制
+    router.MaxMultipartMemory = 32 << 20
     
     // 静态文件服务
     router.Static("/static", "./static")
@@ -62,6 +98,18 @@ func main() {
         c.Redirect(http.StatusMovedPermanently, "/static/index.html")
     })
     
+    // Prometheus指标
+    services.SetMetricsSources(handlers.Jobs(), config.GlobalConfig.TempDir)
+    router.GET("/metrics", handlers.MetricsHandler(config.GlobalConfig.MetricsToken))
+    
+    // API密钥的权限检查，登录会话不受限制
+    requireSession := handlers.RequireSession()
+    rejectAPIKey := handlers.RejectAPIKey() // 登录和关联账号的接口不接受API密钥
+    scopeUpload := handlers.RequireScope(services.ScopeUpload)
+    scopePublish := handlers.RequireScope(services.ScopePublish)
+    limitUpload := handlers.LimitUploadBody() // 解析表单前检查上传配额
+    scopeRead := handlers.RequireScope(services.ScopeReadHistory)
+    
     // API路由组
     api := router.Group("/api")
     {
@@ -72,38 +120,240 @@ func main() {
         authHandler := handlers.NewAuthHandler()
         auth := api.Group("/auth")
         {
-            auth.GET("/url", authHandler.GetAuthURL)           // 获取授权URL
-            auth.GET("/callback", authHandler.HandleCallback)  // OAuth回调
-            auth.POST("/token", authHandler.ExchangeToken)     // 交换token
-            auth.GET("/verify", authHandler.VerifyToken)       // 验证token
+            auth.GET("/url", rejectAPIKey, authHandler.GetAuthURL)          // 获取授权URL
+            auth.GET("/callback", rejectAPIKey, authHandler.HandleCallback) // OAuth回调
+            auth.POST("/token", rejectAPIKey, authHandler.ExchangeToken)    // 交换token
+            auth.GET("/verify", handlers.AuthMiddleware(), authHandler.VerifyToken) // 验证token
+            auth.POST("/cookie", rejectAPIKey, authHandler.ImportCookie)    // 导入网页Cookie
+            auth.POST("/dev", rejectAPIKey, authHandler.DevLogin)           // 模拟模式开发者登录
+            
+            // 会话管理（需要登录会话）
+            auth.POST("/logout", handlers.AuthMiddleware(), requireSession, authHandler.Logout)                 // 退出登录
+            auth.GET("/sessions", handlers.AuthMiddleware(), requireSession, authHandler.ListSessions)          // 会话列表
+            auth.DELETE("/sessions/:id", handlers.AuthMiddleware(), requireSession, authHandler.RevokeSession) // 注销指定会话
+            
+            // 扫码登录
+            qrHandler := handlers.NewQRLoginHandler()
+            auth.POST("/qrcode", rejectAPIKey, qrHandler.CreateQRCode)        // 申请登录二维码
+            auth.GET("/qrcode/:key", rejectAPIKey, qrHandler.GetQRCode)       // 二维码图片/终端文本
+            auth.GET("/qrcode/:key/poll", rejectAPIKey, qrHandler.PollQRCode) // 轮询扫码状态
+        }
+        
+        // 本服务用户
+        userHandler := handlers.NewUserHandler()
+        userGroup := api.Group("/users")
+        {
+            userGroup.POST("/register", userHandler.Register)                                                    // 注册
+            userGroup.POST("/login", userHandler.Login)                                                          // 密码登录
+            userGroup.GET("/oidc/login", userHandler.OIDCLogin)                                                  // 跳转OIDC登录
+            userGroup.GET("/oidc/callback", userHandler.OIDCCallback)                                            // OIDC回调
+            userGroup.GET("/me", handlers.AuthMiddleware(), userHandler.Me)                                      // 当前用户
+            userGroup.PUT("/me/password", handlers.AuthMiddleware(), requireSession, userHandler.ChangePassword) // 设置密码
+        }
+        
+        // API密钥管理（需要登录会话）
+        apiKeyHandler := handlers.NewAPIKeyHandler()
+        keyGroup := api.Group("/keys")
+        keyGroup.Use(handlers.AuthMiddleware(), requireSession)
+        {
+            keyGroup.POST("", apiKeyHandler.CreateKey)       // 创建密钥
+            keyGroup.GET("", apiKeyHandler.ListKeys)         // 密钥列表
+            keyGroup.DELETE("/:id", apiKeyHandler.RevokeKey) // 吊销密钥
+        }
+        
+        // 投稿模板（需要认证）
+        templateHandler := handlers.NewTemplateHandler()
+        templateGroup := api.Group("/templates")
+        templateGroup.Use(handlers.AuthMiddleware())
+        {
+            templateGroup.GET("", templateHandler.ListTemplates)                         // 模板列表
+            templateGroup.POST("", requireSession, templateHandler.SaveTemplate)         // 新建模板
+            templateGroup.PUT("/:id", requireSession, templateHandler.SaveTemplate)      // 更新模板
+            templateGroup.DELETE("/:id", requireSession, templateHandler.DeleteTemplate) // 删除模板
+        }
+        
+        // 投稿历史和后台任务（需要认证）
+        uploadHandler := handlers.NewUploadHandler()
+        api.GET("/history", handlers.AuthMiddleware(), handlers.RequireScope(services.ScopeReadHistory), uploadHandler.GetUploadHistory)
+        api.GET("/jobs", handlers.AuthMiddleware(), handlers.RequireScope(services.ScopeReadHistory), uploadHandler.ListJobs)
+        
+        // 限流和上传配额
+        quotaHandler := handlers.NewQuotaHandler()
+        api.GET("/quota", handlers.AuthMiddleware(), scopeRead, quotaHandler.GetQuota)
+        
+        // 草稿：先上传，再单独提交（需要认证）
+        draftHandler := handlers.NewDraftHandler()
+        draftGroup := api.Group("/drafts")
+        draftGroup.Use(handlers.AuthMiddleware())
+        {
+            draftGroup.POST("", scopeUpload, limitUpload, draftHandler.CreateDraft)  // 上传为草稿
+            draftGroup.GET("", scopeUpload, draftHandler.ListDrafts)                 // 草稿列表
+            draftGroup.GET("/:id", scopeUpload, draftHandler.GetDraft)               // 草稿详情
+            draftGroup.PUT("/:id", scopeUpload, draftHandler.UpdateDraft)            // 修改投稿信息
+            draftGroup.DELETE("/:id", scopeUpload, draftHandler.DeleteDraft)         // 删除草稿
+            draftGroup.POST("/:id/publish", scopePublish, draftHandler.PublishDraft) // 提交稿件
+        }
+        
+        // 团队工作区和稿件审核（需要认证）
+        workspaceHandler := handlers.NewWorkspaceHandler()
+        workspaceGroup := api.Group("/workspaces")
+        workspaceGroup.Use(handlers.AuthMiddleware())
+        {
+            workspaceGroup.POST("", requireSession, workspaceHandler.CreateWorkspace)                              // 创建工作区
+            workspaceGroup.GET("", scopeRead, workspaceHandler.ListWorkspaces)                                     // 工作区列表
+            workspaceGroup.GET("/:id", scopeRead, workspaceHandler.GetWorkspace)                                   // 工作区详情
+            workspaceGroup.PUT("/:id/members", requireSession, workspaceHandler.SetMember)                         // 添加成员/修改角色
+            workspaceGroup.DELETE("/:id/members/:user_id", requireSession, workspaceHandler.RemoveMember)          // 移除成员
+            workspaceGroup.POST("/:id/accounts", requireSession, workspaceHandler.AddAccount)                      // 加入B站账号
+            workspaceGroup.DELETE("/:id/accounts/:account_id", requireSession, workspaceHandler.RemoveAccount)     // 移除B站账号
+            workspaceGroup.POST("/:id/submissions", scopeUpload, limitUpload, workspaceHandler.UploadSubmission)   // 上传并提交审核
+            workspaceGroup.GET("/:id/submissions", scopeRead, workspaceHandler.ListSubmissions)                    // 稿件列表
+            workspaceGroup.PUT("/:id/submissions/:sid", scopeUpload, workspaceHandler.UpdateSubmission)            // 修改稿件信息
+            workspaceGroup.POST("/:id/submissions/:sid/approve", scopePublish, workspaceHandler.ApproveSubmission) // 审核通过并提交
+            workspaceGroup.POST("/:id/submissions/:sid/reject", scopePublish, workspaceHandler.RejectSubmission)   // 驳回
+        }
+        
+        // 关联的B站账号（需要登录会话）
+        accountHandler := handlers.NewAccountHandler()
+        accountGroup := api.Group("/accounts")
+        accountGroup.Use(handlers.AuthMiddleware(), requireSession)
+        {
+            accountGroup.GET("", accountHandler.ListAccounts)                      // 账号列表及状态
+            accountGroup.PUT("/:id/defaults", accountHandler.UpdateAccountDefaults) // 账号默认投稿设置
+            accountGroup.DELETE("/:id", accountHandler.UnlinkAccount)              // 解除关联
         }
         
         // 视频上传相关（需要认证）
         upload := api.Group("/upload")
-        upload.Use(authMiddleware())
+        upload.Use(handlers.AuthMiddleware())
         {
-            upload.POST("/bilibili", handleBilibiliUpload)  // B站上传
-            upload.POST("/process", processVideo)           // 视频处理
+            upload.POST("/bilibili", handlers.RequireScope(services.ScopeUpload, services.ScopePublish), limitUpload, handleBilibiliUpload) // B站上传（直接提交稿件）
+            upload.POST("/process", handlers.RequireScope(services.ScopeProcess), processVideo)                                             // 视频处理
         }
         
         // 公开的上传接口（用于测试）
         api.POST("/upload/test", handleTestUpload)
     }
     
-    // 启动服务器
+    // 启动OAuth令牌后台续期
+    stopRenewal := func() {}
+    if config.IsBilibiliConfigured() {
+        stopRenewal = handlers.StartTokenRenewal()
+    }
+    defer stopRenewal()
+    
+    // 启动监控目录自动投稿
+    watcher := startFolderWatcher()
+    
+    // 请求的上下文都派生自workCtx，退出时取消它来中断仍未完成的上传和FFmpeg
+    workCtx, cancelWork := context.WithCancel(context.Background())
+    defer cancelWork()
     port := ":" + config.GlobalConfig.Port
-    log.Printf("🚀 B站OAuth一键投稿服务启动")
-    log.Printf("📍 访问地址: http://localhost%s", port)
-    log.Printf("🔐 OAuth回调: %s", config.GlobalConfig.BilibiliRedirectURI)
+    server := &http.Server{
+        Addr:    port,
+        Handler: router,
+        BaseContext: func(net.Listener) context.Context {
+            return workCtx
+        },
+    }
     
-    if config.IsBilibiliConfigured() {
-        log.Printf("✅ B站OAuth已配置")
-    } else {
-        log.Printf("⚠️  B站OAuth未配置，运行在模拟模式")
+    // 启动服务器
+    slog.Info("B站OAuth一键投稿服务启动",
+        "addr", "http://localhost"+port,
+        "oauth_callback", config.GlobalConfig.BilibiliRedirectURI,
+        "oauth_configured", config.IsBilibiliConfigured(),
+    )
+    serverErr := make(chan error, 1)
+    go func() {
+        serverErr <- server.ListenAndServe()
+    }()
+    
+    signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+    select {
+    case err := <-serverErr:
+        logging.Fatal("服务器启动失败", "error", err)
+    case <-signalCtx.Done():
     }
+    // 退出过程中再次收到信号时按默认行为立即结束
+    stopSignals()
+    
+    gracefulShutdown(server, watcher, cancelWork, config.GlobalConfig.ShutdownTimeout)
+}
+
+// shutdownGrace 中断进行中的工作后留给上传保存断点、FFmpeg收尾和清理的时间
+const shutdownGrace = 5 * time.Second
+
+// gracefulShutdown 在timeout内优雅退出：
+// 立即停止接收新请求和新的监控目录任务，等待进行中的请求和任务完成；
+// 超过排空时间（timeout减去shutdownGrace）仍未完成的上传和FFmpeg被中断，上传保存断点，监控目录的源文件保留到重启后继续
+func gracefulShutdown(server *http.Server, watcher *services.FolderWatcher, cancelWork context.CancelFunc, timeout time.Duration) {
+    start := time.Now()
+    slog.Info("收到退出信号，开始优雅退出", "timeout", timeout.String())
+    
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
     
-    if err := router.Run(port); err != nil {
-        log.Fatalf("服务器启动失败: %v", err)
+    drain := timeout - shutdownGrace
+    if drain < timeout/2 {
+        drain = timeout / 2
+    }
+    interrupt := time.AfterFunc(drain, func() {
+        slog.Warn("仍有进行中的工作，中断上传和FFmpeg", "drain", drain.String())
+        cancelWork()
+        if watcher != nil {
+            watcher.Interrupt()
+        }
+    })
+    defer interrupt.Stop()
+    
+    var wg sync.WaitGroup
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        if err := server.Shutdown(ctx); err != nil {
+            slog.Error("HTTP服务未能在期限内关闭，强制断开连接", "error", err)
+            server.Close()
+        }
+    }()
+    if watcher != nil {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            watcher.Stop()
+        }()
+    }
+    
+    done := make(chan struct{})
+    go func() {
+        wg.Wait()
+        close(done)
+    }()
+    select {
+    case <-done:
+    case <-ctx.Done():
+        slog.Error("退出期限已到，仍有任务未结束", "timeout", timeout.String())
+    }
+    
+    cleanTempDir(config.GlobalConfig.TempDir, handlers.UploadCheckpoints())
+    slog.Info("服务已退出", "duration_ms", time.Since(start).Milliseconds())
+}
+
+// cleanTempDir 删除临时目录中请求上传留下的文件，有有效断点的文件保留，重启后重试的上传可从断点继续
+func cleanTempDir(dir string, checkpoints *services.CheckpointStore) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return
+    }
+    for _, entry := range entries {
+        if !entry.Type().IsRegular() {
+            continue
+        }
+        path := filepath.Join(dir, entry.Name())
+        if _, ok := checkpoints.Get(path); ok {
+            slog.Info("保留有上传断点的临时文件", "path", path)
+            continue
+        }
+        os.Remove(path)
     }
 }
 
@@ -113,15 +363,96 @@ func createDirectories() {
         config.GlobalConfig.UploadDir,
         config.GlobalConfig.ProcessedDir,
         config.GlobalConfig.TempDir,
+        config.GlobalConfig.DataDir,
     }
     
     for _, dir := range dirs {
         if err := os.MkdirAll(dir, 0755); err != nil {
-            log.Printf("创建目录失败 %s: %v", dir, err)
+            slog.Error("创建目录失败", "dir", dir, "error", err)
+        }
+    }
+}
+
+// configureBilibili 按配置设置B站接口地址和共享的出站HTTP客户端
+// BILIBILI_FAKE=true时先在进程内启动模拟B站接口，所有请求走真实的上传代码
+func configureBilibili() {
+    cfg := config.GlobalConfig
+    if cfg.BilibiliFake {
+        fake := fakebili.New(fakebili.Options{
+            ClientID:     cfg.BilibiliClientID,
+            ClientSecret: cfg.BilibiliClientSecret,
+            ReviewDelay:  30 * time.Second,
+        })
+        baseURL, err := fake.Start("127.0.0.1:0")
+        if err != nil {
+            logging.Fatal("启动模拟B站接口失败", "error", err)
+        }
+        cfg.BilibiliAPIURL = baseURL
+        cfg.BilibiliMemberURL = baseURL
+        cfg.BilibiliPassportURL = baseURL
+        slog.Info("模拟B站接口已启动", "url", baseURL)
+    }
+    
+    client, err := services.NewHTTPClient(services.HTTPOptions{
+        Timeout:   cfg.HTTPTimeout,
+        ProxyURL:  cfg.HTTPProxy,
+        UserAgent: cfg.UserAgent,
+    })
+    if err != nil {
+        logging.Fatal("OUTBOUND_PROXY配置错误", "error", err)
+    }
+    services.SetBilibiliDefaults(services.BilibiliEndpoints{
+        API:      cfg.BilibiliAPIURL,
+        Member:   cfg.BilibiliMemberURL,
+        Passport: cfg.BilibiliPassportURL,
+    }, client)
+}
+
+// watchUpload 监控目录的投稿函数，每次投稿时重新解析账号凭证，
+// 使账号重新授权和OAuth令牌续期无需重启服务即可生效
+func watchUpload(ownerID, accountID string) services.UploadFunc {
+    if _, account, err := handlers.WatchUploader(ownerID, accountID); err != nil {
+        slog.Warn("监控目录的投稿账号暂不可用，投稿时将重试", "error", err)
+    } else {
+        slog.Info("监控目录使用关联账号投稿", "account_id", account.ID, "bilibili_uid", account.UID)
+    }
+
+    return func(ctx context.Context, videoPath string, params services.VideoUploadParams) (string, error) {
+        uploader, account, err := handlers.WatchUploader(ownerID, accountID)
+        if err != nil {
+            return "", fmt.Errorf("获取监控目录投稿账号失败: %w", err)
         }
+        account.Defaults.Apply(&params)
+        return uploader.UploadVideoContext(ctx, videoPath, params)
     }
 }
 
+// startFolderWatcher 启动监控目录自动投稿（未配置WATCH_DIRS时不启动）
+func startFolderWatcher() *services.FolderWatcher {
+    if len(config.GlobalConfig.WatchDirs) == 0 {
+        return nil
+    }
+    
+    // 投稿账号不能调用B站接口（如OAuth账号未配置开放平台应用）时不启动，不会假装投稿成功
+    if err := handlers.CheckWatchAccount(config.GlobalConfig.WatchUserID, config.GlobalConfig.WatchAccountID); err != nil {
+        slog.Error("监控目录的投稿账号无法上传，监控目录未启动", "error", err)
+        return nil
+    }
+    
+    watcher := services.NewFolderWatcher(
+        config.GlobalConfig.WatchDirs,
+        config.GlobalConfig.ProcessedDir,
+        watchUpload(config.GlobalConfig.WatchUserID, config.GlobalConfig.WatchAccountID),
+        handlers.Jobs(),
+    )
+    watcher.UserID = config.GlobalConfig.WatchUserID
+    watcher.Interval = config.GlobalConfig.WatchInterval
+    watcher.SettleTime = config.GlobalConfig.WatchSettleTime
+    watcher.Start()
+    
+    return watcher
+}
+
 // healthCheck 健康检查
 func healthCheck(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{
@@ -136,46 +467,26 @@ func healthCheck(c *gin.Context) {
             "/api/auth/url - 获取OAuth授权URL",
             "/api/auth/callback - OAuth回调",
             "/api/auth/verify - 验证token",
+            "/api/auth/qrcode - 扫码登录",
+            "/api/auth/cookie - 导入网页Cookie",
+            "/api/auth/dev - 模拟模式开发者登录",
+            "/api/auth/logout - 退出登录",
+            "/api/auth/sessions - 会话列表/注销",
+            "/api/users - 用户注册/登录/OIDC",
+            "/api/keys - API密钥管理",
+            "/api/accounts - 关联的B站账号",
+            "/api/drafts - 草稿上传/提交",
+            "/api/workspaces - 团队工作区和稿件审核",
+            "/api/templates - 投稿模板",
+            "/api/history - 投稿历史",
+            "/api/jobs - 后台任务",
+            "/api/quota - 限流和配额用量",
             "/api/upload/bilibili - 上传到B站",
+            "/metrics - Prometheus监控指标",
         },
     })
 }
 
-// authMiddleware 认证中间件
-func authMiddleware() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        // 如果是模拟模式，跳过认证
-        if !config.IsBilibiliConfigured() {
-            log.Println("模拟模式：跳过认证")
-            c.Next()
-            return
-        }
-        
-        // 验证Authorization header
-        authHeader := c.GetHeader("Authorization")
-        if authHeader == "" {
-            c.JSON(http.StatusUnauthorized, gin.H{
-                "success": false,
-                "message": "请先登录B站账号",
-            })
-            c.Abort()
-            return
-        }
-        
-        // 验证token（这里简化处理，实际应该调用handlers.ValidateJWT）
-        if !strings.HasPrefix(authHeader, "Bearer ") {
-            c.JSON(http.StatusUnauthorized, gin.H{
-                "success": false,
-                "message": "无效的认证格式",
-            })
-            c.Abort()
-            return
-        }
-        
-        c.Next()
-    }
-}
-
 // handleBilibiliUpload 处理B站上传
 func handleBilibiliUpload(c *gin.Context) {
     // 获取上传的文件
@@ -195,6 +506,15 @@ func handleBilibiliUpload(c *gin.Context) {
     tags := c.PostForm("tags")
     category := c.PostForm("category")
     
+    template, err := handlers.TemplateForRequest(c, c.PostForm("template_id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    
     if title == "" {
         c.JSON(http.StatusBadRequest, gin.H{
             "success": false,
@@ -203,15 +523,49 @@ func handleBilibiliUpload(c *gin.Context) {
         return
     }
     
-    log.Printf("📤 收到上传请求:")
-    log.Printf("   文件: %s (%.2f MB)", header.Filename, float64(header.Size)/(1024*1024))
-    log.Printf("   标题: %s", title)
-    log.Printf("   简介: %s", desc)
-    log.Printf("   标签: %s", tags)
-    log.Printf("   分区: %s", category)
+    // 确认account_id属于当前用户（未指定时使用登录账号）
+    account, err := handlers.AccountForRequest(c, c.PostForm("account_id"))
+    if err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    
+    // 加入工作区的账号只有发布者可以直接投稿
+    if err := handlers.RequirePublisher(c, account); err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    
+    // OAuth账号需要配置B站开放平台应用，扫码和Cookie登录的账号不需要
+    if !handlers.RequireBilibili(c, account) {
+        return
+    }
+    
+    // 检查上传配额（并发数、每日次数和流量）
+    release, ok := handlers.AcquireUpload(c, account, header.Size)
+    if !ok {
+        return
+    }
+    defer release()
+    
+    ctx := c.Request.Context()
+    slog.InfoContext(ctx, "收到上传请求",
+        "file", header.Filename,
+        "size", header.Size,
+        "category", category,
+        "title_len", len([]rune(title)),
+        "desc_len", len([]rune(desc)),
+        "bilibili_uid", account.UID,
+    )
     
     // 保存文件到临时目录
-    tempFile := filepath.Join(config.GlobalConfig.TempDir, fmt.Sprintf("%d_%s", time.Now().Unix(), header.Filename))
+    tempFile := filepath.Join(config.GlobalConfig.TempDir, fmt.Sprintf("%d_%s", time.Now().Unix(), filepath.Base(header.Filename)))
     dst, err := os.Create(tempFile)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
@@ -224,6 +578,7 @@ func handleBilibiliUpload(c *gin.Context) {
     
     written, err := io.Copy(dst, file)
     if err != nil {
+        os.Remove(tempFile)
         c.JSON(http.StatusInternalServerError, gin.H{
             "success": false,
             "message": "写入文件失败",
@@ -231,75 +586,58 @@ func handleBilibiliUpload(c *gin.Context) {
         return
     }
     
-    log.Printf("✅ 文件已保存: %s (%d bytes)", tempFile, written)
+    slog.DebugContext(ctx, "临时文件已保存", "path", tempFile, "bytes", written)
+    tempFile = handlers.ResumableTempFile(account.ID, tempFile)
+    defer handlers.ReleaseTempFile(tempFile) // 上传完成或出错后删除临时文件，中断时保留
     
-    // 如果配置了B站OAuth，执行真实上传
-    if config.IsBilibiliConfigured() {
-        // 从JWT中获取B站token
-        bilibiliToken, err := handlers.GetBilibiliToken(c)
-        if err != nil {
-            c.JSON(http.StatusUnauthorized, gin.H{
-                "success": false,
-                "message": "获取B站认证失败",
-            })
-            return
-        }
-        
-        // 准备上传参数
-        uploadParams := services.VideoUploadParams{
-            Title:       title,
-            Description: desc,
-            Tags:        strings.Split(tags, " "),
-            Category:    21, // 默认分区，实际应该从category参数解析
-            Copyright:   1,  // 自制
-        }
-        
-        // 执行上传
-        log.Printf("🚀 开始上传到B站...")
-        bvid, err := services.AutoUploadWithOAuth(bilibiliToken, tempFile, uploadParams)
-        if err != nil {
-            log.Printf("❌ 上传失败: %v", err)
-            c.JSON(http.StatusInternalServerError, gin.H{
-                "success": false,
-                "message": fmt.Sprintf("上传失败: %v", err),
-            })
-            return
-        }
-        
-        log.Printf("✅ 上传成功! BV号: %s", bvid)
-        c.JSON(http.StatusOK, gin.H{
-            "success": true,
-            "message": "视频上传成功",
-            "bvid":    bvid,
-            "url":     fmt.Sprintf("https://www.bilibili.com/video/%s", bvid),
-        })
-        
-    } else {
-        // 模拟模式
-        log.Printf("📝 模拟模式：生成模拟结果")
-        
-        // 模拟上传延迟
-        time.Sleep(2 * time.Second)
-        
-        // 生成模拟的BV号
-        mockBVID := fmt.Sprintf("BV1mock%d", time.Now().Unix()%100000)
-        
-        c.JSON(http.StatusOK, gin.H{
-            "success": true,
-            "message": "视频上传成功（模拟）",
-            "bvid":    mockBVID,
-            "url":     fmt.Sprintf("https://www.bilibili.com/video/%s", mockBVID),
-            "mode":    "simulation",
-            "note":    "这是模拟结果，实际上传需要配置B站OAuth",
+    // 从JWT中获取B站凭证（OAuth令牌或网页Cookie）
+    uploader, account, err := handlers.GetBilibiliUploader(c, account.ID)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "success": false,
+            "message": "获取B站认证失败",
         })
+        return
     }
     
-    // 清理临时文件
-    go func() {
-        time.Sleep(5 * time.Minute)
-        os.Remove(tempFile)
-        log.Printf("🗑️ 清理临时文件: %s", tempFile)
-    }()
+    // 准备上传参数
+    uploadParams := services.VideoUploadParams{
+        Title:       title,
+        Description: desc,
+        Tags:        strings.Split(tags, " "),
+    }
+    if template != nil {
+        template.Apply(&uploadParams)
+    }
+    account.Defaults.Apply(&uploadParams)
+    if uploadParams.Copyright == 0 {
+        uploadParams.Copyright = 1 // 自制
+    }
+    if uploadParams.Category == 0 {
+        uploadParams.Category = 21 // 默认分区，实际应该从category参数解析
+    }
+    
+    // 执行上传
+    slog.InfoContext(ctx, "开始上传到B站", "tid", uploadParams.Category)
+    bvid, err := uploader.UploadVideoContext(ctx, tempFile, uploadParams)
+    if err != nil {
+        handlers.RespondBilibiliError(c, "上传失败", err, nil)
+        return
+    }
+    
+    slog.InfoContext(ctx, "上传成功", "bvid", bvid)
+    handlers.RecordUpload(c, account, services.HistoryRecord{
+        BVID:     bvid,
+        Title:    title,
+        File:     header.Filename,
+        Category: uploadParams.Category,
+    })
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "视频上传成功",
+        "bvid":    bvid,
+        "url":     fmt.Sprintf("https://www.bilibili.com/video/%s", bvid),
+    })
 }
 
 // handleTestUpload 测试上传（不需要认证）
@@ -314,7 +652,7 @@ func handleTestUpload(c *gin.Context) {
     }
     defer file.Close()
     
-    log.Printf("📤 测试上传: %s (%.2f MB)", header.Filename, float64(header.Size)/(1024*1024))
+    slog.InfoContext(c.Request.Context(), "测试上传", "file", header.Filename, "size", header.Size)
     
     c.JSON(http.StatusOK, gin.H{
         "success": true,
@@ -327,8 +665,9 @@ func handleTestUpload(c *gin.Context) {
 // processVideo 处理视频
 func processVideo(c *gin.Context) {
     var req struct {
-        Filename string `json:"filename"`
-        Quality  string `json:"quality"`
+        Filename  string `json:"filename"`
+        Quality   string `json:"quality"`
+        AccountID string `json:"account_id"`
     }
     
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -339,7 +678,23 @@ func processVideo(c *gin.Context) {
         return
     }
     
-    log.Printf("⚙️ 处理视频: %s (质量: %s)", req.Filename, req.Quality)
+    account, err := handlers.AccountForRequest(c, req.AccountID)
+    if err != nil {
+        c.JSON(http.StatusForbidden, gin.H{
+            "success": false,
+            "message": err.Error(),
+        })
+        return
+    }
+    
+    // 转码占用并发任务名额
+    release, ok := handlers.AcquireJob(c, account)
+    if !ok {
+        return
+    }
+    defer release()
+    
+    slog.InfoContext(c.Request.Context(), "处理视频", "file", req.Filename, "quality", req.Quality, "bilibili_uid", account.UID)
     
     // 检查是否安装了FFmpeg
     if !services.CheckFFmpeg() {
@@ -361,7 +716,7 @@ func processVideo(c *gin.Context) {
         Quality: req.Quality,
     }
     
-    outputFile, err := processor.ProcessVideo(req.Filename, processOptions)
+    outputFile, err := processor.ProcessVideoContext(c.Request.Context(), req.Filename, processOptions)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
             "success": false,
@@ -0,0 +1,199 @@
+// services/accounts.go - 关联的B站账号
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// AccountDefaults 账号级默认投稿设置
+type AccountDefaults struct {
+    Category       int    `json:"tid,omitempty"`             // 默认分区
+    Template       string `json:"template,omitempty"`        // 简介模板，{title} 替换为标题
+    BandwidthLimit int64  `json:"bandwidth_limit,omitempty"` // 上传限速（字节/秒），0表示不限速
+}
+
+// Apply 为未填写的投稿参数套用默认值
+func (d AccountDefaults) Apply(params *VideoUploadParams) {
+    if params.Category == 0 && d.Category > 0 {
+        params.Category = d.Category
+    }
+    if params.Description == "" && d.Template != "" {
+        params.Description = strings.ReplaceAll(d.Template, "{title}", params.Title)
+    }
+}
+
+// LinkedAccount 用户关联的B站账号
+type LinkedAccount struct {
+    ID       string          `json:"id"`
+    OwnerID  string          `json:"owner_id"`
+    UID      int64           `json:"uid"`
+    Username string          `json:"username"`
+    Kind     string          `json:"kind"` // 凭证类型，与会话类型相同
+    Defaults AccountDefaults `json:"defaults"`
+    LinkedAt time.Time       `json:"linked_at"`
+}
+
+// AccountStore 账号关联存储，持久化到JSON文件
+type AccountStore struct {
+    mu       sync.Mutex
+    path     string
+    accounts map[string]*LinkedAccount
+}
+
+// NewAccountStore 创建账号存储，path为空时只保存在内存中
+func NewAccountStore(path string) *AccountStore {
+    store := &AccountStore{
+        path:     path,
+        accounts: make(map[string]*LinkedAccount),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*LinkedAccount
+            if err := json.Unmarshal(data, &list); err == nil {
+                for _, account := range list {
+                    store.accounts[account.ID] = account
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Link 将B站账号关联到用户，已关联时只更新用户名
+func (s *AccountStore) Link(ownerID string, uid int64, username, kind string) (*LinkedAccount, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for _, account := range s.accounts {
+        if account.OwnerID == ownerID && account.UID == uid && account.Kind == kind {
+            account.Username = username
+            copied := *account
+            return &copied, s.persist()
+        }
+    }
+
+    id, err := RandomID(8)
+    if err != nil {
+        return nil, err
+    }
+    account := &LinkedAccount{
+        ID:       id,
+        OwnerID:  ownerID,
+        UID:      uid,
+        Username: username,
+        Kind:     kind,
+        LinkedAt: time.Now(),
+    }
+    s.accounts[id] = account
+
+    copied := *account
+    return &copied, s.persist()
+}
+
+// Get 获取用户关联的账号
+func (s *AccountStore) Get(ownerID, id string) (*LinkedAccount, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    account, ok := s.accounts[id]
+    if !ok || account.OwnerID != ownerID {
+        return nil, false
+    }
+    copied := *account
+    return &copied, true
+}
+
+// Find 按ID获取账号（不限用户，用于工作区共享的账号）
+func (s *AccountStore) Find(id string) (*LinkedAccount, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    account, ok := s.accounts[id]
+    if !ok {
+        return nil, false
+    }
+    copied := *account
+    return &copied, true
+}
+
+// List 列出用户关联的账号，按关联时间排序
+func (s *AccountStore) List(ownerID string) []LinkedAccount {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]LinkedAccount, 0)
+    for _, account := range s.accounts {
+        if account.OwnerID == ownerID {
+            list = append(list, *account)
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].LinkedAt.Before(list[j].LinkedAt)
+    })
+    return list
+}
+
+// IsLinked 是否仍有用户关联该B站账号
+func (s *AccountStore) IsLinked(uid int64, kind string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for _, account := range s.accounts {
+        if account.UID == uid && account.Kind == kind {
+            return true
+        }
+    }
+    return false
+}
+
+// SetDefaults 更新账号的默认投稿设置
+func (s *AccountStore) SetDefaults(ownerID, id string, defaults AccountDefaults) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    account, ok := s.accounts[id]
+    if !ok || account.OwnerID != ownerID {
+        return fmt.Errorf("账号不存在")
+    }
+    account.Defaults = defaults
+    return s.persist()
+}
+
+// Unlink 取消关联
+func (s *AccountStore) Unlink(ownerID, id string) (*LinkedAccount, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    account, ok := s.accounts[id]
+    if !ok || account.OwnerID != ownerID {
+        return nil, fmt.Errorf("账号不存在")
+    }
+    delete(s.accounts, id)
+    return account, s.persist()
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *AccountStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*LinkedAccount, 0, len(s.accounts))
+    for _, account := range s.accounts {
+        list = append(list, account)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,260 @@
+// services/apikeys.go - 供CI等机器客户端使用的API密钥
+package services
+
+import (
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// APIKeyPrefix API密钥的前缀，用于和JWT区分
+const APIKeyPrefix = "bu_"
+
+// API密钥的权限范围
+const (
+    ScopeUpload      = "upload"       // 上传视频、创建草稿和待审核稿件
+    ScopeProcess     = "process"      // 视频转码
+    ScopeReadHistory = "read:history" // 查看投稿历史、任务和稿件
+    ScopePublish     = "publish"      // 提交稿件（直接投稿、发布草稿、审核通过）
+)
+
+// ErrAPIKeyExpired API密钥已过期
+var ErrAPIKeyExpired = errors.New("API密钥已过期")
+
+// AllScopes 全部权限范围
+var AllScopes = []string{ScopeUpload, ScopeProcess, ScopeReadHistory, ScopePublish}
+
+// lastUsedPersistInterval 最近使用时间的落盘间隔，避免每个请求都写文件
+const lastUsedPersistInterval = time.Minute
+
+// APIKey API密钥，只保存密钥的SHA-256哈希
+type APIKey struct {
+    ID          string    `json:"id"`
+    Name        string    `json:"name"`
+    Hash        string    `json:"hash,omitempty"`
+    UserID      string    `json:"user_id"`                // 创建者，密钥以该用户身份访问
+    WorkspaceID string    `json:"workspace_id,omitempty"` // 非空时只能访问该工作区及其账号
+    Scopes      []string  `json:"scopes"`
+    CreatedAt   time.Time `json:"created_at"`
+    ExpiresAt   time.Time `json:"expires_at"`
+    LastUsedAt  time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope 是否具备指定权限
+func (k *APIKey) HasScope(scope string) bool {
+    for _, s := range k.Scopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// ValidScopes 校验权限范围，返回去重后的列表
+func ValidScopes(scopes []string) ([]string, error) {
+    if len(scopes) == 0 {
+        return nil, fmt.Errorf("至少需要一个权限范围")
+    }
+    seen := make(map[string]bool)
+    valid := make([]string, 0, len(scopes))
+    for _, scope := range scopes {
+        known := false
+        for _, s := range AllScopes {
+            if s == scope {
+                known = true
+                break
+            }
+        }
+        if !known {
+            return nil, fmt.Errorf("未知的权限范围: %s", scope)
+        }
+        if !seen[scope] {
+            seen[scope] = true
+            valid = append(valid, scope)
+        }
+    }
+    return valid, nil
+}
+
+// APIKeyStore API密钥存储，持久化到JSON文件
+type APIKeyStore struct {
+    mu        sync.Mutex
+    path      string
+    keys      map[string]*APIKey
+    persisted map[string]time.Time // 每个密钥最近使用时间的落盘时刻
+}
+
+// NewAPIKeyStore 创建API密钥存储，path为空时只保存在内存中
+func NewAPIKeyStore(path string) *APIKeyStore {
+    store := &APIKeyStore{
+        path:      path,
+        keys:      make(map[string]*APIKey),
+        persisted: make(map[string]time.Time),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*APIKey
+            if err := json.Unmarshal(data, &list); err == nil {
+                for _, key := range list {
+                    store.keys[key.ID] = key
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Create 创建密钥，返回只显示一次的明文密钥
+func (s *APIKeyStore) Create(key *APIKey, ttl time.Duration) (string, *APIKey, error) {
+    if strings.TrimSpace(key.Name) == "" {
+        return "", nil, fmt.Errorf("密钥名称不能为空")
+    }
+    if ttl <= 0 {
+        return "", nil, fmt.Errorf("有效期必须大于0")
+    }
+    scopes, err := ValidScopes(key.Scopes)
+    if err != nil {
+        return "", nil, err
+    }
+
+    id, err := RandomID(8)
+    if err != nil {
+        return "", nil, err
+    }
+    secret, err := RandomID(32)
+    if err != nil {
+        return "", nil, err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    saved := *key
+    saved.ID = id
+    saved.Hash = hashAPIKeySecret(secret)
+    saved.Scopes = scopes
+    saved.CreatedAt = time.Now()
+    saved.ExpiresAt = saved.CreatedAt.Add(ttl)
+    s.keys[id] = &saved
+    if err := s.persist(); err != nil {
+        delete(s.keys, id)
+        return "", nil, err
+    }
+
+    copied := saved
+    return APIKeyPrefix + id + "_" + secret, &copied, nil
+}
+
+// Authenticate 校验明文密钥并记录使用时间
+func (s *APIKeyStore) Authenticate(plaintext string) (*APIKey, error) {
+    id, secret, ok := strings.Cut(strings.TrimPrefix(plaintext, APIKeyPrefix), "_")
+    if !ok || !strings.HasPrefix(plaintext, APIKeyPrefix) {
+        return nil, fmt.Errorf("API密钥格式错误")
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    key, ok := s.keys[id]
+    if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKeySecret(secret))) != 1 {
+        return nil, fmt.Errorf("API密钥无效")
+    }
+    if time.Now().After(key.ExpiresAt) {
+        return nil, ErrAPIKeyExpired
+    }
+
+    key.LastUsedAt = time.Now()
+    if time.Since(s.persisted[id]) > lastUsedPersistInterval {
+        s.persisted[id] = key.LastUsedAt
+        s.persist()
+    }
+
+    copied := *key
+    copied.Scopes = append([]string(nil), key.Scopes...)
+    return &copied, nil
+}
+
+// List 列出用户创建的密钥，以及workspaceIDs中工作区的密钥
+func (s *APIKeyStore) List(userID string, workspaceIDs []string) []APIKey {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    inWorkspace := make(map[string]bool)
+    for _, id := range workspaceIDs {
+        inWorkspace[id] = true
+    }
+
+    list := make([]APIKey, 0)
+    for _, key := range s.keys {
+        if key.UserID == userID || (key.WorkspaceID != "" && inWorkspace[key.WorkspaceID]) {
+            copied := *key
+            copied.Hash = ""
+            list = append(list, copied)
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].CreatedAt.Before(list[j].CreatedAt)
+    })
+    return list
+}
+
+// Get 按ID获取密钥
+func (s *APIKeyStore) Get(id string) (*APIKey, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    key, ok := s.keys[id]
+    if !ok {
+        return nil, false
+    }
+    copied := *key
+    copied.Hash = ""
+    return &copied, true
+}
+
+// Revoke 吊销（删除）密钥
+func (s *APIKeyStore) Revoke(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, ok := s.keys[id]; !ok {
+        return fmt.Errorf("密钥不存在")
+    }
+    delete(s.keys, id)
+    delete(s.persisted, id)
+    return s.persist()
+}
+
+// hashAPIKeySecret 密钥是32字节随机数，SHA-256足以防止泄露的哈希被还原
+func hashAPIKeySecret(secret string) string {
+    sum := sha256.Sum256([]byte(secret))
+    return hex.EncodeToString(sum[:])
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *APIKeyStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*APIKey, 0, len(s.keys))
+    for _, key := range s.keys {
+        list = append(list, key)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestValidScopes(t *testing.T) {
+    tests := []struct {
+        name    string
+        scopes  []string
+        want    []string
+        wantErr bool
+    }{
+        {name: "单个权限", scopes: []string{ScopeUpload}, want: []string{ScopeUpload}},
+        {name: "去重并保持顺序", scopes: []string{ScopePublish, ScopeUpload, ScopePublish}, want: []string{ScopePublish, ScopeUpload}},
+        {name: "空列表", scopes: nil, wantErr: true},
+        {name: "未知权限", scopes: []string{ScopeUpload, "admin"}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := ValidScopes(tt.scopes)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("ValidScopes() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("ValidScopes() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestAPIKeyCreate(t *testing.T) {
+    tests := []struct {
+        name    string
+        key     APIKey
+        ttl     time.Duration
+        wantErr bool
+    }{
+        {name: "正常创建", key: APIKey{Name: "ci", UserID: "u1", Scopes: []string{ScopeUpload}}, ttl: time.Hour},
+        {name: "名称为空", key: APIKey{Name: " ", UserID: "u1", Scopes: []string{ScopeUpload}}, ttl: time.Hour, wantErr: true},
+        {name: "有效期为0", key: APIKey{Name: "ci", UserID: "u1", Scopes: []string{ScopeUpload}}, ttl: 0, wantErr: true},
+        {name: "无效权限", key: APIKey{Name: "ci", UserID: "u1", Scopes: []string{"root"}}, ttl: time.Hour, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store := NewAPIKeyStore("")
+            plaintext, key, err := store.Create(&tt.key, tt.ttl)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                return
+            }
+            if !strings.HasPrefix(plaintext, APIKeyPrefix+key.ID+"_") {
+                t.Errorf("明文密钥 = %q, 应以 %q 开头", plaintext, APIKeyPrefix+key.ID+"_")
+            }
+            secret := strings.TrimPrefix(plaintext, APIKeyPrefix+key.ID+"_")
+            if key.Hash != hashAPIKeySecret(secret) || strings.Contains(key.Hash, secret) {
+                t.Errorf("Hash = %q, 应为密钥的SHA-256", key.Hash)
+            }
+        })
+    }
+}
+
+func TestAPIKeyPersistsOnlyHash(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "api_keys.json")
+    store := NewAPIKeyStore(path)
+    plaintext, _, err := store.Create(&APIKey{Name: "ci", UserID: "u1", Scopes: []string{ScopeUpload}}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    secret := plaintext[strings.LastIndex(plaintext, "_")+1:]
+    if strings.Contains(string(data), secret) {
+        t.Fatal("文件中不应包含明文密钥")
+    }
+
+    // 重新加载后仍可认证
+    if _, err := NewAPIKeyStore(path).Authenticate(plaintext); err != nil {
+        t.Errorf("重新加载后Authenticate() error = %v", err)
+    }
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+    store := NewAPIKeyStore("")
+    plaintext, key, err := store.Create(&APIKey{
+        Name:   "ci",
+        UserID: "u1",
+        Scopes: []string{ScopeUpload, ScopeReadHistory},
+    }, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    expiredPlaintext, expired, err := store.Create(&APIKey{Name: "old", UserID: "u1", Scopes: []string{ScopeUpload}}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    store.keys[expired.ID].ExpiresAt = time.Now().Add(-time.Second)
+
+    tests := []struct {
+        name        string
+        plaintext   string
+        wantErr     bool
+        wantExpired bool
+    }{
+        {name: "有效密钥", plaintext: plaintext},
+        {name: "缺少前缀", plaintext: strings.TrimPrefix(plaintext, APIKeyPrefix), wantErr: true},
+        {name: "格式错误", plaintext: APIKeyPrefix + "abc", wantErr: true},
+        {name: "密钥错误", plaintext: APIKeyPrefix + key.ID + "_wrong", wantErr: true},
+        {name: "ID不存在", plaintext: APIKeyPrefix + "missing_" + plaintext[len(APIKeyPrefix+key.ID+"_"):], wantErr: true},
+        {name: "已过期", plaintext: expiredPlaintext, wantErr: true, wantExpired: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := store.Authenticate(tt.plaintext)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if errors.Is(err, ErrAPIKeyExpired) != tt.wantExpired {
+                t.Errorf("Authenticate() error = %v, wantExpired %v", err, tt.wantExpired)
+            }
+            if err != nil {
+                return
+            }
+            if got.ID != key.ID || got.LastUsedAt.IsZero() {
+                t.Errorf("Authenticate() = %+v", got)
+            }
+            if !got.HasScope(ScopeUpload) || !got.HasScope(ScopeReadHistory) || got.HasScope(ScopePublish) {
+                t.Errorf("Scopes = %v", got.Scopes)
+            }
+        })
+    }
+}
+
+func TestAPIKeyRevoke(t *testing.T) {
+    store := NewAPIKeyStore("")
+    plaintext, key, err := store.Create(&APIKey{Name: "ci", UserID: "u1", Scopes: []string{ScopeUpload}}, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := store.Revoke(key.ID); err != nil {
+        t.Fatalf("Revoke() error = %v", err)
+    }
+    if _, err := store.Authenticate(plaintext); err == nil {
+        t.Error("吊销后Authenticate() error = nil, want error")
+    }
+    if _, ok := store.Get(key.ID); ok {
+        t.Error("吊销后Get()仍能找到密钥")
+    }
+    if err := store.Revoke(key.ID); err == nil {
+        t.Error("重复吊销 error = nil, want error")
+    }
+}
+
+func TestAPIKeyList(t *testing.T) {
+    store := NewAPIKeyStore("")
+    create := func(name, userID, workspaceID string) {
+        if _, _, err := store.Create(&APIKey{Name: name, UserID: userID, WorkspaceID: workspaceID, Scopes: []string{ScopeUpload}}, time.Hour); err != nil {
+            t.Fatal(err)
+        }
+    }
+    create("mine", "u1", "")
+    create("team", "u2", "ws1")
+    create("other", "u2", "ws2")
+
+    list := store.List("u1", []string{"ws1"})
+    names := make([]string, 0, len(list))
+    for _, key := range list {
+        if key.Hash != "" {
+            t.Errorf("List()返回了密钥哈希: %s", key.Name)
+        }
+        names = append(names, key.Name)
+    }
+    if !reflect.DeepEqual(names, []string{"mine", "team"}) {
+        t.Errorf("List() = %v, want [mine team]", names)
+    }
+}
@@ -0,0 +1,254 @@
+// services/bilibili_error.go - B站接口错误：业务码、HTTP状态及处理方式分类
+package services
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// ErrorClass B站错误的处理方式
+type ErrorClass string
+
+const (
+    ErrorClassRetryable ErrorClass = "retryable" // 稍后重试可能成功：频率限制、风控、服务端故障
+    ErrorClassReauth    ErrorClass = "reauth"    // 凭证失效，需要重新授权或登录
+    ErrorClassUser      ErrorClass = "user"      // 稿件或参数有误，需要用户修改后重新提交
+    ErrorClassUnknown   ErrorClass = "unknown"   // 未知错误
+)
+
+// B站常见业务码
+const (
+    BiliCodeNotLoggedIn        = -101
+    BiliCodeCSRFFailed         = -111
+    BiliCodeRiskControl        = -352
+    BiliCodeBadRequest         = -400
+    BiliCodeForbidden          = -403
+    BiliCodeNotFound           = -404
+    BiliCodeRequestBlocked     = -412
+    BiliCodeServerError        = -500
+    BiliCodeServiceUnavailable = -503
+    BiliCodeTooFrequent        = -509
+    BiliCodeTidInvalid         = 21012
+    BiliCodeVideoNotFound      = 21020
+    BiliCodeTitleDuplicated    = 21070
+    BiliCodeSubmitTooFrequent  = 21540
+)
+
+// knownCode 已知业务码的分类、提示和建议重试间隔
+type knownCode struct {
+    class      ErrorClass
+    hint       string
+    retryAfter time.Duration
+}
+
+var knownCodes = map[int]knownCode{
+    BiliCodeNotLoggedIn:        {ErrorClassReauth, "B站账号未登录或登录已失效，请重新授权", 0},
+    BiliCodeCSRFFailed:         {ErrorClassReauth, "B站CSRF校验失败，请重新登录B站账号", 0},
+    BiliCodeRiskControl:        {ErrorClassRetryable, "触发B站风控，请稍后重试或先在浏览器中完成验证", 10 * time.Minute},
+    BiliCodeBadRequest:         {ErrorClassUser, "请求参数错误", 0},
+    BiliCodeForbidden:          {ErrorClassUser, "B站账号无权执行该操作", 0},
+    BiliCodeNotFound:           {ErrorClassUser, "请求的资源不存在", 0},
+    BiliCodeRequestBlocked:     {ErrorClassRetryable, "请求被B站拦截，请稍后重试", 5 * time.Minute},
+    BiliCodeServerError:        {ErrorClassRetryable, "B站服务异常，请稍后重试", 30 * time.Second},
+    BiliCodeServiceUnavailable: {ErrorClassRetryable, "B站服务暂时不可用，请稍后重试", 30 * time.Second},
+    BiliCodeTooFrequent:        {ErrorClassRetryable, "请求过于频繁，请稍后重试", time.Minute},
+    BiliCodeTidInvalid:         {ErrorClassUser, "分区ID无效，请选择正确的分区", 0},
+    BiliCodeVideoNotFound:      {ErrorClassUser, "视频文件不存在或已过期，请重新上传", 0},
+    BiliCodeTitleDuplicated:    {ErrorClassUser, "标题与已有稿件重复，请修改标题后重新提交", 0},
+    BiliCodeSubmitTooFrequent:  {ErrorClassRetryable, "投稿过于频繁，请稍后重试", 5 * time.Minute},
+}
+
+// BilibiliError B站接口返回的错误
+// Code为业务码（UPOS等只返回HTTP状态的接口为0），Endpoint为接口名称，如add、preupload、upos_part
+type BilibiliError struct {
+    Code       int
+    Message    string
+    HTTPStatus int
+    Endpoint   string
+}
+
+// Error 实现error接口，保留code=的格式便于日志检索
+func (e *BilibiliError) Error() string {
+    parts := []string{}
+    if e.Code != 0 {
+        parts = append(parts, fmt.Sprintf("code=%d", e.Code))
+    }
+    if e.HTTPStatus != 0 && e.HTTPStatus != http.StatusOK {
+        parts = append(parts, fmt.Sprintf("status=%d", e.HTTPStatus))
+    }
+    if e.Message != "" {
+        parts = append(parts, e.Message)
+    }
+    return fmt.Sprintf("B站接口%s返回错误: %s", e.Endpoint, strings.Join(parts, ", "))
+}
+
+// Class 错误的处理方式：优先按业务码分类，未知业务码按HTTP状态分类
+func (e *BilibiliError) Class() ErrorClass {
+    if known, ok := knownCodes[e.Code]; ok {
+        return known.class
+    }
+    switch {
+    case e.HTTPStatus == http.StatusUnauthorized:
+        return ErrorClassReauth
+    case e.HTTPStatus == http.StatusTooManyRequests, e.HTTPStatus >= 500:
+        return ErrorClassRetryable
+    case e.HTTPStatus >= 400:
+        return ErrorClassUser
+    }
+    return ErrorClassUnknown
+}
+
+// Retryable 稍后重试可能成功
+func (e *BilibiliError) Retryable() bool {
+    return e.Class() == ErrorClassRetryable
+}
+
+// NeedsReauth 需要重新授权或登录B站账号
+func (e *BilibiliError) NeedsReauth() bool {
+    return e.Class() == ErrorClassReauth
+}
+
+// UserError 需要用户修改稿件或参数
+func (e *BilibiliError) UserError() bool {
+    return e.Class() == ErrorClassUser
+}
+
+// Hint 面向用户的中文提示
+func (e *BilibiliError) Hint() string {
+    if known, ok := knownCodes[e.Code]; ok {
+        return known.hint
+    }
+    switch e.Class() {
+    case ErrorClassReauth:
+        return "B站凭证已失效，请重新授权"
+    case ErrorClassRetryable:
+        return "B站服务暂时不可用，请稍后重试"
+    case ErrorClassUser:
+        return "B站拒绝了该请求，请检查稿件信息"
+    }
+    return "B站接口返回未知错误"
+}
+
+// RetryAfter 可重试错误的建议等待时间，不可重试时为0
+func (e *BilibiliError) RetryAfter() time.Duration {
+    if !e.Retryable() {
+        return 0
+    }
+    if known, ok := knownCodes[e.Code]; ok && known.retryAfter > 0 {
+        return known.retryAfter
+    }
+    return 30 * time.Second
+}
+
+// AsBilibiliError 从错误链中取出BilibiliError
+func AsBilibiliError(err error) (*BilibiliError, bool) {
+    var biliErr *BilibiliError
+    if errors.As(err, &biliErr) {
+        return biliErr, true
+    }
+    return nil, false
+}
+
+// IsRetryable 错误链中的B站错误可重试
+func IsRetryable(err error) bool {
+    biliErr, ok := AsBilibiliError(err)
+    return ok && biliErr.Retryable()
+}
+
+// decodeAPIResponse 解析B站JSON响应（code/message格式）到v，并按接口和业务码计数
+// 业务码非0或HTTP状态非2xx时返回*BilibiliError，v可为nil
+func decodeAPIResponse(endpoint string, resp *http.Response, v interface{}) error {
+    err := parseAPIResponse(endpoint, resp, v)
+    observeBilibiliResponse(endpoint, err)
+    // WBI密钥每天更换，签名失效时下次请求重新获取
+    if biliErr, ok := AsBilibiliError(err); ok && biliErr.Code == BiliCodeRiskControl && isWbiRequest(resp.Request) {
+        DefaultWbiSigner().Invalidate()
+    }
+    return err
+}
+
+// parseAPIResponse 解析code/message格式的响应
+func parseAPIResponse(endpoint string, resp *http.Response, v interface{}) error {
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return fmt.Errorf("读取%s响应失败: %v", endpoint, err)
+    }
+
+    var envelope struct {
+        Code    int    `json:"code"`
+        Message string `json:"message"`
+        Msg     string `json:"msg"`
+    }
+    if err := json.Unmarshal(body, &envelope); err != nil {
+        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+            return &BilibiliError{Endpoint: endpoint, HTTPStatus: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+        }
+        return fmt.Errorf("解析%s响应失败: %v", endpoint, err)
+    }
+    if envelope.Message == "" {
+        envelope.Message = envelope.Msg
+    }
+    if envelope.Code != 0 || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return &BilibiliError{
+            Code:       envelope.Code,
+            Message:    envelope.Message,
+            HTTPStatus: resp.StatusCode,
+            Endpoint:   endpoint,
+        }
+    }
+
+    if v == nil {
+        return nil
+    }
+    if err := json.Unmarshal(body, v); err != nil {
+        return fmt.Errorf("解析%s响应失败: %v", endpoint, err)
+    }
+    return nil
+}
+
+// decodeUposResponse 解析预上传和UPOS的JSON响应（OK字段为1表示成功）到v，并按接口计数
+func decodeUposResponse(endpoint string, resp *http.Response, v interface{}) error {
+    err := parseUposResponse(endpoint, resp, v)
+    observeBilibiliResponse(endpoint, err)
+    return err
+}
+
+// parseUposResponse 解析OK格式的响应
+func parseUposResponse(endpoint string, resp *http.Response, v interface{}) error {
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return fmt.Errorf("读取%s响应失败: %v", endpoint, err)
+    }
+
+    var envelope struct {
+        OK      int    `json:"OK"`
+        Code    int    `json:"code"`
+        Message string `json:"message"`
+    }
+    jsonErr := json.Unmarshal(body, &envelope)
+    if resp.StatusCode != http.StatusOK || (jsonErr == nil && envelope.OK != 1) {
+        message := envelope.Message
+        if message == "" && resp.StatusCode != http.StatusOK {
+            message = http.StatusText(resp.StatusCode)
+        } else if message == "" {
+            message = "上传接口返回失败"
+        }
+        return &BilibiliError{Code: envelope.Code, Message: message, HTTPStatus: resp.StatusCode, Endpoint: endpoint}
+    }
+    if jsonErr != nil {
+        return fmt.Errorf("解析%s响应失败: %v", endpoint, jsonErr)
+    }
+
+    if v == nil {
+        return nil
+    }
+    if err := json.Unmarshal(body, v); err != nil {
+        return fmt.Errorf("解析%s响应失败: %v", endpoint, err)
+    }
+    return nil
+}
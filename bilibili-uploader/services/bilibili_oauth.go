@@ -3,15 +3,22 @@ package services
 
 import (
     "bytes"
+    "context"
+    "encoding/base64"
     "encoding/json"
     "fmt"
     "io"
+    "log/slog"
     "mime/multipart"
     "net/http"
     "net/url"
     "os"
     "path/filepath"
+    "strings"
     "time"
+    
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
 )
 
 // BilibiliOAuth B站OAuth服务
@@ -20,16 +27,29 @@ type BilibiliOAuth struct {
     ClientSecret string
     RedirectURI  string
     BaseURL      string
+    PassportURL  string       // 授权页面和令牌接口所在的passport域名
+    HTTPClient   *http.Client // 出站HTTP客户端，为空时使用DefaultHTTPClient
 }
 
-// NewBilibiliOAuth 创建B站OAuth服务
+// NewBilibiliOAuth 创建B站OAuth服务，使用默认的接口域名和HTTP客户端
 func NewBilibiliOAuth(clientID, clientSecret, redirectURI string) *BilibiliOAuth {
+    endpoints := DefaultBilibiliEndpoints()
     return &BilibiliOAuth{
         ClientID:     clientID,
         ClientSecret: clientSecret,
         RedirectURI:  redirectURI,
-        BaseURL:      "https://api.bilibili.com",
+        BaseURL:      endpoints.API,
+        PassportURL:  endpoints.Passport,
+        HTTPClient:   DefaultHTTPClient(),
+    }
+}
+
+// client 出站HTTP客户端
+func (b *BilibiliOAuth) client() *http.Client {
+    if b.HTTPClient != nil {
+        return b.HTTPClient
     }
+    return DefaultHTTPClient()
 }
 
 // TokenResponse OAuth token响应
@@ -47,8 +67,23 @@ type UserInfo struct {
     Face     string `json:"face"`
 }
 
-// ExchangeCode 用授权码换取访问令牌
-func (b *BilibiliOAuth) ExchangeCode(code string) (*TokenResponse, error) {
+// AuthURL 构建授权页面地址，codeChallenge非空时启用PKCE（S256）
+func (b *BilibiliOAuth) AuthURL(state, codeChallenge string) string {
+    params := url.Values{}
+    params.Set("client_id", b.ClientID)
+    params.Set("response_type", "code")
+    params.Set("redirect_uri", b.RedirectURI)
+    params.Set("scope", "video-upload")
+    params.Set("state", state)
+    if codeChallenge != "" {
+        params.Set("code_challenge", codeChallenge)
+        params.Set("code_challenge_method", "S256")
+    }
+    return b.PassportURL + "/register/pc_oauth2.html?" + params.Encode()
+}
+
+// ExchangeCode 用授权码换取访问令牌，codeVerifier为授权时PKCE的code_verifier（未使用PKCE时为空）
+func (b *BilibiliOAuth) ExchangeCode(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
     // 构建请求参数
     data := url.Values{}
     data.Set("client_id", b.ClientID)
@@ -56,33 +91,107 @@ func (b *BilibiliOAuth) ExchangeCode(code string) (*TokenResponse, error) {
     data.Set("grant_type", "authorization_code")
     data.Set("code", code)
     data.Set("redirect_uri", b.RedirectURI)
+    if codeVerifier != "" {
+        data.Set("code_verifier", codeVerifier)
+    }
     
     // 发送请求
-    resp, err := http.PostForm("https://passport.bilibili.com/api/oauth2/access_token", data)
+    resp, err := b.postForm(ctx, b.PassportURL+"/api/oauth2/access_token", data)
     if err != nil {
         return nil, fmt.Errorf("请求token失败: %v", err)
     }
     defer resp.Body.Close()
     
-    // 解析响应
+    // 解析响应，错误时返回业务码和message
+    var tokenResp TokenResponse
+    if err := decodeAPIResponse("token", resp, &tokenResp); err != nil {
+        return nil, fmt.Errorf("换取token失败: %w", err)
+    }
+    if tokenResp.AccessToken == "" {
+        return nil, fmt.Errorf("换取token失败: %w", &BilibiliError{Message: "响应中没有access_token", HTTPStatus: resp.StatusCode, Endpoint: "token"})
+    }
+    
+    return &tokenResp, nil
+}
+
+// postForm 以表单提交POST请求，请求随ctx取消
+func (b *BilibiliOAuth) postForm(ctx context.Context, endpoint string, data url.Values) (*http.Response, error) {
+    req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(data.Encode()))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    return b.client().Do(req)
+}
+
+// RefreshToken 用refresh_token换取新的访问令牌
+func (b *BilibiliOAuth) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+    data := url.Values{}
+    data.Set("client_id", b.ClientID)
+    data.Set("client_secret", b.ClientSecret)
+    data.Set("grant_type", "refresh_token")
+    data.Set("refresh_token", refreshToken)
+    
+    resp, err := b.postForm(ctx, b.PassportURL+"/api/oauth2/refresh_token", data)
+    if err != nil {
+        return nil, fmt.Errorf("刷新token失败: %v", err)
+    }
+    defer resp.Body.Close()
+    
     var tokenResp TokenResponse
-    if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-        return nil, fmt.Errorf("解析token响应失败: %v", err)
+    if err := decodeAPIResponse("refresh", resp, &tokenResp); err != nil {
+        return nil, fmt.Errorf("刷新token失败: %w", err)
+    }
+    if tokenResp.AccessToken == "" {
+        return nil, fmt.Errorf("刷新token失败: %w", &BilibiliError{Message: "响应中没有access_token", HTTPStatus: resp.StatusCode, Endpoint: "refresh"})
     }
     
     return &tokenResp, nil
 }
 
+// RevokeToken 在B站注销访问令牌（App端 /x/passport-login/revoke），auth需携带该令牌
+func (b *BilibiliOAuth) RevokeToken(ctx context.Context, auth Authenticator) error {
+    req, err := http.NewRequestWithContext(ctx, "POST", b.PassportURL+"/x/passport-login/revoke", strings.NewReader(""))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    
+    if err := auth.Authenticate(req); err != nil {
+        return err
+    }
+    
+    client := b.client()
+    resp, err := client.Do(req)
+    if err != nil {
+        return fmt.Errorf("注销令牌失败: %v", err)
+    }
+    defer resp.Body.Close()
+    
+    if err := decodeAPIResponse("revoke", resp, nil); err != nil {
+        return fmt.Errorf("注销令牌失败: %w", err)
+    }
+    
+    return nil
+}
+
 // GetUserInfo 获取用户信息
-func (b *BilibiliOAuth) GetUserInfo(accessToken string) (*UserInfo, error) {
-    req, err := http.NewRequest("GET", b.BaseURL+"/x/web-interface/nav", nil)
+func (b *BilibiliOAuth) GetUserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+    return b.FetchUserInfo(ctx, &BearerAuth{AccessToken: accessToken})
+}
+
+// FetchUserInfo 使用任意认证方式调用nav接口获取用户信息
+func (b *BilibiliOAuth) FetchUserInfo(ctx context.Context, auth Authenticator) (*UserInfo, error) {
+    req, err := http.NewRequestWithContext(ctx, "GET", b.BaseURL+"/x/web-interface/nav", nil)
     if err != nil {
         return nil, err
     }
     
-    req.Header.Set("Authorization", "Bearer "+accessToken)
+    if err := auth.Authenticate(req); err != nil {
+        return nil, err
+    }
     
-    client := &http.Client{}
+    client := b.client()
     resp, err := client.Do(req)
     if err != nil {
         return nil, err
@@ -90,33 +199,105 @@ func (b *BilibiliOAuth) GetUserInfo(accessToken string) (*UserInfo, error) {
     defer resp.Body.Close()
     
     var result struct {
-        Code int      `json:"code"`
-        Data UserInfo `json:"data"`
+        Data struct {
+            IsLogin bool   `json:"isLogin"`
+            Mid     int64  `json:"mid"`
+            Uname   string `json:"uname"`
+            Face    string `json:"face"`
+        } `json:"data"`
     }
     
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return nil, err
+    if err := decodeAPIResponse("nav", resp, &result); err != nil {
+        return nil, fmt.Errorf("获取用户信息失败: %w", err)
     }
-    
-    if result.Code != 0 {
-        return nil, fmt.Errorf("获取用户信息失败: code=%d", result.Code)
+    if !result.Data.IsLogin {
+        return nil, fmt.Errorf("获取用户信息失败: %w", &BilibiliError{Code: BiliCodeNotLoggedIn, Message: "账号未登录", HTTPStatus: resp.StatusCode, Endpoint: "nav"})
     }
     
-    return &result.Data, nil
+    return &UserInfo{
+        UID:      result.Data.Mid,
+        Username: result.Data.Uname,
+        Face:     result.Data.Face,
+    }, nil
 }
 
 // BilibiliUploader B站视频上传器
 type BilibiliUploader struct {
-    AccessToken string
-    BaseURL     string
+    Auth           Authenticator    // 认证方式：OAuth令牌、网页Cookie或appkey签名
+    BaseURL        string
+    BandwidthLimit int64            // 上传限速（字节/秒），0表示不限速
+    PartRetries    int              // 分片遇到网络错误或可重试的B站错误时的重试次数，0表示不重试
+    Checkpoints    *CheckpointStore // 上传断点，为空时中断的上传需要从头开始
+    HTTPClient     *http.Client     // 出站HTTP客户端，为空时使用DefaultHTTPClient
 }
 
-// NewBilibiliUploader 创建上传器
+// partRetryBackoff 分片第一次重试前的等待时间，之后每次翻倍
+const partRetryBackoff = time.Second
+
+// NewBilibiliUploader 使用OAuth访问令牌创建上传器
 func NewBilibiliUploader(accessToken string) *BilibiliUploader {
+    return NewBilibiliUploaderWithAuth(&BearerAuth{AccessToken: accessToken})
+}
+
+// NewBilibiliUploaderWithCookie 使用网页Cookie凭证创建上传器
+func NewBilibiliUploaderWithCookie(cred *WebCredential) *BilibiliUploader {
+    return NewBilibiliUploaderWithAuth(&CookieAuth{Credential: cred})
+}
+
+// NewBilibiliUploaderWithAuth 使用任意认证方式创建上传器，使用默认的接口域名和HTTP客户端
+func NewBilibiliUploaderWithAuth(auth Authenticator) *BilibiliUploader {
     return &BilibiliUploader{
-        AccessToken: accessToken,
-        BaseURL:     "https://member.bilibili.com",
+        Auth:       auth,
+        BaseURL:    DefaultBilibiliEndpoints().Member,
+        HTTPClient: DefaultHTTPClient(),
+    }
+}
+
+// client 出站HTTP客户端
+func (u *BilibiliUploader) client() *http.Client {
+    if u.HTTPClient != nil {
+        return u.HTTPClient
     }
+    return DefaultHTTPClient()
+}
+
+// do 发送带认证的请求，返回401时刷新凭证并重试一次
+func (u *BilibiliUploader) do(req *http.Request) (*http.Response, error) {
+    if err := u.Auth.Authenticate(req); err != nil {
+        return nil, err
+    }
+    
+    client := u.client()
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    
+    refresher, ok := u.Auth.(Refresher)
+    if resp.StatusCode != http.StatusUnauthorized || !ok {
+        return resp, nil
+    }
+    if req.Body != nil && req.GetBody == nil {
+        return resp, nil
+    }
+    resp.Body.Close()
+    
+    if err := refresher.Refresh(req.Context()); err != nil {
+        return nil, fmt.Errorf("凭证已失效且刷新失败: %w", err)
+    }
+    
+    retry := req.Clone(req.Context())
+    if req.GetBody != nil {
+        body, err := req.GetBody()
+        if err != nil {
+            return nil, err
+        }
+        retry.Body = body
+    }
+    if err := u.Auth.Authenticate(retry); err != nil {
+        return nil, err
+    }
+    return client.Do(retry)
 }
 
 // VideoUploadParams 视频上传参数
@@ -125,42 +306,130 @@ type VideoUploadParams struct {
     Description string   `json:"desc"`        // 简介
     Tags        []string `json:"tags"`        // 标签
     Category    int      `json:"tid"`         // 分区ID
-    Cover       string   `json:"cover"`       // 封面URL
+    Cover       string   `json:"cover"`       // 封面URL或本地图片路径
     Source      string   `json:"source"`      // 来源
     Copyright   int      `json:"copyright"`   // 1:自制 2:转载
 }
 
 // UploadVideo 上传视频到B站
 func (u *BilibiliUploader) UploadVideo(videoPath string, params VideoUploadParams) (string, error) {
+    return u.UploadVideoContext(context.Background(), videoPath, params)
+}
+
+// UploadVideoContext 上传视频到B站，预上传、分片和提交各阶段的span挂在ctx中的span下
+func (u *BilibiliUploader) UploadVideoContext(ctx context.Context, videoPath string, params VideoUploadParams) (bvid string, err error) {
+    ctx, span := startSpan(ctx, "bilibili.upload_video")
+    defer func() { endSpan(span, err) }()
+    
+    uploadInfo, err := u.UploadFileContext(ctx, videoPath)
+    if err != nil {
+        return "", err
+    }
+    return u.SubmitContext(ctx, uploadInfo.Filename, params)
+}
+
+// UploadFile 预上传并分片上传视频文件，返回的Filename用于之后提交稿件
+func (u *BilibiliUploader) UploadFile(videoPath string) (*UploadInfo, error) {
+    return u.UploadFileContext(context.Background(), videoPath)
+}
+
+// UploadFileContext 预上传并分片上传视频文件，ctx用于链路追踪和日志关联
+// ctx取消时停止上传；配置了Checkpoints时记录已完成的分片，之后上传同一文件从断点继续
+func (u *BilibiliUploader) UploadFileContext(ctx context.Context, videoPath string) (info *UploadInfo, err error) {
+    ctx, span := startSpan(ctx, "bilibili.upload_file", attribute.String("file.name", filepath.Base(videoPath)))
+    activeUploads.Inc()
+    defer func() {
+        activeUploads.Dec()
+        if err != nil {
+            uploads.WithLabelValues("failure").Inc()
+        } else {
+            uploads.WithLabelValues("success").Inc()
+        }
+        endSpan(span, err)
+    }()
+    
+    // 从断点继续：UPOS会话失效等不可重试的错误时放弃断点，重新上传
+    if u.Checkpoints != nil {
+        if checkpoint, ok := u.Checkpoints.Get(videoPath); ok {
+            slog.InfoContext(ctx, "从断点继续上传", "file", videoPath, "parts_done", checkpoint.Parts)
+            span.SetAttributes(attribute.Int64("upload.resumed_parts", checkpoint.Parts))
+            uploadInfo := checkpoint.Info
+            err := u.uploadChunks(ctx, videoPath, &uploadInfo, checkpoint.Parts)
+            if err == nil {
+                return &uploadInfo, nil
+            }
+            if biliErr, ok := AsBilibiliError(err); !ok || biliErr.Retryable() || ctx.Err() != nil {
+                return nil, fmt.Errorf("上传视频失败: %w", err)
+            }
+            slog.WarnContext(ctx, "断点已失效，重新上传", "file", videoPath, "error", err)
+            u.Checkpoints.Delete(videoPath)
+        }
+    }
+    
     // Step 1: 预上传，获取上传地址
-    uploadInfo, err := u.preUpload(videoPath)
+    uploadInfo, err := u.preUpload(ctx, videoPath)
     if err != nil {
-        return "", fmt.Errorf("预上传失败: %v", err)
+        return nil, fmt.Errorf("预上传失败: %w", err)
+    }
+    if err := u.initUpload(ctx, uploadInfo); err != nil {
+        return nil, fmt.Errorf("上传视频失败: %w", err)
     }
     
     // Step 2: 分片上传视频文件
-    if err := u.uploadChunks(videoPath, uploadInfo); err != nil {
-        return "", fmt.Errorf("上传视频失败: %v", err)
+    if err := u.uploadChunks(ctx, videoPath, uploadInfo, 0); err != nil {
+        return nil, fmt.Errorf("上传视频失败: %w", err)
     }
     
-    // Step 3: 提交稿件
-    bvid, err := u.submitVideo(uploadInfo.Filename, params)
+    return uploadInfo, nil
+}
+
+// Submit 用已上传的服务端文件名提交稿件
+func (u *BilibiliUploader) Submit(filename string, params VideoUploadParams) (string, error) {
+    return u.SubmitContext(context.Background(), filename, params)
+}
+
+// SubmitContext 用已上传的服务端文件名提交稿件，ctx用于链路追踪和日志关联
+func (u *BilibiliUploader) SubmitContext(ctx context.Context, filename string, params VideoUploadParams) (bvid string, err error) {
+    ctx, span := startSpan(ctx, "bilibili.submit", attribute.Int("bilibili.tid", params.Category))
+    defer func() { endSpan(span, err) }()
+    
+    // Step 3: 上传本地封面
+    if params.Cover != "" && !strings.HasPrefix(params.Cover, "http") {
+        coverURL, err := u.UploadCoverContext(ctx, params.Cover)
+        if err != nil {
+            return "", fmt.Errorf("上传封面失败: %w", err)
+        }
+        params.Cover = coverURL
+    }
+    
+    // Step 4: 提交稿件
+    bvid, err = u.submitVideo(ctx, filename, params)
     if err != nil {
-        return "", fmt.Errorf("提交稿件失败: %v", err)
+        return "", fmt.Errorf("提交稿件失败: %w", err)
     }
+    span.SetAttributes(attribute.String("bilibili.bvid", bvid))
     
     return bvid, nil
 }
 
-// UploadInfo 上传信息
+// UploadInfo 上传信息（UPOS）
 type UploadInfo struct {
-    URL      string `json:"url"`
-    Filename string `json:"filename"`
-    BizID    int    `json:"biz_id"`
+    OK        int    `json:"OK"`
+    Auth      string `json:"auth"` // X-Upos-Auth
+    BizID     int64  `json:"biz_id"`
+    ChunkSize int64  `json:"chunk_size"`
+    Endpoint  string `json:"endpoint"` // 如 //upos-cs-upcdnbda2.bilivideo.com
+    UposURI   string `json:"upos_uri"` // 如 upos://ugcboss/xxx.mp4
+    URL       string `json:"url"`      // 上传地址，由endpoint和upos_uri拼接
+    Filename  string `json:"filename"` // 提交稿件时使用的服务端文件名
+    UploadID  string `json:"upload_id"`
 }
 
 // preUpload 预上传
-func (u *BilibiliUploader) preUpload(videoPath string) (*UploadInfo, error) {
+func (u *BilibiliUploader) preUpload(ctx context.Context, videoPath string) (info *UploadInfo, err error) {
+    ctx, span := startSpan(ctx, "bilibili.preupload")
+    defer func() { endSpan(span, err) }()
+    
     file, err := os.Open(videoPath)
     if err != nil {
         return nil, err
@@ -176,32 +445,76 @@ func (u *BilibiliUploader) preUpload(videoPath string) (*UploadInfo, error) {
     params.Set("r", "upos")
     params.Set("profile", "ugcupos/bup")
     
-    req, err := http.NewRequest("GET", 
+    req, err := http.NewRequestWithContext(ctx, "GET", 
         fmt.Sprintf("%s/preupload?%s", u.BaseURL, params.Encode()), nil)
     if err != nil {
         return nil, err
     }
     
-    req.Header.Set("Authorization", "Bearer "+u.AccessToken)
-    
-    client := &http.Client{}
-    resp, err := client.Do(req)
+    resp, err := u.do(req)
     if err != nil {
         return nil, err
     }
     defer resp.Body.Close()
     
     var uploadInfo UploadInfo
-    if err := json.NewDecoder(resp.Body).Decode(&uploadInfo); err != nil {
+    if err := decodeUposResponse("preupload", resp, &uploadInfo); err != nil {
         return nil, err
     }
     
-    uploadInfo.Filename = filepath.Base(videoPath)
+    // upos://ugcboss/n123.mp4 -> https://endpoint/ugcboss/n123.mp4
+    uposPath := strings.TrimPrefix(uploadInfo.UposURI, "upos://")
+    if uploadInfo.URL == "" {
+        endpoint := uploadInfo.Endpoint
+        if strings.HasPrefix(endpoint, "//") {
+            endpoint = "https:" + endpoint
+        }
+        uploadInfo.URL = endpoint + "/" + uposPath
+    }
+    base := filepath.Base(uposPath)
+    uploadInfo.Filename = strings.TrimSuffix(base, filepath.Ext(base))
+    if uploadInfo.ChunkSize <= 0 {
+        uploadInfo.ChunkSize = 5 * 1024 * 1024
+    }
+    span.SetAttributes(attribute.Int64("file.size", fileInfo.Size()), attribute.Int64("upload.chunk_size", uploadInfo.ChunkSize))
+    
     return &uploadInfo, nil
 }
 
-// uploadChunks 分片上传
-func (u *BilibiliUploader) uploadChunks(videoPath string, uploadInfo *UploadInfo) error {
+// initUpload 初始化分片上传，获取upload_id
+func (u *BilibiliUploader) initUpload(ctx context.Context, uploadInfo *UploadInfo) (err error) {
+    ctx, span := startSpan(ctx, "bilibili.upos_init")
+    defer func() { endSpan(span, err) }()
+    
+    req, err := http.NewRequestWithContext(ctx, "POST", uploadInfo.URL+"?uploads&output=json", nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("X-Upos-Auth", uploadInfo.Auth)
+    
+    client := u.client()
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    
+    var result struct {
+        UploadID string `json:"upload_id"`
+    }
+    if err := decodeUposResponse("upos_init", resp, &result); err != nil {
+        return fmt.Errorf("初始化上传失败: %w", err)
+    }
+    if result.UploadID == "" {
+        return fmt.Errorf("初始化上传失败: %w", &BilibiliError{Message: "响应中没有upload_id", HTTPStatus: resp.StatusCode, Endpoint: "upos_init"})
+    }
+    
+    uploadInfo.UploadID = result.UploadID
+    return nil
+}
+
+// uploadChunks 从第startPart+1片开始分片上传并合并，中断时保存断点
+func (u *BilibiliUploader) uploadChunks(ctx context.Context, videoPath string, uploadInfo *UploadInfo, startPart int64) error {
     file, err := os.Open(videoPath)
     if err != nil {
         return err
@@ -211,11 +524,45 @@ func (u *BilibiliUploader) uploadChunks(videoPath string, uploadInfo *UploadInfo
     fileInfo, _ := file.Stat()
     fileSize := fileInfo.Size()
     
-    // 分片大小：5MB
-    chunkSize := int64(5 * 1024 * 1024)
+    chunkSize := uploadInfo.ChunkSize
     chunks := (fileSize + chunkSize - 1) / chunkSize
+    uploadStart := time.Now()
+    
+    partsCtx, partsSpan := startSpan(ctx, "bilibili.upload_parts", attribute.Int64("upload.parts", chunks), attribute.Int64("upload.start_part", startPart+1))
+    done, err := u.uploadParts(partsCtx, file, uploadInfo, fileSize, chunks, startPart)
+    endSpan(partsSpan, err)
+    if err != nil {
+        if u.Checkpoints != nil {
+            if saveErr := u.Checkpoints.Save(videoPath, uploadInfo, done); saveErr != nil {
+                slog.WarnContext(ctx, "保存上传断点失败", "file", videoPath, "error", saveErr)
+            } else {
+                slog.InfoContext(ctx, "已保存上传断点", "file", videoPath, "parts_done", done, "parts", chunks)
+            }
+        }
+        return err
+    }
+    
+    if err := u.completeUpload(ctx, uploadInfo, videoPath, chunks); err != nil {
+        return err
+    }
+    if u.Checkpoints != nil {
+        u.Checkpoints.Delete(videoPath)
+    }
+    if elapsed := time.Since(uploadStart).Seconds(); elapsed > 0 {
+        uploadThroughput.Observe(float64(fileSize-startPart*chunkSize) / elapsed)
+    }
+    return nil
+}
+
+// uploadParts 依次读取并上传分片，返回已完成的分片数
+func (u *BilibiliUploader) uploadParts(ctx context.Context, file *os.File, uploadInfo *UploadInfo, fileSize, chunks, startPart int64) (int64, error) {
+    chunkSize := uploadInfo.ChunkSize
     
-    for i := int64(0); i < chunks; i++ {
+    for i := startPart; i < chunks; i++ {
+        if err := ctx.Err(); err != nil {
+            return i, err
+        }
+        
         start := i * chunkSize
         end := start + chunkSize
         if end > fileSize {
@@ -226,46 +573,194 @@ func (u *BilibiliUploader) uploadChunks(videoPath string, uploadInfo *UploadInfo
         chunkData := make([]byte, end-start)
         _, err := file.ReadAt(chunkData, start)
         if err != nil && err != io.EOF {
-            return fmt.Errorf("读取分片失败: %v", err)
+            return i, fmt.Errorf("读取分片失败: %v", err)
         }
         
         // 上传分片
-        if err := u.uploadChunk(uploadInfo.URL, chunkData, i+1, chunks); err != nil {
-            return fmt.Errorf("上传分片%d失败: %v", i+1, err)
+        chunkStart := time.Now()
+        if err := u.uploadChunkWithRetry(ctx, uploadInfo, chunkData, i, chunks, start, fileSize); err != nil {
+            return i, fmt.Errorf("上传分片%d失败: %w", i+1, err)
+        }
+        uploadBytes.Add(float64(len(chunkData)))
+        
+        // 限速：分片上传过快时等待，使平均速度不超过BandwidthLimit
+        if u.BandwidthLimit > 0 {
+            minDuration := time.Duration(int64(len(chunkData)) * int64(time.Second) / u.BandwidthLimit)
+            if elapsed := time.Since(chunkStart); elapsed < minDuration {
+                if err := sleepContext(ctx, minDuration-elapsed); err != nil {
+                    return i + 1, err
+                }
+            }
         }
     }
+    return chunks, nil
+}
+
+// uploadChunkWithRetry 上传单个分片，网络错误或可重试的B站错误按PartRetries重试
+func (u *BilibiliUploader) uploadChunkWithRetry(ctx context.Context, uploadInfo *UploadInfo, data []byte, chunk, chunks, start, total int64) (err error) {
+    ctx, span := startSpan(ctx, "bilibili.upload_part",
+        attribute.Int64("upload.part", chunk+1),
+        attribute.Int("upload.part_size", len(data)),
+    )
+    defer func() { endSpan(span, err) }()
+    
+    backoff := partRetryBackoff
+    for attempt := 0; ; attempt++ {
+        err := u.uploadChunk(ctx, uploadInfo, data, chunk, chunks, start, total)
+        if err == nil {
+            return nil
+        }
+        if biliErr, ok := AsBilibiliError(err); attempt >= u.PartRetries || (ok && !biliErr.Retryable()) || ctx.Err() != nil {
+            return err
+        }
+        uploadPartRetries.Inc()
+        span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1), attribute.String("error", err.Error())))
+        if err := sleepContext(ctx, backoff); err != nil {
+            return err
+        }
+        backoff *= 2
+    }
+}
+
+// sleepContext 等待d，ctx取消时提前返回ctx的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
     
-    return nil
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
 }
 
 // uploadChunk 上传单个分片
-func (u *BilibiliUploader) uploadChunk(uploadURL string, data []byte, partNum, totalParts int64) error {
-    url := fmt.Sprintf("%s?partNumber=%d&parts=%d", uploadURL, partNum, totalParts)
-    
-    req, err := http.NewRequest("PUT", url, bytes.NewReader(data))
+func (u *BilibiliUploader) uploadChunk(ctx context.Context, uploadInfo *UploadInfo, data []byte, chunk, chunks, start, total int64) error {
+    params := url.Values{}
+    params.Set("partNumber", fmt.Sprintf("%d", chunk+1))
+    params.Set("uploadId", uploadInfo.UploadID)
+    params.Set("chunk", fmt.Sprintf("%d", chunk))
+    params.Set("chunks", fmt.Sprintf("%d", chunks))
+    params.Set("size", fmt.Sprintf("%d", len(data)))
+    params.Set("start", fmt.Sprintf("%d", start))
+    params.Set("end", fmt.Sprintf("%d", start+int64(len(data))))
+    params.Set("total", fmt.Sprintf("%d", total))
+    
+    req, err := http.NewRequestWithContext(ctx, "PUT", uploadInfo.URL+"?"+params.Encode(), bytes.NewReader(data))
     if err != nil {
         return err
     }
     
     req.Header.Set("Content-Type", "application/octet-stream")
-    req.Header.Set("Authorization", "Bearer "+u.AccessToken)
+    req.Header.Set("X-Upos-Auth", uploadInfo.Auth)
     
-    client := &http.Client{Timeout: 30 * time.Second}
+    client := u.client()
     resp, err := client.Do(req)
     if err != nil {
         return err
     }
     defer resp.Body.Close()
     
+    // 分片接口成功时返回纯文本，只检查HTTP状态
     if resp.StatusCode != http.StatusOK {
-        return fmt.Errorf("上传分片失败: status=%d", resp.StatusCode)
+        err := &BilibiliError{Message: http.StatusText(resp.StatusCode), HTTPStatus: resp.StatusCode, Endpoint: "upos_part"}
+        observeBilibiliResponse("upos_part", err)
+        return err
+    }
+    observeBilibiliResponse("upos_part", nil)
+    
+    return nil
+}
+
+// completeUpload 通知服务端合并分片
+func (u *BilibiliUploader) completeUpload(ctx context.Context, uploadInfo *UploadInfo, videoPath string, chunks int64) (err error) {
+    ctx, span := startSpan(ctx, "bilibili.upos_complete")
+    defer func() { endSpan(span, err) }()
+    
+    parts := make([]map[string]interface{}, 0, chunks)
+    for i := int64(1); i <= chunks; i++ {
+        parts = append(parts, map[string]interface{}{
+            "partNumber": i,
+            "eTag":       "etag",
+        })
+    }
+    body, err := json.Marshal(map[string]interface{}{"parts": parts})
+    if err != nil {
+        return err
+    }
+    
+    params := url.Values{}
+    params.Set("output", "json")
+    params.Set("name", filepath.Base(videoPath))
+    params.Set("profile", "ugcupos/bup")
+    params.Set("uploadId", uploadInfo.UploadID)
+    params.Set("biz_id", fmt.Sprintf("%d", uploadInfo.BizID))
+    
+    req, err := http.NewRequestWithContext(ctx, "POST", uploadInfo.URL+"?"+params.Encode(), bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-Upos-Auth", uploadInfo.Auth)
+    
+    client := u.client()
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    
+    if err := decodeUposResponse("upos_complete", resp, nil); err != nil {
+        return fmt.Errorf("合并分片失败: %w", err)
     }
     
     return nil
 }
 
+// UploadCover 上传本地封面图片，返回封面URL
+func (u *BilibiliUploader) UploadCover(coverPath string) (string, error) {
+    return u.UploadCoverContext(context.Background(), coverPath)
+}
+
+// UploadCoverContext 上传本地封面图片，ctx用于链路追踪和日志关联
+func (u *BilibiliUploader) UploadCoverContext(ctx context.Context, coverPath string) (coverURL string, err error) {
+    ctx, span := startSpan(ctx, "bilibili.upload_cover")
+    defer func() { endSpan(span, err) }()
+    
+    data, err := os.ReadFile(coverPath)
+    if err != nil {
+        return "", err
+    }
+    
+    form := url.Values{}
+    form.Set("cover", "data:"+http.DetectContentType(data)+";base64,"+base64.StdEncoding.EncodeToString(data))
+    
+    req, err := http.NewRequestWithContext(ctx, "POST", u.BaseURL+"/x/vu/web/cover/up", strings.NewReader(form.Encode()))
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    resp, err := u.do(req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    
+    var result struct {
+        Data struct {
+            URL string `json:"url"`
+        } `json:"data"`
+    }
+    if err := decodeAPIResponse("cover", resp, &result); err != nil {
+        return "", err
+    }
+    
+    return result.Data.URL, nil
+}
+
 // submitVideo 提交稿件
-func (u *BilibiliUploader) submitVideo(filename string, params VideoUploadParams) (string, error) {
+func (u *BilibiliUploader) submitVideo(ctx context.Context, filename string, params VideoUploadParams) (string, error) {
     // 构建提交数据
     submitData := map[string]interface{}{
         "copyright": params.Copyright,
@@ -289,35 +784,77 @@ func (u *BilibiliUploader) submitVideo(filename string, params VideoUploadParams
         return "", err
     }
     
-    req, err := http.NewRequest("POST", u.BaseURL+"/web/add", bytes.NewBuffer(jsonData))
+    req, err := http.NewRequestWithContext(ctx, "POST", u.BaseURL+"/x/vu/web/add", bytes.NewBuffer(jsonData))
     if err != nil {
         return "", err
     }
     
     req.Header.Set("Content-Type", "application/json")
-    req.Header.Set("Authorization", "Bearer "+u.AccessToken)
-    
-    client := &http.Client{}
-    resp, err := client.Do(req)
+    resp, err := u.do(req)
     if err != nil {
         return "", err
     }
     defer resp.Body.Close()
     
     var result struct {
-        Code int    `json:"code"`
-        BVid string `json:"bvid"`
+        Data struct {
+            AID  int64  `json:"aid"`
+            BVid string `json:"bvid"`
+        } `json:"data"`
     }
     
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+    if err := decodeAPIResponse("add", resp, &result); err != nil {
         return "", err
     }
     
-    if result.Code != 0 {
-        return "", fmt.Errorf("提交稿件失败: code=%d", result.Code)
+    return result.Data.BVid, nil
+}
+
+// ArchiveStatus 稿件状态
+type ArchiveStatus struct {
+    BVID         string `json:"bvid"`
+    AID          int64  `json:"aid"`
+    Title        string `json:"title"`
+    State        int    `json:"state"` // 0:已发布 -30:审核中 其他负数:未通过/锁定
+    StateDesc    string `json:"state_desc"`
+    RejectReason string `json:"reject_reason"`
+}
+
+// GetArchiveStatus 查询稿件审核状态
+func (u *BilibiliUploader) GetArchiveStatus(bvid string) (*ArchiveStatus, error) {
+    return u.GetArchiveStatusContext(context.Background(), bvid)
+}
+
+// GetArchiveStatusContext 查询稿件审核状态，ctx用于链路追踪和日志关联
+func (u *BilibiliUploader) GetArchiveStatusContext(ctx context.Context, bvid string) (status *ArchiveStatus, err error) {
+    ctx, span := startSpan(ctx, "bilibili.archive_status", attribute.String("bilibili.bvid", bvid))
+    defer func() { endSpan(span, err) }()
+    
+    params := url.Values{}
+    params.Set("bvid", bvid)
+    
+    req, err := http.NewRequestWithContext(ctx, "GET", u.BaseURL+"/x/web/archive/view?"+params.Encode(), nil)
+    if err != nil {
+        return nil, err
     }
     
-    return result.BVid, nil
+    resp, err := u.do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    
+    var result struct {
+        Data struct {
+            Archive ArchiveStatus `json:"archive"`
+        } `json:"data"`
+    }
+    
+    if err := decodeAPIResponse("archive", resp, &result); err != nil {
+        return nil, fmt.Errorf("查询稿件状态失败: %w", err)
+    }
+    
+    return &result.Data.Archive, nil
 }
 
 // AutoUploadWithOAuth 使用OAuth自动上传视频
@@ -341,36 +878,6 @@ func joinTags(tags []string) string {
     return result
 }
 
-// ===== 模拟实现（用于测试） =====
-
-// SimulatedUpload 模拟上传（用于开发测试）
-func SimulatedUpload(videoPath string, params VideoUploadParams) (string, error) {
-    // 模拟上传延迟
-    time.Sleep(3 * time.Second)
-    
-    // 生成模拟的BV号
-    bvid := fmt.Sprintf("BV1%s%d", generateRandomString(8), time.Now().Unix()%1000)
-    
-    fmt.Printf("模拟上传视频:\n")
-    fmt.Printf("  文件: %s\n", videoPath)
-    fmt.Printf("  标题: %s\n", params.Title)
-    fmt.Printf("  简介: %s\n", params.Description)
-    fmt.Printf("  分区: %d\n", params.Category)
-    fmt.Printf("  标签: %v\n", params.Tags)
-    fmt.Printf("  BV号: %s\n", bvid)
-    
-    return bvid, nil
-}
-
-func generateRandomString(length int) string {
-    const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-    result := make([]byte, length)
-    for i := range result {
-        result[i] = charset[time.Now().UnixNano()%int64(len(charset))]
-    }
-    return string(result)
-}
-
 // CreateMultipartUpload 创建分片上传请求
 func CreateMultipartUpload(videoPath string, params map[string]string) (*bytes.Buffer, string, error) {
     file, err := os.Open(videoPath)
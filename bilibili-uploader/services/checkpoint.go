@@ -0,0 +1,145 @@
+// services/checkpoint.go - 分片上传断点：中断的上传记录已完成的分片，重启后从断点继续
+package services
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// CheckpointStoreFile 数据目录中上传断点的文件名
+const CheckpointStoreFile = "upload_checkpoints.json"
+
+// UploadCheckpointTTL 断点有效期，超过后UPOS的上传会话可能已失效，重新上传
+const UploadCheckpointTTL = 12 * time.Hour
+
+// UploadCheckpoint 一个文件的上传断点
+type UploadCheckpoint struct {
+    Path      string     `json:"path"`
+    Size      int64      `json:"size"`
+    ModTime   time.Time  `json:"mod_time"`
+    Info      UploadInfo `json:"info"`  // 预上传和初始化得到的上传地址、upload_id和X-Upos-Auth
+    Parts     int64      `json:"parts"` // 已上传的分片数，从第Parts+1片继续
+    UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CheckpointStore 上传断点存储，持久化到JSON文件（含X-Upos-Auth，仅当前用户可读）
+type CheckpointStore struct {
+    mu          sync.Mutex
+    path        string
+    checkpoints map[string]*UploadCheckpoint
+}
+
+// NewCheckpointStore 创建断点存储，path为空时只保存在内存中
+func NewCheckpointStore(path string) *CheckpointStore {
+    store := &CheckpointStore{
+        path:        path,
+        checkpoints: make(map[string]*UploadCheckpoint),
+    }
+    store.load()
+    return store
+}
+
+// Get 获取文件的断点，文件大小或修改时间变化、断点过期时返回false
+func (s *CheckpointStore) Get(videoPath string) (*UploadCheckpoint, bool) {
+    key := checkpointKey(videoPath)
+    info, err := os.Stat(videoPath)
+    if err != nil {
+        return nil, false
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    checkpoint, ok := s.checkpoints[key]
+    if !ok {
+        return nil, false
+    }
+    if checkpoint.Size != info.Size() || !checkpoint.ModTime.Equal(info.ModTime()) || time.Since(checkpoint.UpdatedAt) > UploadCheckpointTTL {
+        delete(s.checkpoints, key)
+        s.save()
+        return nil, false
+    }
+    copied := *checkpoint
+    return &copied, true
+}
+
+// Save 记录文件已上传的分片数
+func (s *CheckpointStore) Save(videoPath string, info *UploadInfo, parts int64) error {
+    stat, err := os.Stat(videoPath)
+    if err != nil {
+        return err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    key := checkpointKey(videoPath)
+    s.checkpoints[key] = &UploadCheckpoint{
+        Path:      key,
+        Size:      stat.Size(),
+        ModTime:   stat.ModTime(),
+        Info:      *info,
+        Parts:     parts,
+        UpdatedAt: time.Now(),
+    }
+    s.save()
+    return nil
+}
+
+// Delete 删除文件的断点
+func (s *CheckpointStore) Delete(videoPath string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    key := checkpointKey(videoPath)
+    if _, ok := s.checkpoints[key]; ok {
+        delete(s.checkpoints, key)
+        s.save()
+    }
+}
+
+// checkpointKey 断点的键：文件的绝对路径
+func checkpointKey(videoPath string) string {
+    if abs, err := filepath.Abs(videoPath); err == nil {
+        return abs
+    }
+    return videoPath
+}
+
+// load 从文件加载断点
+func (s *CheckpointStore) load() {
+    if s.path == "" {
+        return
+    }
+    data, err := os.ReadFile(s.path)
+    if err != nil {
+        return
+    }
+    var checkpoints []*UploadCheckpoint
+    if err := json.Unmarshal(data, &checkpoints); err != nil {
+        return
+    }
+    for _, checkpoint := range checkpoints {
+        s.checkpoints[checkpoint.Path] = checkpoint
+    }
+}
+
+// save 保存断点到文件（调用方需持有锁）
+func (s *CheckpointStore) save() {
+    if s.path == "" {
+        return
+    }
+    checkpoints := make([]*UploadCheckpoint, 0, len(s.checkpoints))
+    for _, checkpoint := range s.checkpoints {
+        checkpoints = append(checkpoints, checkpoint)
+    }
+    data, err := json.MarshalIndent(checkpoints, "", "  ")
+    if err != nil {
+        return
+    }
+    os.MkdirAll(filepath.Dir(s.path), 0700)
+    os.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,285 @@
+// services/drafts.go - 已上传未提交的草稿
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+// 草稿状态
+const (
+    DraftStatusDraft      = "draft"      // 视频已上传，等待提交
+    DraftStatusPublishing = "publishing" // 正在提交稿件
+    DraftStatusPublished  = "published"  // 已提交稿件
+    DraftStatusFailed     = "failed"     // 提交失败，可修改后重试
+)
+
+// DraftPublishTimeout 提交稿件的最长时间，超过后认为提交已中断（如服务崩溃），草稿回到失败状态以便重试
+const DraftPublishTimeout = 10 * time.Minute
+
+// publishInterruptedError 草稿或待审核稿件提交中断时记录的错误，稿件可能已在B站创建，重试前需确认
+const publishInterruptedError = "提交过程被中断，未确认结果，请在B站稿件管理中确认后再重试"
+
+// Draft 已完成预上传和分片上传、尚未提交稿件的视频
+type Draft struct {
+    ID        string            `json:"id"`
+    UserID    string            `json:"user_id"`
+    AccountID string            `json:"account_id"`
+    Filename  string            `json:"filename"` // 预上传返回的服务端文件名
+    File      string            `json:"file"`     // 原始文件名
+    Params    VideoUploadParams `json:"params"`
+    Status    string            `json:"status"`
+    Error     string            `json:"error,omitempty"`
+    BVID      string            `json:"bvid,omitempty"`
+    CreatedAt time.Time         `json:"created_at"`
+    UpdatedAt time.Time         `json:"updated_at"`
+    ExpiresAt time.Time         `json:"expires_at"` // B站侧的上传文件失效时间，之后无法再提交
+
+    PublishStartedAt time.Time `json:"publish_started_at,omitempty"` // 开始提交稿件的时间
+}
+
+// Expired 上传的文件是否已失效（已提交的草稿不会过期）
+func (d *Draft) Expired() bool {
+    return d.Status != DraftStatusPublished && time.Now().After(d.ExpiresAt)
+}
+
+// publishStalled 提交中的草稿是否已超时
+func (d *Draft) publishStalled() bool {
+    return d.Status == DraftStatusPublishing && time.Since(d.PublishStartedAt) > DraftPublishTimeout
+}
+
+// DraftStore 草稿存储，持久化到JSON文件，过期草稿在访问时清理
+type DraftStore struct {
+    mu     sync.Mutex
+    path   string
+    ttl    time.Duration
+    drafts map[string]*Draft
+}
+
+// NewDraftStore 创建草稿存储，ttl为上传文件在B站侧的有效期，path为空时只保存在内存中
+// 加载时仍处于提交中的草稿是上次运行中断留下的，改为失败状态以便重试
+func NewDraftStore(path string, ttl time.Duration) *DraftStore {
+    store := &DraftStore{
+        path:   path,
+        ttl:    ttl,
+        drafts: make(map[string]*Draft),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*Draft
+            if err := json.Unmarshal(data, &list); err == nil {
+                interrupted := false
+                for _, draft := range list {
+                    if draft.Status == DraftStatusPublishing {
+                        interruptPublish(draft)
+                        interrupted = true
+                    }
+                    store.drafts[draft.ID] = draft
+                }
+                if interrupted {
+                    store.persist()
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Create 保存新草稿，有效期从上传完成开始计算
+func (s *DraftStore) Create(draft *Draft) (*Draft, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    id, err := RandomID(8)
+    if err != nil {
+        return nil, err
+    }
+    saved := *draft
+    saved.ID = id
+    saved.Status = DraftStatusDraft
+    saved.CreatedAt = time.Now()
+    saved.UpdatedAt = saved.CreatedAt
+    saved.ExpiresAt = saved.CreatedAt.Add(s.ttl)
+    s.drafts[id] = &saved
+
+    copied := saved
+    return &copied, s.persist()
+}
+
+// Get 获取用户的草稿
+func (s *DraftStore) Get(userID, id string) (*Draft, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    draft, err := s.find(userID, id)
+    if err != nil {
+        return nil, err
+    }
+    copied := *draft
+    return &copied, nil
+}
+
+// List 按创建时间倒序列出用户未过期的草稿
+func (s *DraftStore) List(userID string) []Draft {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.purge()
+    list := make([]Draft, 0)
+    for _, draft := range s.drafts {
+        if draft.UserID == userID {
+            list = append(list, *draft)
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].CreatedAt.After(list[j].CreatedAt)
+    })
+    return list
+}
+
+// Update 修改草稿的投稿信息
+func (s *DraftStore) Update(userID, id string, params VideoUploadParams) (*Draft, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    draft, err := s.find(userID, id)
+    if err != nil {
+        return nil, err
+    }
+    if draft.Status != DraftStatusDraft && draft.Status != DraftStatusFailed {
+        return nil, fmt.Errorf("草稿当前状态为%s，不能修改", draft.Status)
+    }
+    draft.Params = params
+    draft.UpdatedAt = time.Now()
+
+    copied := *draft
+    return &copied, s.persist()
+}
+
+// Delete 删除草稿
+func (s *DraftStore) Delete(userID, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    draft, err := s.find(userID, id)
+    if err != nil {
+        return err
+    }
+    if draft.Status == DraftStatusPublishing {
+        return fmt.Errorf("草稿正在提交，不能删除")
+    }
+    delete(s.drafts, id)
+    return s.persist()
+}
+
+// BeginPublish 标记草稿为提交中并返回副本，由调用方提交稿件后调用Finish
+func (s *DraftStore) BeginPublish(userID, id string) (*Draft, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    draft, err := s.find(userID, id)
+    if err != nil {
+        return nil, err
+    }
+    if draft.Status != DraftStatusDraft && draft.Status != DraftStatusFailed {
+        return nil, fmt.Errorf("草稿当前状态为%s，不能提交", draft.Status)
+    }
+    draft.Status = DraftStatusPublishing
+    draft.PublishStartedAt = time.Now()
+    draft.UpdatedAt = draft.PublishStartedAt
+
+    copied := *draft
+    return &copied, s.persist()
+}
+
+// Finish 记录提交稿件的结果
+func (s *DraftStore) Finish(id, bvid string, submitErr error) (*Draft, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    draft, ok := s.drafts[id]
+    if !ok {
+        return nil, fmt.Errorf("草稿不存在")
+    }
+    if submitErr != nil {
+        draft.Status = DraftStatusFailed
+        draft.Error = submitErr.Error()
+    } else {
+        draft.Status = DraftStatusPublished
+        draft.Error = ""
+        draft.BVID = bvid
+    }
+    draft.PublishStartedAt = time.Time{}
+    draft.UpdatedAt = time.Now()
+
+    copied := *draft
+    return &copied, s.persist()
+}
+
+// find 查找用户的草稿，过期草稿会被删除，提交超时的草稿改为失败（调用方需持有锁）
+func (s *DraftStore) find(userID, id string) (*Draft, error) {
+    draft, ok := s.drafts[id]
+    if !ok || draft.UserID != userID {
+        return nil, fmt.Errorf("草稿不存在")
+    }
+    if draft.publishStalled() {
+        interruptPublish(draft)
+        s.persist()
+    }
+    if draft.Expired() {
+        delete(s.drafts, id)
+        s.persist()
+        return nil, fmt.Errorf("草稿已过期，上传的文件在B站已失效，请重新上传")
+    }
+    return draft, nil
+}
+
+// purge 删除过期草稿，提交超时的草稿改为失败（调用方需持有锁）
+func (s *DraftStore) purge() {
+    changed := false
+    for id, draft := range s.drafts {
+        if draft.publishStalled() {
+            interruptPublish(draft)
+            changed = true
+        }
+        if draft.Expired() {
+            delete(s.drafts, id)
+            changed = true
+        }
+    }
+    if changed {
+        s.persist()
+    }
+}
+
+// interruptPublish 把中断的提交改为失败状态
+func interruptPublish(draft *Draft) {
+    draft.Status = DraftStatusFailed
+    draft.Error = publishInterruptedError
+    draft.PublishStartedAt = time.Time{}
+    draft.UpdatedAt = time.Now()
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *DraftStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*Draft, 0, len(s.drafts))
+    for _, draft := range s.drafts {
+        list = append(list, draft)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,353 @@
+// services/encryption.go - 凭证加密存储
+package services
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ErrNoCredentialKey 未配置加密密钥时拒绝保存凭证
+var ErrNoCredentialKey = errors.New("未配置CREDENTIAL_KEYS，拒绝以明文保存B站凭证")
+
+// Keyring AES-GCM密钥环，每条记录标记加密时使用的密钥版本；current为0表示明文模式
+type Keyring struct {
+    current int
+    aeads   map[int]cipher.AEAD
+}
+
+// NewKeyring 创建密钥环，keys为版本号到32字节密钥的映射
+func NewKeyring(keys map[int][]byte, current int) (*Keyring, error) {
+    if len(keys) == 0 {
+        return nil, fmt.Errorf("未配置加密密钥")
+    }
+
+    keyring := &Keyring{
+        current: current,
+        aeads:   make(map[int]cipher.AEAD),
+    }
+    for version, key := range keys {
+        if version <= 0 {
+            return nil, fmt.Errorf("密钥版本必须为正整数: %d", version)
+        }
+        if len(key) != 32 {
+            return nil, fmt.Errorf("密钥版本%d长度为%d字节，AES-256需要32字节", version, len(key))
+        }
+        block, err := aes.NewCipher(key)
+        if err != nil {
+            return nil, err
+        }
+        aead, err := cipher.NewGCM(block)
+        if err != nil {
+            return nil, err
+        }
+        keyring.aeads[version] = aead
+        if current == 0 && version > keyring.current {
+            keyring.current = version
+        }
+    }
+
+    if _, ok := keyring.aeads[keyring.current]; !ok {
+        return nil, fmt.Errorf("当前密钥版本%d不在密钥列表中", keyring.current)
+    }
+    return keyring, nil
+}
+
+// PlaintextKeyring 明文模式的密钥环，只应在显式开启ALLOW_PLAINTEXT_CREDENTIALS时使用
+func PlaintextKeyring() *Keyring {
+    return &Keyring{}
+}
+
+// ParseKeyring 解析密钥配置，格式为 "1:base64密钥,2:base64密钥"；current为0时使用最大版本
+func ParseKeyring(spec string, current int) (*Keyring, error) {
+    keys := make(map[int][]byte)
+    for _, item := range strings.Split(spec, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        versionStr, encoded, ok := strings.Cut(item, ":")
+        if !ok {
+            return nil, fmt.Errorf("密钥格式错误，应为 版本:base64密钥")
+        }
+        version, err := strconv.Atoi(strings.TrimSpace(versionStr))
+        if err != nil {
+            return nil, fmt.Errorf("密钥版本格式错误: %s", versionStr)
+        }
+        key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+        if err != nil {
+            return nil, fmt.Errorf("密钥版本%d不是有效的base64: %v", version, err)
+        }
+        if _, dup := keys[version]; dup {
+            return nil, fmt.Errorf("密钥版本%d重复", version)
+        }
+        keys[version] = key
+    }
+    return NewKeyring(keys, current)
+}
+
+// GenerateKey 生成新的base64编码AES-256密钥
+func GenerateKey() (string, error) {
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return "", err
+    }
+    return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// CurrentVersion 当前用于加密的密钥版本，0表示明文模式
+func (k *Keyring) CurrentVersion() int {
+    return k.current
+}
+
+// sealedRecord 加密后的单条记录
+type sealedRecord struct {
+    ID         string `json:"id"`
+    KeyVersion int    `json:"key_version"` // 0表示未加密（明文模式）
+    Nonce      string `json:"nonce,omitempty"`
+    Data       string `json:"data"`
+}
+
+// seal 加密一条记录，记录ID作为附加数据防止记录被互换；未配置密钥时返回ErrNoCredentialKey
+func (k *Keyring) seal(id string, plaintext []byte) (sealedRecord, error) {
+    if k == nil {
+        return sealedRecord{}, ErrNoCredentialKey
+    }
+    if k.current == 0 {
+        return sealedRecord{
+            ID:   id,
+            Data: base64.StdEncoding.EncodeToString(plaintext),
+        }, nil
+    }
+
+    aead := k.aeads[k.current]
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return sealedRecord{}, err
+    }
+    return sealedRecord{
+        ID:         id,
+        KeyVersion: k.current,
+        Nonce:      base64.StdEncoding.EncodeToString(nonce),
+        Data:       base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(id))),
+    }, nil
+}
+
+// open 解密一条记录，找不到对应版本的密钥时返回错误
+func (k *Keyring) open(record sealedRecord) ([]byte, error) {
+    data, err := base64.StdEncoding.DecodeString(record.Data)
+    if err != nil {
+        return nil, fmt.Errorf("记录%s数据损坏: %v", record.ID, err)
+    }
+    if record.KeyVersion == 0 {
+        return data, nil
+    }
+    if k == nil {
+        return nil, fmt.Errorf("记录%s使用密钥版本%d加密，但未配置CREDENTIAL_KEYS", record.ID, record.KeyVersion)
+    }
+
+    aead, ok := k.aeads[record.KeyVersion]
+    if !ok {
+        return nil, fmt.Errorf("记录%s使用密钥版本%d加密，但该密钥不在CREDENTIAL_KEYS中", record.ID, record.KeyVersion)
+    }
+    nonce, err := base64.StdEncoding.DecodeString(record.Nonce)
+    if err != nil {
+        return nil, fmt.Errorf("记录%s的nonce损坏: %v", record.ID, err)
+    }
+    plaintext, err := aead.Open(nil, nonce, data, []byte(record.ID))
+    if err != nil {
+        return nil, fmt.Errorf("记录%s解密失败（密钥版本%d）: %v", record.ID, record.KeyVersion, err)
+    }
+    return plaintext, nil
+}
+
+// loadSealedFile 读取并解密文件中的全部记录，文件不存在时返回空
+func loadSealedFile(path string, keyring *Keyring) (map[string][]byte, error) {
+    records := make(map[string][]byte)
+    if path == "" {
+        return records, nil
+    }
+
+    sealed, err := readSealedRecords(path)
+    if err != nil {
+        return nil, err
+    }
+    for _, record := range sealed {
+        plaintext, err := keyring.open(record)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+        }
+        records[record.ID] = plaintext
+    }
+    return records, nil
+}
+
+// updateSealedFile 在文件锁内重新读取文件，只替换（plaintext为nil时删除）一条记录后原子写入，
+// 其他记录原样保留，避免覆盖其他进程（如rotate-keys）写入的内容
+func updateSealedFile(path string, keyring *Keyring, id string, plaintext []byte) error {
+    if path == "" {
+        return nil
+    }
+
+    var record sealedRecord
+    if plaintext != nil {
+        var err error
+        if record, err = keyring.seal(id, plaintext); err != nil {
+            return err
+        }
+    }
+
+    unlock, err := lockFile(path)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    sealed, err := readSealedRecords(path)
+    if err != nil {
+        return err
+    }
+
+    merged := make([]sealedRecord, 0, len(sealed)+1)
+    for _, existing := range sealed {
+        if existing.ID != id {
+            merged = append(merged, existing)
+        }
+    }
+    if plaintext != nil {
+        merged = append(merged, record)
+    }
+    sort.Slice(merged, func(i, j int) bool { return merged[i].ID < merged[j].ID })
+
+    data, err := json.MarshalIndent(merged, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(path, data, 0600)
+}
+
+// readSealedRecords 读取文件中未解密的记录，文件不存在时返回空
+func readSealedRecords(path string) ([]sealedRecord, error) {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var sealed []sealedRecord
+    if err := json.Unmarshal(data, &sealed); err != nil {
+        return nil, fmt.Errorf("解析%s失败: %v", filepath.Base(path), err)
+    }
+    return sealed, nil
+}
+
+// RotateSealedFile 用当前密钥重新加密文件中的全部记录，返回重新加密的记录数
+// 在线轮换步骤：1) CREDENTIAL_KEYS中加入新密钥并设为当前版本后重启服务；
+// 2) 执行轮换；3) 确认无旧版本记录后从CREDENTIAL_KEYS移除旧密钥
+func RotateSealedFile(path string, keyring *Keyring) (int, error) {
+    if keyring == nil || keyring.current == 0 {
+        return 0, fmt.Errorf("未配置CREDENTIAL_KEYS")
+    }
+
+    unlock, err := lockFile(path)
+    if err != nil {
+        return 0, err
+    }
+    defer unlock()
+
+    sealed, err := readSealedRecords(path)
+    if err != nil {
+        return 0, err
+    }
+
+    rotated := 0
+    for i, record := range sealed {
+        if record.KeyVersion == keyring.current {
+            continue
+        }
+        plaintext, err := keyring.open(record)
+        if err != nil {
+            return 0, err
+        }
+        resealed, err := keyring.seal(record.ID, plaintext)
+        if err != nil {
+            return 0, err
+        }
+        sealed[i] = resealed
+        rotated++
+    }
+    if rotated == 0 {
+        return 0, nil
+    }
+
+    data, err := json.MarshalIndent(sealed, "", "  ")
+    if err != nil {
+        return 0, err
+    }
+    return rotated, writeFileAtomic(path, data, 0600)
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免读到写了一半的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Chmod(perm); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// lockFile 通过 <path>.lock 实现跨进程互斥，超过30秒的锁视为残留
+func lockFile(path string) (unlock func(), err error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return nil, err
+    }
+
+    lockPath := path + ".lock"
+    deadline := time.Now().Add(10 * time.Second)
+    for {
+        f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+        if err == nil {
+            f.Close()
+            return func() { os.Remove(lockPath) }, nil
+        }
+        if !os.IsExist(err) {
+            return nil, err
+        }
+        if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > 30*time.Second {
+            os.Remove(lockPath)
+            continue
+        }
+        if time.Now().After(deadline) {
+            return nil, fmt.Errorf("等待文件锁超时: %s", lockPath)
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func testKey(b byte) []byte {
+    return bytes.Repeat([]byte{b}, 32)
+}
+
+func mustKeyring(t *testing.T, keys map[int][]byte, current int) *Keyring {
+    t.Helper()
+    keyring, err := NewKeyring(keys, current)
+    if err != nil {
+        t.Fatalf("NewKeyring() error = %v", err)
+    }
+    return keyring
+}
+
+func TestNewKeyring(t *testing.T) {
+    tests := []struct {
+        name        string
+        keys        map[int][]byte
+        current     int
+        wantCurrent int
+        wantErr     string
+    }{
+        {name: "未指定版本时使用最大版本", keys: map[int][]byte{1: testKey(1), 3: testKey(3)}, wantCurrent: 3},
+        {name: "指定当前版本", keys: map[int][]byte{1: testKey(1), 2: testKey(2)}, current: 1, wantCurrent: 1},
+        {name: "没有密钥", keys: nil, wantErr: "未配置加密密钥"},
+        {name: "版本不是正整数", keys: map[int][]byte{0: testKey(1)}, wantErr: "密钥版本必须为正整数"},
+        {name: "密钥长度错误", keys: map[int][]byte{1: testKey(1)[:16]}, wantErr: "需要32字节"},
+        {name: "当前版本不存在", keys: map[int][]byte{1: testKey(1)}, current: 2, wantErr: "当前密钥版本2不在密钥列表中"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            keyring, err := NewKeyring(tt.keys, tt.current)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("NewKeyring() error = %v, want %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("NewKeyring() error = %v", err)
+            }
+            if got := keyring.CurrentVersion(); got != tt.wantCurrent {
+                t.Errorf("CurrentVersion() = %d, want %d", got, tt.wantCurrent)
+            }
+        })
+    }
+}
+
+func TestParseKeyring(t *testing.T) {
+    key, err := GenerateKey()
+    if err != nil {
+        t.Fatalf("GenerateKey() error = %v", err)
+    }
+
+    tests := []struct {
+        name    string
+        spec    string
+        wantErr bool
+    }{
+        {name: "单个密钥", spec: "1:" + key},
+        {name: "多个密钥和空白", spec: " 1:" + key + " , 2:" + key + ","},
+        {name: "缺少版本", spec: key, wantErr: true},
+        {name: "版本不是数字", spec: "a:" + key, wantErr: true},
+        {name: "无效base64", spec: "1:***", wantErr: true},
+        {name: "版本重复", spec: "1:" + key + ",1:" + key, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := ParseKeyring(tt.spec, 0)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("ParseKeyring() error = %v, wantErr %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestSealOpen(t *testing.T) {
+    v1 := mustKeyring(t, map[int][]byte{1: testKey(1)}, 0)
+    v2 := mustKeyring(t, map[int][]byte{1: testKey(1), 2: testKey(2)}, 0)
+    other := mustKeyring(t, map[int][]byte{1: testKey(9)}, 0)
+
+    tests := []struct {
+        name    string
+        sealer  *Keyring
+        opener  *Keyring
+        tamper  func(*sealedRecord)
+        wantErr bool
+    }{
+        {name: "同一密钥", sealer: v1, opener: v1},
+        {name: "新密钥环读取旧版本记录", sealer: v1, opener: v2},
+        {name: "明文模式", sealer: PlaintextKeyring(), opener: nil},
+        {name: "缺少记录使用的密钥版本", sealer: v2, opener: v1, wantErr: true},
+        {name: "密钥不同", sealer: v1, opener: other, wantErr: true},
+        {name: "未配置密钥时读取加密记录", sealer: v1, opener: nil, wantErr: true},
+        {name: "记录ID被互换", sealer: v1, opener: v1, tamper: func(r *sealedRecord) { r.ID = "other" }, wantErr: true},
+        {name: "数据损坏", sealer: v1, opener: v1, tamper: func(r *sealedRecord) { r.Data = "!" }, wantErr: true},
+    }
+
+    plaintext := []byte(`{"access_token":"secret"}`)
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            record, err := tt.sealer.seal("42", plaintext)
+            if err != nil {
+                t.Fatalf("seal() error = %v", err)
+            }
+            if tt.sealer.CurrentVersion() != 0 && strings.Contains(record.Data, "secret") {
+                t.Fatalf("seal() 输出包含明文: %q", record.Data)
+            }
+            if tt.tamper != nil {
+                tt.tamper(&record)
+            }
+
+            got, err := tt.opener.open(record)
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatalf("open() error = nil, want error")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("open() error = %v", err)
+            }
+            if !bytes.Equal(got, plaintext) {
+                t.Errorf("open() = %q, want %q", got, plaintext)
+            }
+        })
+    }
+}
+
+func TestSealWithoutKeyring(t *testing.T) {
+    var keyring *Keyring
+    if _, err := keyring.seal("1", []byte("x")); !errors.Is(err, ErrNoCredentialKey) {
+        t.Fatalf("seal() error = %v, want ErrNoCredentialKey", err)
+    }
+
+    path := filepath.Join(t.TempDir(), "tokens.json")
+    if err := updateSealedFile(path, nil, "1", []byte("x")); !errors.Is(err, ErrNoCredentialKey) {
+        t.Fatalf("updateSealedFile() error = %v, want ErrNoCredentialKey", err)
+    }
+    if _, err := os.Stat(path); !os.IsNotExist(err) {
+        t.Errorf("拒绝保存后不应创建文件, stat error = %v", err)
+    }
+}
+
+func TestUpdateSealedFileMergesRecords(t *testing.T) {
+    keyring := mustKeyring(t, map[int][]byte{1: testKey(1)}, 0)
+    path := filepath.Join(t.TempDir(), "tokens.json")
+
+    steps := []struct {
+        id        string
+        plaintext []byte
+    }{
+        {id: "b", plaintext: []byte("B")},
+        {id: "a", plaintext: []byte("A")},
+        {id: "b", plaintext: []byte("B2")},
+        {id: "c", plaintext: []byte("C")},
+        {id: "a", plaintext: nil},
+    }
+    for _, step := range steps {
+        if err := updateSealedFile(path, keyring, step.id, step.plaintext); err != nil {
+            t.Fatalf("updateSealedFile(%q) error = %v", step.id, err)
+        }
+    }
+
+    records, err := loadSealedFile(path, keyring)
+    if err != nil {
+        t.Fatalf("loadSealedFile() error = %v", err)
+    }
+    want := map[string]string{"b": "B2", "c": "C"}
+    if len(records) != len(want) {
+        t.Fatalf("loadSealedFile() = %d条记录, want %d", len(records), len(want))
+    }
+    for id, value := range want {
+        if string(records[id]) != value {
+            t.Errorf("记录%s = %q, want %q", id, records[id], value)
+        }
+    }
+
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if perm := info.Mode().Perm(); perm != 0600 {
+        t.Errorf("文件权限 = %o, want 600", perm)
+    }
+    if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+        t.Errorf("写入后应释放文件锁, stat error = %v", err)
+    }
+}
+
+func TestRotateSealedFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "tokens.json")
+    old := mustKeyring(t, map[int][]byte{1: testKey(1)}, 0)
+    for _, id := range []string{"1", "2"} {
+        if err := updateSealedFile(path, old, id, []byte("token-"+id)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    if _, err := RotateSealedFile(path, nil); err == nil {
+        t.Error("RotateSealedFile(nil) error = nil, want error")
+    }
+    if _, err := RotateSealedFile(path, PlaintextKeyring()); err == nil {
+        t.Error("RotateSealedFile(明文) error = nil, want error")
+    }
+
+    rotated := mustKeyring(t, map[int][]byte{1: testKey(1), 2: testKey(2)}, 0)
+    n, err := RotateSealedFile(path, rotated)
+    if err != nil {
+        t.Fatalf("RotateSealedFile() error = %v", err)
+    }
+    if n != 2 {
+        t.Errorf("RotateSealedFile() = %d, want 2", n)
+    }
+    if n, _ := RotateSealedFile(path, rotated); n != 0 {
+        t.Errorf("再次轮换 = %d, want 0", n)
+    }
+
+    // 移除旧密钥后仍能读取全部记录
+    onlyNew := mustKeyring(t, map[int][]byte{2: testKey(2)}, 0)
+    records, err := loadSealedFile(path, onlyNew)
+    if err != nil {
+        t.Fatalf("loadSealedFile() error = %v", err)
+    }
+    if string(records["1"]) != "token-1" || string(records["2"]) != "token-2" {
+        t.Errorf("轮换后记录 = %q", records)
+    }
+}
+
+func TestLockFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "data.json")
+
+    unlock, err := lockFile(path)
+    if err != nil {
+        t.Fatalf("lockFile() error = %v", err)
+    }
+
+    acquired := make(chan struct{})
+    go func() {
+        second, err := lockFile(path)
+        if err != nil {
+            t.Errorf("第二次lockFile() error = %v", err)
+            close(acquired)
+            return
+        }
+        close(acquired)
+        second()
+    }()
+
+    select {
+    case <-acquired:
+        t.Fatal("锁未释放前第二个调用方不应获得锁")
+    case <-time.After(200 * time.Millisecond):
+    }
+    unlock()
+    select {
+    case <-acquired:
+    case <-time.After(5 * time.Second):
+        t.Fatal("锁释放后第二个调用方未获得锁")
+    }
+}
+
+func TestLockFileRemovesStaleLock(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "data.json")
+    lockPath := path + ".lock"
+    if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+        t.Fatal(err)
+    }
+    stale := time.Now().Add(-time.Minute)
+    if err := os.Chtimes(lockPath, stale, stale); err != nil {
+        t.Fatal(err)
+    }
+
+    unlock, err := lockFile(path)
+    if err != nil {
+        t.Fatalf("lockFile() error = %v", err)
+    }
+    unlock()
+}
@@ -0,0 +1,85 @@
+// services/history.go - 投稿历史记录
+package services
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// HistoryRecord 一次投稿记录
+type HistoryRecord struct {
+    UserID     string    `json:"user_id,omitempty"` // 投稿用户（服务端记录）
+    AccountID  string    `json:"account_id,omitempty"`
+    BVID       string    `json:"bvid"`
+    Title      string    `json:"title"`
+    File       string    `json:"file"`
+    Category   int       `json:"tid"`
+    UploadedAt time.Time `json:"upload_time"`
+}
+
+// HistoryStore 投稿历史存储，持久化到JSON文件
+type HistoryStore struct {
+    mu      sync.Mutex
+    path    string
+    records []HistoryRecord
+}
+
+// NewHistoryStore 创建投稿历史存储，path为空时只保存在内存中
+func NewHistoryStore(path string) *HistoryStore {
+    store := &HistoryStore{path: path}
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            json.Unmarshal(data, &store.records)
+        }
+    }
+    return store
+}
+
+// Add 添加投稿记录
+func (s *HistoryStore) Add(record HistoryRecord) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if record.UploadedAt.IsZero() {
+        record.UploadedAt = time.Now()
+    }
+    s.records = append(s.records, record)
+
+    if s.path == "" {
+        return nil
+    }
+    data, err := json.MarshalIndent(s.records, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0644)
+}
+
+// List 按投稿时间倒序列出记录
+func (s *HistoryStore) List() []HistoryRecord {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]HistoryRecord, len(s.records))
+    for i, record := range s.records {
+        list[len(s.records)-1-i] = record
+    }
+    return list
+}
+
+// ListForUser 按投稿时间倒序列出用户的记录
+func (s *HistoryStore) ListForUser(userID string) []HistoryRecord {
+    list := make([]HistoryRecord, 0)
+    for _, record := range s.List() {
+        if record.UserID == userID {
+            list = append(list, record)
+        }
+    }
+    return list
+}
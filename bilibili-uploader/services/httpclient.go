@@ -0,0 +1,156 @@
+// services/httpclient.go - B站接口地址和共享的出站HTTP客户端
+package services
+
+import (
+    "bilibili-uploader/signing"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+    "go.opentelemetry.io/otel/propagation"
+)
+
+// BilibiliEndpoints B站各接口的域名，可指向本地的模拟服务（fakebili）
+type BilibiliEndpoints struct {
+    API      string // api.bilibili.com：nav等
+    Member   string // member.bilibili.com：预上传、投稿、稿件状态
+    Passport string // passport.bilibili.com：授权、令牌、扫码登录
+}
+
+// HTTPOptions 出站HTTP客户端配置
+type HTTPOptions struct {
+    Timeout   time.Duration // 单个请求的总超时（含分片上传），0表示使用默认值
+    ProxyURL  string        // 出站代理（http、https或socks5），为空时使用HTTP_PROXY等环境变量
+    UserAgent string        // 请求未设置User-Agent时使用
+}
+
+// 默认的出站配置
+const (
+    DefaultHTTPTimeout = 2 * time.Minute
+    DefaultUserAgent   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+)
+
+var (
+    bilibiliDefaultsMu sync.RWMutex
+    defaultEndpoints   = BilibiliEndpoints{
+        API:      "https://api.bilibili.com",
+        Member:   "https://member.bilibili.com",
+        Passport: "https://passport.bilibili.com",
+    }
+    defaultHTTPClient, _ = NewHTTPClient(HTTPOptions{})
+    defaultWbiSigner     *signing.WbiSigner // 按当前的API域名和HTTP客户端懒加载
+)
+
+// SetBilibiliDefaults 设置之后新建的OAuth服务、上传器和扫码登录使用的接口域名和HTTP客户端
+// 空的域名保持原值，client为nil时保持原客户端
+func SetBilibiliDefaults(endpoints BilibiliEndpoints, client *http.Client) {
+    bilibiliDefaultsMu.Lock()
+    defer bilibiliDefaultsMu.Unlock()
+
+    if endpoints.API != "" {
+        defaultEndpoints.API = strings.TrimRight(endpoints.API, "/")
+    }
+    if endpoints.Member != "" {
+        defaultEndpoints.Member = strings.TrimRight(endpoints.Member, "/")
+    }
+    if endpoints.Passport != "" {
+        defaultEndpoints.Passport = strings.TrimRight(endpoints.Passport, "/")
+    }
+    if client != nil {
+        defaultHTTPClient = client
+    }
+    defaultWbiSigner = nil
+}
+
+// DefaultBilibiliEndpoints 当前的默认接口域名
+func DefaultBilibiliEndpoints() BilibiliEndpoints {
+    bilibiliDefaultsMu.RLock()
+    defer bilibiliDefaultsMu.RUnlock()
+
+    return defaultEndpoints
+}
+
+// DefaultHTTPClient 当前的默认出站HTTP客户端
+func DefaultHTTPClient() *http.Client {
+    bilibiliDefaultsMu.RLock()
+    defer bilibiliDefaultsMu.RUnlock()
+
+    return defaultHTTPClient
+}
+
+// DefaultWbiSigner 共享的WBI签名器，通过默认API域名的nav接口和默认HTTP客户端获取密钥
+func DefaultWbiSigner() *signing.WbiSigner {
+    bilibiliDefaultsMu.Lock()
+    defer bilibiliDefaultsMu.Unlock()
+
+    if defaultWbiSigner == nil {
+        defaultWbiSigner = signing.NewWbiSigner(signing.NavKeyFetcher(defaultEndpoints.API, defaultHTTPClient))
+    }
+    return defaultWbiSigner
+}
+
+// NewHTTPClient 创建出站HTTP客户端：复用长连接，设置连接、TLS握手和响应头超时，可选代理和User-Agent
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+    proxy := http.ProxyFromEnvironment
+    if opts.ProxyURL != "" {
+        proxyURL, err := url.Parse(opts.ProxyURL)
+        if err != nil || proxyURL.Host == "" {
+            return nil, fmt.Errorf("代理地址格式错误: %s", opts.ProxyURL)
+        }
+        proxy = http.ProxyURL(proxyURL)
+    }
+    if opts.Timeout <= 0 {
+        opts.Timeout = DefaultHTTPTimeout
+    }
+    if opts.UserAgent == "" {
+        opts.UserAgent = DefaultUserAgent
+    }
+
+    transport := &http.Transport{
+        Proxy: proxy,
+        DialContext: (&net.Dialer{
+            Timeout:   10 * time.Second,
+            KeepAlive: 30 * time.Second,
+        }).DialContext,
+        ForceAttemptHTTP2:     true,
+        MaxIdleConns:          100,
+        MaxIdleConnsPerHost:   16, // 分片并发上传到同一个UPOS节点
+        IdleConnTimeout:       90 * time.Second,
+        TLSHandshakeTimeout:   10 * time.Second,
+        ResponseHeaderTimeout: time.Minute,
+        ExpectContinueTimeout: time.Second,
+    }
+
+    // 每个出站请求一个客户端span；不向B站发送traceparent等追踪请求头
+    traced := otelhttp.NewTransport(transport,
+        otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()),
+        otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+            return "HTTP " + req.Method + " " + req.URL.Host
+        }),
+    )
+
+    return &http.Client{
+        Transport: &userAgentTransport{base: traced, userAgent: opts.UserAgent},
+        Timeout:   opts.Timeout,
+    }, nil
+}
+
+// userAgentTransport 为未设置User-Agent的请求补充默认值
+type userAgentTransport struct {
+    base      http.RoundTripper
+    userAgent string
+}
+
+// RoundTrip 实现http.RoundTripper，不修改调用方的请求
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if req.Header.Get("User-Agent") == "" {
+        req = req.Clone(req.Context())
+        req.Header.Set("User-Agent", t.userAgent)
+    }
+    return t.base.RoundTrip(req)
+}
@@ -0,0 +1,170 @@
+// services/jobs.go - 后台任务记录
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+// 任务状态
+const (
+    JobStatusPending     = "pending"
+    JobStatusProcessing  = "processing"
+    JobStatusUploading   = "uploading"
+    JobStatusDone        = "done"
+    JobStatusFailed      = "failed"
+    JobStatusInterrupted = "interrupted" // 服务退出时中断，源文件保留，重启后重新处理（上传从断点继续）
+)
+
+// Job 后台任务（监控目录投稿等）
+type Job struct {
+    ID        string    `json:"id"`
+    UserID    string    `json:"user_id,omitempty"` // 任务所属用户，为空表示系统任务
+    Source    string    `json:"source"`            // 源文件路径
+    Title     string    `json:"title"`
+    Status    string    `json:"status"`
+    Stage     string    `json:"stage"` // 当前（或失败时）所处阶段
+    Error     string    `json:"error,omitempty"`
+    ErrorKind string    `json:"error_kind,omitempty"` // B站错误的分类：retryable、reauth、user
+    BVID      string    `json:"bvid,omitempty"`
+    TraceID   string    `json:"trace_id,omitempty"` // 处理该任务的链路追踪ID
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JobStore 任务存储，持久化到JSON文件
+type JobStore struct {
+    mu   sync.Mutex
+    path string
+    jobs map[string]*Job
+}
+
+// NewJobStore 创建任务存储，path为空时只保存在内存中
+func NewJobStore(path string) *JobStore {
+    store := &JobStore{
+        path: path,
+        jobs: make(map[string]*Job),
+    }
+    store.load()
+    return store
+}
+
+// Create 创建新任务
+func (s *JobStore) Create(source, title string) *Job {
+    return s.CreateFor("", source, title)
+}
+
+// CreateFor 为指定用户创建新任务
+func (s *JobStore) CreateFor(userID, source, title string) *Job {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    now := time.Now()
+    job := &Job{
+        ID:        fmt.Sprintf("job_%d", now.UnixNano()),
+        UserID:    userID,
+        Source:    source,
+        Title:     title,
+        Status:    JobStatusPending,
+        CreatedAt: now,
+        UpdatedAt: now,
+    }
+    s.jobs[job.ID] = job
+    s.save()
+
+    copied := *job
+    return &copied
+}
+
+// Update 更新任务
+func (s *JobStore) Update(id string, update func(job *Job)) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    job, ok := s.jobs[id]
+    if !ok {
+        return
+    }
+    update(job)
+    job.UpdatedAt = time.Now()
+    s.save()
+}
+
+// Get 获取任务
+func (s *JobStore) Get(id string) (*Job, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    job, ok := s.jobs[id]
+    if !ok {
+        return nil, false
+    }
+    copied := *job
+    return &copied, true
+}
+
+// List 按创建时间倒序列出任务
+func (s *JobStore) List() []Job {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]Job, 0, len(s.jobs))
+    for _, job := range s.jobs {
+        list = append(list, *job)
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].CreatedAt.After(list[j].CreatedAt)
+    })
+    return list
+}
+
+// ListForUser 按创建时间倒序列出用户的任务
+func (s *JobStore) ListForUser(userID string) []Job {
+    list := make([]Job, 0)
+    for _, job := range s.List() {
+        if job.UserID == userID {
+            list = append(list, job)
+        }
+    }
+    return list
+}
+
+// load 从文件加载任务
+func (s *JobStore) load() {
+    if s.path == "" {
+        return
+    }
+    data, err := os.ReadFile(s.path)
+    if err != nil {
+        return
+    }
+    var jobs []*Job
+    if err := json.Unmarshal(data, &jobs); err != nil {
+        return
+    }
+    for _, job := range jobs {
+        s.jobs[job.ID] = job
+    }
+}
+
+// save 保存任务到文件（调用方需持有锁）
+func (s *JobStore) save() {
+    if s.path == "" {
+        return
+    }
+    jobs := make([]*Job, 0, len(s.jobs))
+    for _, job := range s.jobs {
+        jobs = append(jobs, job)
+    }
+    data, err := json.MarshalIndent(jobs, "", "  ")
+    if err != nil {
+        return
+    }
+    os.MkdirAll(filepath.Dir(s.path), 0755)
+    os.WriteFile(s.path, data, 0644)
+}
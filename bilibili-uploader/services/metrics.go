@@ -0,0 +1,227 @@
+// services/metrics.go - Prometheus指标：HTTP请求、上传、B站接口、FFmpeg、任务队列和临时目录
+package services
+
+import (
+    "io"
+    "io/fs"
+    "net/http"
+    "path/filepath"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+    "github.com/prometheus/common/expfmt"
+)
+
+// metricsNamespace 指标名前缀
+const metricsNamespace = "bilibili_uploader"
+
+var (
+    metricsRegistry = prometheus.NewRegistry()
+
+    httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: metricsNamespace,
+        Name:      "http_requests_total",
+        Help:      "HTTP请求数（按方法、路由和状态码）",
+    }, []string{"method", "route", "status"})
+
+    httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: metricsNamespace,
+        Name:      "http_request_duration_seconds",
+        Help:      "HTTP请求耗时（按方法和路由）",
+        Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+    }, []string{"method", "route"})
+
+    uploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: metricsNamespace,
+        Name:      "uploads_total",
+        Help:      "视频文件上传次数（按结果：success、failure）",
+    }, []string{"result"})
+
+    uploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: metricsNamespace,
+        Name:      "upload_bytes_total",
+        Help:      "成功上传到B站的分片字节数",
+    })
+
+    uploadThroughput = prometheus.NewHistogram(prometheus.HistogramOpts{
+        Namespace: metricsNamespace,
+        Name:      "upload_throughput_bytes_per_second",
+        Help:      "单个视频文件的平均上传速度",
+        Buckets:   prometheus.ExponentialBuckets(64<<10, 2, 10), // 64KB/s ~ 32MB/s
+    })
+
+    uploadPartRetries = prometheus.NewCounter(prometheus.CounterOpts{
+        Namespace: metricsNamespace,
+        Name:      "upload_part_retries_total",
+        Help:      "分片上传的重试次数",
+    })
+
+    activeUploads = prometheus.NewGauge(prometheus.GaugeOpts{
+        Namespace: metricsNamespace,
+        Name:      "active_uploads",
+        Help:      "正在上传到B站的视频文件数",
+    })
+
+    bilibiliResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: metricsNamespace,
+        Name:      "bilibili_api_responses_total",
+        Help:      "B站接口响应数（按接口和业务码，只有HTTP状态时为http_<状态码>）",
+    }, []string{"endpoint", "code"})
+
+    ffmpegDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: metricsNamespace,
+        Name:      "ffmpeg_duration_seconds",
+        Help:      "FFmpeg处理耗时（按结果：success、failure）",
+        Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 3600},
+    }, []string{"result"})
+)
+
+func init() {
+    metricsRegistry.MustRegister(
+        collectors.NewGoCollector(),
+        collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+        httpRequests,
+        httpDuration,
+        uploads,
+        uploadBytes,
+        uploadThroughput,
+        uploadPartRetries,
+        activeUploads,
+        bilibiliResponses,
+        ffmpegDuration,
+        &sourceCollector{},
+    )
+}
+
+// MetricsHandler 以Prometheus文本格式输出所有指标的HTTP处理器
+func MetricsHandler() http.Handler {
+    return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// WriteMetrics 以Prometheus文本格式写出所有指标（命令行工具写入textfile收集目录时使用）
+func WriteMetrics(w io.Writer) error {
+    families, err := metricsRegistry.Gather()
+    if err != nil {
+        return err
+    }
+    encoder := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+    for _, family := range families {
+        if err := encoder.Encode(family); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ObserveHTTPRequest 记录一次HTTP请求，route为路由模板（如 /api/drafts/:id）
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+    httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+    httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// observeBilibiliResponse 记录一次B站接口响应，err为nil表示业务码为0
+func observeBilibiliResponse(endpoint string, err error) {
+    code := "0"
+    if biliErr, ok := AsBilibiliError(err); ok {
+        if biliErr.Code != 0 {
+            code = strconv.Itoa(biliErr.Code)
+        } else {
+            code = "http_" + strconv.Itoa(biliErr.HTTPStatus)
+        }
+    } else if err != nil {
+        code = "invalid_response"
+    }
+    bilibiliResponses.WithLabelValues(endpoint, code).Inc()
+}
+
+// observeFFmpeg 记录一次FFmpeg处理
+func observeFFmpeg(duration time.Duration, err error) {
+    result := "success"
+    if err != nil {
+        result = "failure"
+    }
+    ffmpegDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
+
+var (
+    metricsSourcesMu sync.Mutex
+    metricsJobs      *JobStore
+    metricsTempDirs  []string
+)
+
+// SetMetricsSources 设置任务队列和临时目录指标的数据来源，每次抓取时读取
+func SetMetricsSources(jobs *JobStore, tempDirs ...string) {
+    metricsSourcesMu.Lock()
+    defer metricsSourcesMu.Unlock()
+
+    metricsJobs = jobs
+    metricsTempDirs = tempDirs
+}
+
+var (
+    jobsDesc = prometheus.NewDesc(
+        prometheus.BuildFQName(metricsNamespace, "", "jobs"),
+        "后台任务数（按状态：pending为排队中，processing、uploading为执行中）",
+        []string{"status"}, nil,
+    )
+    tempDiskDesc = prometheus.NewDesc(
+        prometheus.BuildFQName(metricsNamespace, "", "temp_disk_bytes"),
+        "临时目录占用的磁盘空间",
+        []string{"dir"}, nil,
+    )
+)
+
+// sourceCollector 抓取时统计任务状态和临时目录大小
+type sourceCollector struct{}
+
+// Describe 实现prometheus.Collector
+func (sourceCollector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- jobsDesc
+    ch <- tempDiskDesc
+}
+
+// Collect 实现prometheus.Collector
+func (sourceCollector) Collect(ch chan<- prometheus.Metric) {
+    metricsSourcesMu.Lock()
+    jobs, dirs := metricsJobs, metricsTempDirs
+    metricsSourcesMu.Unlock()
+
+    if jobs != nil {
+        counts := map[string]int{
+            JobStatusPending:    0,
+            JobStatusProcessing: 0,
+            JobStatusUploading:  0,
+        }
+        for _, job := range jobs.List() {
+            if _, ok := counts[job.Status]; ok {
+                counts[job.Status]++
+            }
+        }
+        for status, count := range counts {
+            ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(count), status)
+        }
+    }
+
+    for _, dir := range dirs {
+        ch <- prometheus.MustNewConstMetric(tempDiskDesc, prometheus.GaugeValue, float64(dirSize(dir)), dir)
+    }
+}
+
+// dirSize 目录下所有文件的大小之和，读取失败的文件跳过
+func dirSize(dir string) int64 {
+    var total int64
+    filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+        if err != nil || entry.IsDir() {
+            return nil
+        }
+        if info, err := entry.Info(); err == nil {
+            total += info.Size()
+        }
+        return nil
+    })
+    return total
+}
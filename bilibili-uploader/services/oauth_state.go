@@ -0,0 +1,128 @@
+// services/oauth_state.go - OAuth state与PKCE
+package services
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// DefaultOAuthStateTTL 授权state有效期
+const DefaultOAuthStateTTL = 10 * time.Minute
+
+// PKCE 授权码流程的code_verifier与code_challenge（S256）
+type PKCE struct {
+    Verifier  string
+    Challenge string
+}
+
+// NewPKCE 生成随机code_verifier并计算S256 code_challenge
+func NewPKCE() (*PKCE, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return nil, fmt.Errorf("生成code_verifier失败: %v", err)
+    }
+    verifier := base64.RawURLEncoding.EncodeToString(b)
+    sum := sha256.Sum256([]byte(verifier))
+    return &PKCE{
+        Verifier:  verifier,
+        Challenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+    }, nil
+}
+
+// OAuthState 服务端保存的一次性授权state
+type OAuthState struct {
+    State        string
+    CodeVerifier string
+    Nonce        string // OIDC登录时写入ID Token的nonce
+    binding      string // 浏览器绑定值的SHA-256
+    createdAt    time.Time
+}
+
+// OAuthStateStore 一次性state存储，state只能被消费一次且必须来自同一浏览器
+type OAuthStateStore struct {
+    mu     sync.Mutex
+    ttl    time.Duration
+    states map[string]*OAuthState
+}
+
+// NewOAuthStateStore 创建state存储，ttl为0时使用默认有效期
+func NewOAuthStateStore(ttl time.Duration) *OAuthStateStore {
+    if ttl <= 0 {
+        ttl = DefaultOAuthStateTTL
+    }
+    return &OAuthStateStore{
+        ttl:    ttl,
+        states: make(map[string]*OAuthState),
+    }
+}
+
+// Issue 生成新的state和PKCE，binding为浏览器侧保存的随机值（如HttpOnly Cookie）
+func (s *OAuthStateStore) Issue(binding string) (*OAuthState, *PKCE, error) {
+    state, err := RandomID(32)
+    if err != nil {
+        return nil, nil, err
+    }
+    pkce, err := NewPKCE()
+    if err != nil {
+        return nil, nil, err
+    }
+    nonce, err := RandomID(16)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.purgeExpired()
+    issued := &OAuthState{
+        State:        state,
+        CodeVerifier: pkce.Verifier,
+        Nonce:        nonce,
+        binding:      hashBinding(binding),
+        createdAt:    time.Now(),
+    }
+    s.states[state] = issued
+    return issued, pkce, nil
+}
+
+// Consume 校验并消费state；无论校验是否通过，state都会被删除
+func (s *OAuthStateStore) Consume(state, binding string) (*OAuthState, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    issued, ok := s.states[state]
+    if !ok {
+        return nil, fmt.Errorf("state不存在或已使用")
+    }
+    delete(s.states, state)
+
+    if time.Since(issued.createdAt) > s.ttl {
+        return nil, fmt.Errorf("state已过期")
+    }
+    if binding == "" || subtle.ConstantTimeCompare([]byte(issued.binding), []byte(hashBinding(binding))) != 1 {
+        return nil, fmt.Errorf("state与当前浏览器不匹配")
+    }
+    return issued, nil
+}
+
+// purgeExpired 清除过期state（调用方需持有锁）
+func (s *OAuthStateStore) purgeExpired() {
+    for state, issued := range s.states {
+        if time.Since(issued.createdAt) > s.ttl {
+            delete(s.states, state)
+        }
+    }
+}
+
+// hashBinding 只保存绑定值的哈希
+func hashBinding(binding string) string {
+    sum := sha256.Sum256([]byte(binding))
+    return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,274 @@
+// services/oauth_token.go - OAuth令牌存储与自动续期
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// DefaultRefreshBefore 令牌到期前多久开始主动续期
+const DefaultRefreshBefore = 30 * time.Minute
+
+// TokenStoreFile 数据目录中OAuth令牌的文件名
+const TokenStoreFile = "oauth_tokens.json"
+
+// OAuthToken 服务端保存的OAuth令牌
+type OAuthToken struct {
+    UID          int64     `json:"uid"`
+    AccessToken  string    `json:"access_token"`
+    RefreshToken string    `json:"refresh_token"`
+    ExpiresAt    time.Time `json:"expires_at"`
+    RefreshedAt  time.Time `json:"refreshed_at,omitempty"`
+}
+
+// NewOAuthToken 根据token响应创建令牌记录
+func NewOAuthToken(uid int64, resp *TokenResponse) *OAuthToken {
+    token := &OAuthToken{
+        UID:          uid,
+        AccessToken:  resp.AccessToken,
+        RefreshToken: resp.RefreshToken,
+    }
+    if resp.ExpiresIn > 0 {
+        token.ExpiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+    }
+    return token
+}
+
+// ExpiresWithin 令牌是否会在d内过期（未知过期时间视为不过期）
+func (t *OAuthToken) ExpiresWithin(d time.Duration) bool {
+    return !t.ExpiresAt.IsZero() && time.Until(t.ExpiresAt) < d
+}
+
+// Refresher 可在凭证失效时刷新的认证方式
+type Refresher interface {
+    Refresh(ctx context.Context) error
+}
+
+// TokenStore OAuth令牌存储（按UID），持久化到JSON文件
+type TokenStore struct {
+    mu      sync.Mutex
+    path    string
+    keyring *Keyring
+    tokens  map[int64]*OAuthToken
+    locks   map[int64]*sync.Mutex // 每个UID一把刷新锁，避免并发重复刷新
+}
+
+// NewTokenStore 创建令牌存储，path为空时只保存在内存中；keyring为空时只能读取明文记录，保存时返回ErrNoCredentialKey
+// 存在无法解密的记录（如缺少对应版本的密钥）时返回错误
+func NewTokenStore(path string, keyring *Keyring) (*TokenStore, error) {
+    store := &TokenStore{
+        path:    path,
+        keyring: keyring,
+        tokens:  make(map[int64]*OAuthToken),
+        locks:   make(map[int64]*sync.Mutex),
+    }
+
+    records, err := loadSealedFile(path, keyring)
+    if err != nil {
+        return nil, fmt.Errorf("加载OAuth令牌失败: %v", err)
+    }
+    for id, data := range records {
+        var token OAuthToken
+        if err := json.Unmarshal(data, &token); err != nil {
+            return nil, fmt.Errorf("解析UID %s 的令牌失败: %v", id, err)
+        }
+        store.tokens[token.UID] = &token
+    }
+    return store, nil
+}
+
+// Save 保存令牌
+func (s *TokenStore) Save(token *OAuthToken) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    copied := *token
+    s.tokens[token.UID] = &copied
+    return s.persist(token.UID)
+}
+
+// Get 按UID获取令牌
+func (s *TokenStore) Get(uid int64) (*OAuthToken, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    token, ok := s.tokens[uid]
+    if !ok {
+        return nil, false
+    }
+    copied := *token
+    return &copied, true
+}
+
+// Delete 删除令牌
+func (s *TokenStore) Delete(uid int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    delete(s.tokens, uid)
+    return s.persist(uid)
+}
+
+// List 列出所有令牌
+func (s *TokenStore) List() []OAuthToken {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]OAuthToken, 0, len(s.tokens))
+    for _, token := range s.tokens {
+        list = append(list, *token)
+    }
+    return list
+}
+
+// Refresh 刷新指定UID的令牌；force为false时只在临近过期时刷新
+func (s *TokenStore) Refresh(ctx context.Context, oauth *BilibiliOAuth, uid int64, force bool, refreshBefore time.Duration) (*OAuthToken, error) {
+    lock := s.refreshLock(uid)
+    lock.Lock()
+    defer lock.Unlock()
+
+    token, ok := s.Get(uid)
+    if !ok {
+        return nil, fmt.Errorf("未找到UID %d 的令牌", uid)
+    }
+    if !force && !token.ExpiresWithin(refreshBefore) {
+        return token, nil
+    }
+    // 并发请求同时收到401时只刷新一次
+    if force && time.Since(token.RefreshedAt) < 30*time.Second {
+        return token, nil
+    }
+    if token.RefreshToken == "" {
+        return nil, fmt.Errorf("UID %d 没有refresh_token，需要重新授权", uid)
+    }
+
+    resp, err := oauth.RefreshToken(ctx, token.RefreshToken)
+    if err != nil {
+        return nil, err
+    }
+
+    refreshed := NewOAuthToken(uid, resp)
+    if refreshed.RefreshToken == "" {
+        refreshed.RefreshToken = token.RefreshToken
+    }
+    refreshed.RefreshedAt = time.Now()
+    if err := s.Save(refreshed); err != nil {
+        return nil, fmt.Errorf("保存刷新后的令牌失败: %v", err)
+    }
+
+    slog.InfoContext(ctx, "已刷新访问令牌", "bilibili_uid", uid, "expires_at", refreshed.ExpiresAt)
+    return refreshed, nil
+}
+
+// RefreshExpiring 刷新所有即将过期的令牌
+func (s *TokenStore) RefreshExpiring(ctx context.Context, oauth *BilibiliOAuth, refreshBefore time.Duration) {
+    for _, token := range s.List() {
+        if !token.ExpiresWithin(refreshBefore) {
+            continue
+        }
+        if _, err := s.Refresh(ctx, oauth, token.UID, false, refreshBefore); err != nil {
+            slog.WarnContext(ctx, "刷新令牌失败", "bilibili_uid", token.UID, "error", err)
+        }
+    }
+}
+
+// StartRenewal 定时主动续期即将过期的令牌，返回停止函数；停止时取消进行中的刷新请求
+func (s *TokenStore) StartRenewal(oauth *BilibiliOAuth, interval, refreshBefore time.Duration) (stop func()) {
+    ctx, cancel := context.WithCancel(context.Background())
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            s.RefreshExpiring(ctx, oauth, refreshBefore)
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+            }
+        }
+    }()
+    return cancel
+}
+
+// refreshLock 获取UID对应的刷新锁
+func (s *TokenStore) refreshLock(uid int64) *sync.Mutex {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    lock, ok := s.locks[uid]
+    if !ok {
+        lock = &sync.Mutex{}
+        s.locks[uid] = lock
+    }
+    return lock
+}
+
+// persist 把UID对应的一条记录写入文件，内存中已删除时从文件中删除（调用方需持有锁）
+func (s *TokenStore) persist(uid int64) error {
+    var data []byte
+    if token, ok := s.tokens[uid]; ok {
+        var err error
+        if data, err = json.Marshal(token); err != nil {
+            return err
+        }
+    }
+    return updateSealedFile(s.path, s.keyring, strconv.FormatInt(uid, 10), data)
+}
+
+// RefreshingAuth 从令牌存储读取访问令牌，临近过期或返回401时自动刷新
+type RefreshingAuth struct {
+    UID           int64
+    Store         *TokenStore
+    OAuth         *BilibiliOAuth
+    RefreshBefore time.Duration
+    // Wrap 由访问令牌创建实际的认证方式（Bearer或appkey签名），为空时使用Bearer
+    Wrap func(accessToken string) Authenticator
+}
+
+// Authenticate 使用当前有效令牌认证请求
+func (a *RefreshingAuth) Authenticate(req *http.Request) error {
+    token, err := a.Store.Refresh(req.Context(), a.OAuth, a.UID, false, a.refreshBefore())
+    if err != nil {
+        // 刷新失败但令牌尚未过期时继续使用旧令牌
+        current, ok := a.Store.Get(a.UID)
+        if !ok || (!current.ExpiresAt.IsZero() && time.Now().After(current.ExpiresAt)) {
+            return err
+        }
+        slog.WarnContext(req.Context(), "提前刷新令牌失败，继续使用当前令牌", "bilibili_uid", a.UID, "error", err)
+        token = current
+    }
+    return a.wrap(token.AccessToken).Authenticate(req)
+}
+
+// Refresh 强制刷新令牌（收到401时调用）
+func (a *RefreshingAuth) Refresh(ctx context.Context) error {
+    _, err := a.Store.Refresh(ctx, a.OAuth, a.UID, true, a.refreshBefore())
+    return err
+}
+
+// Kind 认证方式名称
+func (a *RefreshingAuth) Kind() string {
+    return a.wrap("").Kind()
+}
+
+// refreshBefore 续期提前量
+func (a *RefreshingAuth) refreshBefore() time.Duration {
+    if a.RefreshBefore > 0 {
+        return a.RefreshBefore
+    }
+    return DefaultRefreshBefore
+}
+
+// wrap 创建实际的认证方式
+func (a *RefreshingAuth) wrap(accessToken string) Authenticator {
+    if a.Wrap != nil {
+        return a.Wrap(accessToken)
+    }
+    return &BearerAuth{AccessToken: accessToken}
+}
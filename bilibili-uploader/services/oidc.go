@@ -0,0 +1,248 @@
+// services/oidc.go - OIDC登录
+package services
+
+import (
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v4"
+)
+
+// OIDCProvider OIDC身份提供方（授权码流程 + PKCE）
+type OIDCProvider struct {
+    Issuer       string
+    ClientID     string
+    ClientSecret string
+    RedirectURI  string
+
+    mu        sync.Mutex
+    discovery *oidcDiscovery
+    keys      map[string]*rsa.PublicKey
+    keysAt    time.Time
+}
+
+// oidcDiscovery /.well-known/openid-configuration 中用到的字段
+type oidcDiscovery struct {
+    Issuer                string `json:"issuer"`
+    AuthorizationEndpoint string `json:"authorization_endpoint"`
+    TokenEndpoint         string `json:"token_endpoint"`
+    JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims ID Token中的用户信息
+type OIDCClaims struct {
+    Email             string `json:"email"`
+    Name              string `json:"name"`
+    PreferredUsername string `json:"preferred_username"`
+    Nonce             string `json:"nonce"`
+    jwt.RegisteredClaims
+}
+
+// NewOIDCProvider 创建OIDC提供方，端点在首次使用时通过discovery获取
+func NewOIDCProvider(issuer, clientID, clientSecret, redirectURI string) *OIDCProvider {
+    return &OIDCProvider{
+        Issuer:       strings.TrimRight(issuer, "/"),
+        ClientID:     clientID,
+        ClientSecret: clientSecret,
+        RedirectURI:  redirectURI,
+    }
+}
+
+// AuthURL 构建授权地址
+func (p *OIDCProvider) AuthURL(state, nonce, codeChallenge string) (string, error) {
+    discovery, err := p.discover()
+    if err != nil {
+        return "", err
+    }
+
+    params := url.Values{}
+    params.Set("client_id", p.ClientID)
+    params.Set("response_type", "code")
+    params.Set("redirect_uri", p.RedirectURI)
+    params.Set("scope", "openid profile email")
+    params.Set("state", state)
+    params.Set("nonce", nonce)
+    params.Set("code_challenge", codeChallenge)
+    params.Set("code_challenge_method", "S256")
+    return discovery.AuthorizationEndpoint + "?" + params.Encode(), nil
+}
+
+// Exchange 用授权码换取ID Token并校验签名、issuer、audience和nonce
+func (p *OIDCProvider) Exchange(code, codeVerifier, nonce string) (*OIDCClaims, error) {
+    discovery, err := p.discover()
+    if err != nil {
+        return nil, err
+    }
+
+    data := url.Values{}
+    data.Set("grant_type", "authorization_code")
+    data.Set("code", code)
+    data.Set("redirect_uri", p.RedirectURI)
+    data.Set("client_id", p.ClientID)
+    data.Set("client_secret", p.ClientSecret)
+    data.Set("code_verifier", codeVerifier)
+
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.PostForm(discovery.TokenEndpoint, data)
+    if err != nil {
+        return nil, fmt.Errorf("请求OIDC令牌失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    var tokenResp struct {
+        IDToken          string `json:"id_token"`
+        Error            string `json:"error"`
+        ErrorDescription string `json:"error_description"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+        return nil, fmt.Errorf("解析OIDC令牌响应失败: %v", err)
+    }
+    if tokenResp.IDToken == "" {
+        return nil, fmt.Errorf("获取OIDC令牌失败: %s %s", tokenResp.Error, tokenResp.ErrorDescription)
+    }
+
+    return p.verify(tokenResp.IDToken, discovery.Issuer, nonce)
+}
+
+// verify 校验ID Token
+func (p *OIDCProvider) verify(idToken, issuer, nonce string) (*OIDCClaims, error) {
+    claims := &OIDCClaims{}
+    _, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+        if token.Method != jwt.SigningMethodRS256 {
+            return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+        }
+        kid, _ := token.Header["kid"].(string)
+        return p.publicKey(kid)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("ID Token校验失败: %v", err)
+    }
+
+    if !claims.VerifyIssuer(issuer, true) {
+        return nil, fmt.Errorf("ID Token的issuer不匹配: %s", claims.Issuer)
+    }
+    if !claims.VerifyAudience(p.ClientID, true) {
+        return nil, fmt.Errorf("ID Token的audience不匹配")
+    }
+    if claims.Nonce != nonce {
+        return nil, fmt.Errorf("ID Token的nonce不匹配")
+    }
+    if claims.Subject == "" {
+        return nil, fmt.Errorf("ID Token缺少sub")
+    }
+    return claims, nil
+}
+
+// discover 获取并缓存discovery文档
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if p.discovery != nil {
+        return p.discovery, nil
+    }
+
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Get(p.Issuer + "/.well-known/openid-configuration")
+    if err != nil {
+        return nil, fmt.Errorf("获取OIDC配置失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    var discovery oidcDiscovery
+    if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+        return nil, fmt.Errorf("解析OIDC配置失败: %v", err)
+    }
+    if strings.TrimRight(discovery.Issuer, "/") != p.Issuer {
+        return nil, fmt.Errorf("OIDC配置中的issuer(%s)与配置不一致", discovery.Issuer)
+    }
+    if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+        return nil, fmt.Errorf("OIDC配置缺少必要的端点")
+    }
+
+    p.discovery = &discovery
+    return p.discovery, nil
+}
+
+// publicKey 按kid取签名公钥，找不到时重新拉取JWKS（最多每分钟一次）
+func (p *OIDCProvider) publicKey(kid string) (*rsa.PublicKey, error) {
+    p.mu.Lock()
+    key, ok := p.keys[kid]
+    stale := time.Since(p.keysAt) > time.Minute
+    p.mu.Unlock()
+    if ok {
+        return key, nil
+    }
+    if !stale && p.keys != nil {
+        return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+    }
+
+    discovery, err := p.discover()
+    if err != nil {
+        return nil, err
+    }
+    keys, err := fetchJWKS(discovery.JWKSURI)
+    if err != nil {
+        return nil, err
+    }
+
+    p.mu.Lock()
+    p.keys = keys
+    p.keysAt = time.Now()
+    p.mu.Unlock()
+
+    if key, ok := keys[kid]; ok {
+        return key, nil
+    }
+    return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+}
+
+// fetchJWKS 拉取JWKS中的RSA公钥
+func fetchJWKS(jwksURI string) (map[string]*rsa.PublicKey, error) {
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Get(jwksURI)
+    if err != nil {
+        return nil, fmt.Errorf("获取JWKS失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    var jwks struct {
+        Keys []struct {
+            Kid string `json:"kid"`
+            Kty string `json:"kty"`
+            N   string `json:"n"`
+            E   string `json:"e"`
+        } `json:"keys"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+        return nil, fmt.Errorf("解析JWKS失败: %v", err)
+    }
+
+    keys := make(map[string]*rsa.PublicKey)
+    for _, k := range jwks.Keys {
+        if k.Kty != "RSA" {
+            continue
+        }
+        n, err := base64.RawURLEncoding.DecodeString(k.N)
+        if err != nil {
+            continue
+        }
+        e, err := base64.RawURLEncoding.DecodeString(k.E)
+        if err != nil {
+            continue
+        }
+        keys[k.Kid] = &rsa.PublicKey{
+            N: new(big.Int).SetBytes(n),
+            E: int(new(big.Int).SetBytes(e).Int64()),
+        }
+    }
+    return keys, nil
+}
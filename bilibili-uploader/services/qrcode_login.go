@@ -0,0 +1,164 @@
+// services/qrcode_login.go - B站扫码登录
+package services
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+
+    qrcode "github.com/skip2/go-qrcode"
+)
+
+// 扫码状态
+const (
+    QRStatusWaiting   = "waiting"   // 未扫码
+    QRStatusScanned   = "scanned"   // 已扫码，等待手机确认
+    QRStatusConfirmed = "confirmed" // 已确认，登录成功
+    QRStatusExpired   = "expired"   // 二维码已失效
+)
+
+// 扫码轮询返回的业务码
+const (
+    qrCodeConfirmed = 0
+    qrCodeExpired   = 86038
+    qrCodeScanned   = 86090
+    qrCodeWaiting   = 86101
+)
+
+// QRLogin B站扫码登录服务
+type QRLogin struct {
+    PassportURL string
+    HTTPClient  *http.Client // 出站HTTP客户端，为空时使用DefaultHTTPClient
+}
+
+// NewQRLogin 创建扫码登录服务
+func NewQRLogin() *QRLogin {
+    return &QRLogin{
+        PassportURL: DefaultBilibiliEndpoints().Passport,
+        HTTPClient:  DefaultHTTPClient(),
+    }
+}
+
+// client 出站HTTP客户端
+func (q *QRLogin) client() *http.Client {
+    if q.HTTPClient != nil {
+        return q.HTTPClient
+    }
+    return DefaultHTTPClient()
+}
+
+// QRCode 登录二维码
+type QRCode struct {
+    URL string `json:"url"`        // 二维码内容
+    Key string `json:"qrcode_key"` // 轮询用的key
+}
+
+// QRPollResult 扫码轮询结果
+type QRPollResult struct {
+    Status       string            `json:"status"`
+    Message      string            `json:"message"`
+    UID          int64             `json:"uid,omitempty"`
+    Cookies      map[string]string `json:"-"` // SESSDATA、bili_jct、DedeUserID等
+    RefreshToken string            `json:"-"`
+}
+
+// Generate 申请登录二维码
+func (q *QRLogin) Generate(ctx context.Context) (*QRCode, error) {
+    req, err := http.NewRequestWithContext(ctx, "GET", q.PassportURL+"/x/passport-login/web/qrcode/generate", nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := q.client().Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("申请二维码失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        Data QRCode `json:"data"`
+    }
+    if err := decodeAPIResponse("qrcode_generate", resp, &result); err != nil {
+        return nil, fmt.Errorf("申请二维码失败: %w", err)
+    }
+
+    return &result.Data, nil
+}
+
+// Poll 查询扫码状态，确认后返回登录Cookie和refresh_token
+func (q *QRLogin) Poll(ctx context.Context, key string) (*QRPollResult, error) {
+    params := url.Values{}
+    params.Set("qrcode_key", key)
+
+    req, err := http.NewRequestWithContext(ctx, "GET", q.PassportURL+"/x/passport-login/web/qrcode/poll?"+params.Encode(), nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := q.client().Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("查询扫码状态失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        Data struct {
+            URL          string `json:"url"`
+            RefreshToken string `json:"refresh_token"`
+            Code         int    `json:"code"`
+            Message      string `json:"message"`
+        } `json:"data"`
+    }
+    if err := decodeAPIResponse("qrcode_poll", resp, &result); err != nil {
+        return nil, fmt.Errorf("查询扫码状态失败: %w", err)
+    }
+
+    pollResult := &QRPollResult{Message: result.Data.Message}
+    switch result.Data.Code {
+    case qrCodeWaiting:
+        pollResult.Status = QRStatusWaiting
+    case qrCodeScanned:
+        pollResult.Status = QRStatusScanned
+    case qrCodeExpired:
+        pollResult.Status = QRStatusExpired
+    case qrCodeConfirmed:
+        pollResult.Status = QRStatusConfirmed
+        pollResult.RefreshToken = result.Data.RefreshToken
+        pollResult.Cookies = make(map[string]string)
+        for _, cookie := range resp.Cookies() {
+            pollResult.Cookies[cookie.Name] = cookie.Value
+        }
+        // 部分情况下Cookie只出现在跳转URL的参数中
+        if len(pollResult.Cookies) == 0 && result.Data.URL != "" {
+            if u, err := url.Parse(result.Data.URL); err == nil {
+                for name, values := range u.Query() {
+                    if len(values) > 0 {
+                        pollResult.Cookies[name] = values[0]
+                    }
+                }
+            }
+        }
+        if pollResult.Cookies["SESSDATA"] == "" {
+            return nil, fmt.Errorf("登录成功但未获取到SESSDATA")
+        }
+        pollResult.UID, _ = strconv.ParseInt(pollResult.Cookies["DedeUserID"], 10, 64)
+    default:
+        return nil, fmt.Errorf("未知扫码状态: code=%d %s", result.Data.Code, result.Data.Message)
+    }
+
+    return pollResult, nil
+}
+
+// RenderQRCodePNG 将内容渲染为PNG二维码
+func RenderQRCodePNG(content string, size int) ([]byte, error) {
+    return qrcode.Encode(content, qrcode.Medium, size)
+}
+
+// RenderQRCodeText 将内容渲染为可在终端显示的二维码文本
+func RenderQRCodeText(content string) (string, error) {
+    q, err := qrcode.New(content, qrcode.Medium)
+    if err != nil {
+        return "", err
+    }
+    return q.ToSmallString(false), nil
+}
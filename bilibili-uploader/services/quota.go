@@ -0,0 +1,355 @@
+// services/quota.go - 按用户、工作区和账号的限流与上传配额
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// 配额主体类型
+const (
+    QuotaUser      = "user"
+    QuotaWorkspace = "workspace"
+    QuotaAccount   = "account"
+)
+
+// concurrentRetryAfter 并发任务已满时建议的重试间隔
+const concurrentRetryAfter = 30 * time.Second
+
+// QuotaLimits 一个主体的限制，0或负数表示不限制
+type QuotaLimits struct {
+    RequestsPerMinute int   `json:"requests_per_minute,omitempty"`
+    ConcurrentJobs    int   `json:"concurrent_jobs,omitempty"`
+    DailyUploads      int   `json:"daily_uploads,omitempty"`
+    DailyBytes        int64 `json:"daily_bytes,omitempty"`
+}
+
+// merge 用override中非0的字段覆盖（-1表示不限制）
+func (l QuotaLimits) merge(override QuotaLimits) QuotaLimits {
+    if override.RequestsPerMinute != 0 {
+        l.RequestsPerMinute = override.RequestsPerMinute
+    }
+    if override.ConcurrentJobs != 0 {
+        l.ConcurrentJobs = override.ConcurrentJobs
+    }
+    if override.DailyUploads != 0 {
+        l.DailyUploads = override.DailyUploads
+    }
+    if override.DailyBytes != 0 {
+        l.DailyBytes = override.DailyBytes
+    }
+    return l
+}
+
+// QuotaScope 一类主体的默认限制和按ID的覆盖
+type QuotaScope struct {
+    Default   QuotaLimits            `json:"default"`
+    Overrides map[string]QuotaLimits `json:"overrides,omitempty"`
+}
+
+// QuotaPolicy 限流和配额策略
+type QuotaPolicy struct {
+    Users      QuotaScope `json:"users"`
+    Workspaces QuotaScope `json:"workspaces"`
+    Accounts   QuotaScope `json:"accounts"`
+}
+
+// LoadQuotaPolicy 读取策略文件，文件中的用户默认值覆盖userDefault；path为空或文件不存在时只使用userDefault
+func LoadQuotaPolicy(path string, userDefault QuotaLimits) (*QuotaPolicy, error) {
+    policy := &QuotaPolicy{}
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil && !os.IsNotExist(err) {
+            return nil, fmt.Errorf("读取配额配置失败: %v", err)
+        }
+        if err == nil {
+            if err := json.Unmarshal(data, policy); err != nil {
+                return nil, fmt.Errorf("解析配额配置失败: %v", err)
+            }
+        }
+    }
+    policy.Users.Default = userDefault.merge(policy.Users.Default)
+    return policy, nil
+}
+
+// Limits 主体的有效限制
+func (p *QuotaPolicy) Limits(kind, id string) QuotaLimits {
+    var scope QuotaScope
+    switch kind {
+    case QuotaUser:
+        scope = p.Users
+    case QuotaWorkspace:
+        scope = p.Workspaces
+    case QuotaAccount:
+        scope = p.Accounts
+    }
+    return scope.Default.merge(scope.Overrides[id])
+}
+
+// QuotaSubject 受限制的主体
+type QuotaSubject struct {
+    Kind string `json:"kind"`
+    ID   string `json:"id"`
+}
+
+// key 用量表中的键
+func (s QuotaSubject) key() string {
+    return s.Kind + ":" + s.ID
+}
+
+// QuotaError 超出限制，RetryAfter为建议的重试等待时间
+type QuotaError struct {
+    Subject    QuotaSubject
+    Reason     string
+    RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+    return fmt.Sprintf("%s %s %s", quotaKindNames[e.Subject.Kind], e.Subject.ID, e.Reason)
+}
+
+// quotaKindNames 错误提示中的主体名称
+var quotaKindNames = map[string]string{
+    QuotaUser:      "用户",
+    QuotaWorkspace: "工作区",
+    QuotaAccount:   "账号",
+}
+
+// QuotaUsage 主体的当前用量
+type QuotaUsage struct {
+    Requests     int       `json:"requests"` // 当前一分钟窗口内的请求数
+    WindowStart  time.Time `json:"window_start"`
+    Concurrent   int       `json:"concurrent"`
+    Day          string    `json:"day"` // 本地日期，跨天时清零
+    Uploads      int       `json:"uploads"`
+    Bytes        int64     `json:"bytes"`
+    DailyResetAt time.Time `json:"daily_reset_at"`
+}
+
+// QuotaTracker 记录用量并检查限制；每日上传量持久化，请求数和并发数只在内存中
+type QuotaTracker struct {
+    mu     sync.Mutex
+    path   string
+    policy *QuotaPolicy
+    usage  map[string]*QuotaUsage
+    now    func() time.Time
+}
+
+// NewQuotaTracker 创建用量记录，path为空时只保存在内存中
+func NewQuotaTracker(path string, policy *QuotaPolicy) *QuotaTracker {
+    tracker := &QuotaTracker{
+        path:   path,
+        policy: policy,
+        usage:  make(map[string]*QuotaUsage),
+        now:    time.Now,
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            json.Unmarshal(data, &tracker.usage)
+        }
+    }
+    for _, usage := range tracker.usage {
+        usage.Concurrent = 0
+    }
+    return tracker
+}
+
+// Policy 当前策略
+func (t *QuotaTracker) Policy() *QuotaPolicy {
+    return t.policy
+}
+
+// AllowRequest 记录一次请求，超出每分钟请求数时返回错误（请求不计入）
+func (t *QuotaTracker) AllowRequest(subjects ...QuotaSubject) *QuotaError {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    now := t.now()
+    for _, subject := range subjects {
+        limit := t.policy.Limits(subject.Kind, subject.ID).RequestsPerMinute
+        usage := t.current(subject, now)
+        if limit > 0 && usage.Requests >= limit {
+            return &QuotaError{
+                Subject:    subject,
+                Reason:     fmt.Sprintf("每分钟请求数超过%d", limit),
+                RetryAfter: usage.WindowStart.Add(time.Minute).Sub(now),
+            }
+        }
+    }
+    for _, subject := range subjects {
+        t.current(subject, now).Requests++
+    }
+    return nil
+}
+
+// CheckUpload 检查一次上传是否会超出限制，不占用名额；用于在读取请求体之前尽早拒绝
+func (t *QuotaTracker) CheckUpload(size int64, subjects ...QuotaSubject) *QuotaError {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    return t.checkUpload(t.now(), size, subjects)
+}
+
+// RemainingBytes 各主体今日剩余上传流量的最小值，都不限制时返回-1
+func (t *QuotaTracker) RemainingBytes(subjects ...QuotaSubject) int64 {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    now := t.now()
+    remaining := int64(-1)
+    for _, subject := range subjects {
+        limit := t.policy.Limits(subject.Kind, subject.ID).DailyBytes
+        if limit <= 0 {
+            continue
+        }
+        left := limit - t.current(subject, now).Bytes
+        if left < 0 {
+            left = 0
+        }
+        if remaining < 0 || left < remaining {
+            remaining = left
+        }
+    }
+    return remaining
+}
+
+// AcquireUpload 为一次上传占用并发名额并计入每日用量，全部主体都未超限时才生效
+// 返回的release在上传结束后调用以释放并发名额
+func (t *QuotaTracker) AcquireUpload(size int64, subjects ...QuotaSubject) (func(), *QuotaError) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    now := t.now()
+    if err := t.checkUpload(now, size, subjects); err != nil {
+        return nil, err
+    }
+
+    for _, subject := range subjects {
+        usage := t.current(subject, now)
+        usage.Concurrent++
+        usage.Uploads++
+        usage.Bytes += size
+    }
+    t.persist()
+    return t.releaser(subjects), nil
+}
+
+// AcquireJob 为转码等不计入每日上传量的任务占用并发名额，返回的release在任务结束后调用
+func (t *QuotaTracker) AcquireJob(subjects ...QuotaSubject) (func(), *QuotaError) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    now := t.now()
+    for _, subject := range subjects {
+        if err := t.checkConcurrent(subject, t.current(subject, now)); err != nil {
+            return nil, err
+        }
+    }
+    for _, subject := range subjects {
+        t.current(subject, now).Concurrent++
+    }
+    return t.releaser(subjects), nil
+}
+
+// checkUpload 检查并发数、每日次数和流量（调用方需持有锁）
+func (t *QuotaTracker) checkUpload(now time.Time, size int64, subjects []QuotaSubject) *QuotaError {
+    for _, subject := range subjects {
+        limits := t.policy.Limits(subject.Kind, subject.ID)
+        usage := t.current(subject, now)
+        if err := t.checkConcurrent(subject, usage); err != nil {
+            return err
+        }
+        switch {
+        case limits.DailyUploads > 0 && usage.Uploads >= limits.DailyUploads:
+            return &QuotaError{
+                Subject:    subject,
+                Reason:     fmt.Sprintf("今日上传次数已达%d次", limits.DailyUploads),
+                RetryAfter: usage.DailyResetAt.Sub(now),
+            }
+        case limits.DailyBytes > 0 && usage.Bytes+size > limits.DailyBytes:
+            return &QuotaError{
+                Subject:    subject,
+                Reason:     fmt.Sprintf("今日上传流量将超过%d字节", limits.DailyBytes),
+                RetryAfter: usage.DailyResetAt.Sub(now),
+            }
+        }
+    }
+    return nil
+}
+
+// checkConcurrent 检查并发任务数（调用方需持有锁）
+func (t *QuotaTracker) checkConcurrent(subject QuotaSubject, usage *QuotaUsage) *QuotaError {
+    limit := t.policy.Limits(subject.Kind, subject.ID).ConcurrentJobs
+    if limit > 0 && usage.Concurrent >= limit {
+        return &QuotaError{
+            Subject:    subject,
+            Reason:     fmt.Sprintf("同时进行的任务超过%d个", limit),
+            RetryAfter: concurrentRetryAfter,
+        }
+    }
+    return nil
+}
+
+// releaser 返回只生效一次的并发名额释放函数
+func (t *QuotaTracker) releaser(subjects []QuotaSubject) func() {
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            for _, subject := range subjects {
+                if usage, ok := t.usage[subject.key()]; ok && usage.Concurrent > 0 {
+                    usage.Concurrent--
+                }
+            }
+        })
+    }
+}
+
+// Usage 主体的当前用量
+func (t *QuotaTracker) Usage(subject QuotaSubject) QuotaUsage {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    return *t.current(subject, t.now())
+}
+
+// current 取出主体用量，并按时间清零过期的分钟窗口和每日用量（调用方需持有锁）
+func (t *QuotaTracker) current(subject QuotaSubject, now time.Time) *QuotaUsage {
+    usage, ok := t.usage[subject.key()]
+    if !ok {
+        usage = &QuotaUsage{}
+        t.usage[subject.key()] = usage
+    }
+    if now.Sub(usage.WindowStart) >= time.Minute {
+        usage.WindowStart = now
+        usage.Requests = 0
+    }
+    if day := now.Format("2006-01-02"); usage.Day != day {
+        usage.Day = day
+        usage.Uploads = 0
+        usage.Bytes = 0
+        y, m, d := now.Date()
+        usage.DailyResetAt = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+    }
+    return usage
+}
+
+// persist 写入文件（调用方需持有锁）
+func (t *QuotaTracker) persist() error {
+    if t.path == "" {
+        return nil
+    }
+    data, err := json.MarshalIndent(t.usage, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(t.path, data, 0600)
+}
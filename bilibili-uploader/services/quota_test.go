@@ -0,0 +1,242 @@
+package services
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// newTestTracker 创建使用固定时钟的用量记录，返回推进时钟的函数
+func newTestTracker(t *testing.T, path string, policy *QuotaPolicy) (*QuotaTracker, func(time.Duration)) {
+    t.Helper()
+    now := time.Date(2026, 5, 1, 23, 0, 0, 0, time.Local)
+    tracker := NewQuotaTracker(path, policy)
+    tracker.now = func() time.Time { return now }
+    return tracker, func(d time.Duration) { now = now.Add(d) }
+}
+
+var testUser = QuotaSubject{Kind: QuotaUser, ID: "u1"}
+
+func TestQuotaPolicyLimits(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "quota.json")
+    data := `{
+        "users": {"default": {"daily_uploads": 5}, "overrides": {"vip": {"daily_uploads": -1, "daily_bytes": 100}}},
+        "accounts": {"default": {"concurrent_jobs": 1}}
+    }`
+    if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+        t.Fatal(err)
+    }
+    policy, err := LoadQuotaPolicy(path, QuotaLimits{RequestsPerMinute: 60, DailyUploads: 10})
+    if err != nil {
+        t.Fatalf("LoadQuotaPolicy() error = %v", err)
+    }
+
+    tests := []struct {
+        name string
+        kind string
+        id   string
+        want QuotaLimits
+    }{
+        {name: "文件中的用户默认值覆盖环境变量", kind: QuotaUser, id: "u1", want: QuotaLimits{RequestsPerMinute: 60, DailyUploads: 5}},
+        {name: "按ID覆盖，-1表示不限制", kind: QuotaUser, id: "vip", want: QuotaLimits{RequestsPerMinute: 60, DailyUploads: -1, DailyBytes: 100}},
+        {name: "账号默认值", kind: QuotaAccount, id: "a1", want: QuotaLimits{ConcurrentJobs: 1}},
+        {name: "未配置的工作区不限制", kind: QuotaWorkspace, id: "w1", want: QuotaLimits{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := policy.Limits(tt.kind, tt.id); got != tt.want {
+                t.Errorf("Limits() = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+
+    if _, err := LoadQuotaPolicy(filepath.Join(t.TempDir(), "missing.json"), QuotaLimits{}); err != nil {
+        t.Errorf("文件不存在时 error = %v, want nil", err)
+    }
+    os.WriteFile(path, []byte("{"), 0600)
+    if _, err := LoadQuotaPolicy(path, QuotaLimits{}); err == nil {
+        t.Error("文件格式错误时 error = nil, want error")
+    }
+}
+
+func TestAllowRequestRetryAfter(t *testing.T) {
+    policy := &QuotaPolicy{Users: QuotaScope{Default: QuotaLimits{RequestsPerMinute: 2}}}
+    tracker, advance := newTestTracker(t, "", policy)
+
+    for i := 0; i < 2; i++ {
+        if err := tracker.AllowRequest(testUser); err != nil {
+            t.Fatalf("第%d次请求 error = %v", i+1, err)
+        }
+    }
+    advance(20 * time.Second)
+    err := tracker.AllowRequest(testUser)
+    if err == nil {
+        t.Fatal("超出每分钟请求数 error = nil, want error")
+    }
+    if err.RetryAfter != 40*time.Second {
+        t.Errorf("RetryAfter = %v, want 40s", err.RetryAfter)
+    }
+
+    advance(40 * time.Second)
+    if err := tracker.AllowRequest(testUser); err != nil {
+        t.Errorf("新窗口的请求 error = %v", err)
+    }
+}
+
+func TestAcquireUploadLimits(t *testing.T) {
+    tests := []struct {
+        name      string
+        limits    QuotaLimits
+        sizes     []int64 // 依次占用（不释放）的上传大小
+        wantFail  int     // 第一次失败的序号，-1表示全部成功
+        wantRetry time.Duration
+    }{
+        {name: "不限制", sizes: []int64{1 << 30, 1 << 30}, wantFail: -1},
+        {name: "并发数", limits: QuotaLimits{ConcurrentJobs: 1}, sizes: []int64{1, 1}, wantFail: 1, wantRetry: concurrentRetryAfter},
+        {name: "每日次数", limits: QuotaLimits{DailyUploads: 2}, sizes: []int64{1, 1, 1}, wantFail: 2, wantRetry: time.Hour},
+        {name: "每日流量", limits: QuotaLimits{DailyBytes: 100}, sizes: []int64{60, 40, 1}, wantFail: 2, wantRetry: time.Hour},
+        {name: "单次超过流量", limits: QuotaLimits{DailyBytes: 100}, sizes: []int64{101}, wantFail: 0, wantRetry: time.Hour},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tracker, _ := newTestTracker(t, "", &QuotaPolicy{Users: QuotaScope{Default: tt.limits}})
+            for i, size := range tt.sizes {
+                _, err := tracker.AcquireUpload(size, testUser)
+                if i != tt.wantFail {
+                    if err != nil {
+                        t.Fatalf("第%d次上传 error = %v", i, err)
+                    }
+                    continue
+                }
+                if err == nil {
+                    t.Fatalf("第%d次上传 error = nil, want error", i)
+                }
+                if err.RetryAfter != tt.wantRetry {
+                    t.Errorf("RetryAfter = %v, want %v", err.RetryAfter, tt.wantRetry)
+                }
+                return
+            }
+        })
+    }
+}
+
+func TestAcquireUploadAllSubjects(t *testing.T) {
+    account := QuotaSubject{Kind: QuotaAccount, ID: "a1"}
+    policy := &QuotaPolicy{Accounts: QuotaScope{Default: QuotaLimits{DailyUploads: 1}}}
+    tracker, _ := newTestTracker(t, "", policy)
+
+    if _, err := tracker.AcquireUpload(10, testUser, account); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := tracker.AcquireUpload(10, testUser, account); err == nil || err.Subject != account {
+        t.Fatalf("账号超限 error = %v, want 账号a1", err)
+    }
+    // 超限的上传不计入任何主体
+    if usage := tracker.Usage(testUser); usage.Uploads != 1 || usage.Bytes != 10 {
+        t.Errorf("用户用量 = %+v, want 1次10字节", usage)
+    }
+}
+
+func TestAcquireUploadRelease(t *testing.T) {
+    tracker, _ := newTestTracker(t, "", &QuotaPolicy{Users: QuotaScope{Default: QuotaLimits{ConcurrentJobs: 1}}})
+
+    release, err := tracker.AcquireUpload(1, testUser)
+    if err != nil {
+        t.Fatal(err)
+    }
+    release()
+    release() // 重复调用只释放一次
+    if usage := tracker.Usage(testUser); usage.Concurrent != 0 {
+        t.Errorf("Concurrent = %d, want 0", usage.Concurrent)
+    }
+    if _, err := tracker.AcquireUpload(1, testUser); err != nil {
+        t.Errorf("释放后再次上传 error = %v", err)
+    }
+}
+
+func TestAcquireJob(t *testing.T) {
+    limits := QuotaLimits{ConcurrentJobs: 1, DailyUploads: 1}
+    tracker, _ := newTestTracker(t, "", &QuotaPolicy{Users: QuotaScope{Default: limits}})
+
+    release, err := tracker.AcquireJob(testUser)
+    if err != nil {
+        t.Fatalf("AcquireJob() error = %v", err)
+    }
+    if _, err := tracker.AcquireUpload(1, testUser); err == nil {
+        t.Error("任务占用并发名额时上传 error = nil, want error")
+    }
+    release()
+
+    // 任务不计入每日上传次数
+    if _, err := tracker.AcquireUpload(1, testUser); err != nil {
+        t.Errorf("任务结束后上传 error = %v", err)
+    }
+}
+
+func TestCheckUploadAndRemainingBytes(t *testing.T) {
+    policy := &QuotaPolicy{
+        Users:      QuotaScope{Default: QuotaLimits{DailyBytes: 100}},
+        Workspaces: QuotaScope{Overrides: map[string]QuotaLimits{"w1": {DailyBytes: 50}}},
+    }
+    tracker, _ := newTestTracker(t, "", policy)
+    workspace := QuotaSubject{Kind: QuotaWorkspace, ID: "w1"}
+    other := QuotaSubject{Kind: QuotaWorkspace, ID: "w2"}
+
+    if got := tracker.RemainingBytes(other); got != -1 {
+        t.Errorf("不限制时 RemainingBytes() = %d, want -1", got)
+    }
+    if got := tracker.RemainingBytes(testUser, workspace); got != 50 {
+        t.Errorf("RemainingBytes() = %d, want 50", got)
+    }
+
+    if err := tracker.CheckUpload(80, testUser); err != nil {
+        t.Errorf("CheckUpload(80) error = %v", err)
+    }
+    if err := tracker.CheckUpload(80, testUser, workspace); err == nil {
+        t.Error("超过工作区流量 CheckUpload() error = nil, want error")
+    }
+    // CheckUpload不占用配额
+    if usage := tracker.Usage(testUser); usage.Bytes != 0 || usage.Concurrent != 0 {
+        t.Errorf("CheckUpload后用量 = %+v, want 0", usage)
+    }
+
+    if _, err := tracker.AcquireUpload(90, testUser); err != nil {
+        t.Fatal(err)
+    }
+    if got := tracker.RemainingBytes(testUser); got != 10 {
+        t.Errorf("上传后 RemainingBytes() = %d, want 10", got)
+    }
+}
+
+func TestQuotaDailyReset(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "quota_usage.json")
+    policy := &QuotaPolicy{Users: QuotaScope{Default: QuotaLimits{DailyUploads: 1, DailyBytes: 100}}}
+    tracker, _ := newTestTracker(t, path, policy)
+
+    release, err := tracker.AcquireUpload(100, testUser)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := tracker.AcquireUpload(1, testUser); err == nil || err.RetryAfter != time.Hour {
+        t.Fatalf("当日超限 error = %v, want RetryAfter 1h", err)
+    }
+
+    // 重启后每日用量保留，并发数清零
+    reloaded, advance := newTestTracker(t, path, policy)
+    usage := reloaded.Usage(testUser)
+    if usage.Uploads != 1 || usage.Bytes != 100 || usage.Concurrent != 0 {
+        t.Errorf("重新加载后用量 = %+v", usage)
+    }
+    release()
+
+    // 跨过午夜后清零
+    advance(time.Hour)
+    if _, err := reloaded.AcquireUpload(100, testUser); err != nil {
+        t.Errorf("第二天上传 error = %v", err)
+    }
+    if usage := reloaded.Usage(testUser); usage.Day != "2026-05-02" || usage.Uploads != 1 {
+        t.Errorf("第二天用量 = %+v", usage)
+    }
+}
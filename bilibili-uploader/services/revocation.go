@@ -0,0 +1,79 @@
+// services/revocation.go - JWT吊销列表
+package services
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// RevocationList 按jti记录已吊销的JWT，记录保留到JWT自然过期
+type RevocationList struct {
+    mu      sync.Mutex
+    path    string
+    revoked map[string]time.Time // jti -> JWT过期时间
+}
+
+// NewRevocationList 创建吊销列表，path为空时只保存在内存中
+func NewRevocationList(path string) *RevocationList {
+    list := &RevocationList{
+        path:    path,
+        revoked: make(map[string]time.Time),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            json.Unmarshal(data, &list.revoked)
+        }
+    }
+    return list
+}
+
+// Revoke 吊销jti，expiresAt为JWT的过期时间
+func (l *RevocationList) Revoke(jti string, expiresAt time.Time) error {
+    if jti == "" {
+        return nil
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.revoked[jti] = expiresAt
+    l.purgeExpired()
+    return l.persist()
+}
+
+// IsRevoked jti是否已被吊销
+func (l *RevocationList) IsRevoked(jti string) bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    _, ok := l.revoked[jti]
+    return ok
+}
+
+// purgeExpired 清除已自然过期的记录（调用方需持有锁）
+func (l *RevocationList) purgeExpired() {
+    now := time.Now()
+    for jti, expiresAt := range l.revoked {
+        if expiresAt.Before(now) {
+            delete(l.revoked, jti)
+        }
+    }
+}
+
+// persist 写入文件（调用方需持有锁）
+func (l *RevocationList) persist() error {
+    if l.path == "" {
+        return nil
+    }
+    data, err := json.MarshalIndent(l.revoked, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(l.path, data, 0600)
+}
@@ -0,0 +1,208 @@
+// services/session.go - 服务端会话存储
+package services
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+// 会话使用的凭证类型
+const (
+    SessionKindOAuth  = "oauth"  // OAuth令牌，保存在TokenStore
+    SessionKindCookie = "cookie" // 网页Cookie，保存在CredentialStore
+    SessionKindDev    = "dev"    // 模拟模式的开发者身份，没有B站凭证
+    SessionKindUser   = "user"   // 本服务用户（密码或OIDC）登录，使用关联的B站账号
+)
+
+// Session 登录会话，JWT中只携带会话ID
+type Session struct {
+    ID         string    `json:"id"`
+    OwnerID    string    `json:"owner_id"`   // 用户ID，关联的B站账号归属于该用户
+    AccountID  string    `json:"account_id"` // 本次登录使用的关联账号
+    UID        int64     `json:"uid"`
+    Username   string    `json:"username"`
+    Kind       string    `json:"kind"`
+    TokenID    string    `json:"token_id,omitempty"` // 签发的JWT的jti
+    UserAgent  string    `json:"user_agent,omitempty"`
+    IP         string    `json:"ip,omitempty"`
+    CreatedAt  time.Time `json:"created_at"`
+    LastSeenAt time.Time `json:"last_seen_at"`
+    ExpiresAt  time.Time `json:"expires_at"`
+    RevokedAt  time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active 会话是否仍然有效
+func (s *Session) Active() bool {
+    return s.RevokedAt.IsZero() && time.Now().Before(s.ExpiresAt)
+}
+
+// SessionStore 会话存储，持久化到JSON文件
+type SessionStore struct {
+    mu       sync.Mutex
+    path     string
+    sessions map[string]*Session
+}
+
+// NewSessionStore 创建会话存储，path为空时只保存在内存中
+func NewSessionStore(path string) *SessionStore {
+    store := &SessionStore{
+        path:     path,
+        sessions: make(map[string]*Session),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*Session
+            if err := json.Unmarshal(data, &list); err == nil {
+                for _, session := range list {
+                    store.sessions[session.ID] = session
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Create 创建会话
+func (s *SessionStore) Create(session *Session, ttl time.Duration) (*Session, error) {
+    id, err := RandomID(32)
+    if err != nil {
+        return nil, err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    now := time.Now()
+    created := *session
+    created.ID = id
+    created.CreatedAt = now
+    created.LastSeenAt = now
+    created.ExpiresAt = now.Add(ttl)
+    s.sessions[id] = &created
+    s.purgeExpired()
+
+    if err := s.persist(); err != nil {
+        return nil, err
+    }
+    copied := created
+    return &copied, nil
+}
+
+// Get 获取有效会话并更新最近访问时间
+func (s *SessionStore) Get(id string) (*Session, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    session, ok := s.sessions[id]
+    if !ok {
+        return nil, fmt.Errorf("会话不存在")
+    }
+    if !session.RevokedAt.IsZero() {
+        return nil, fmt.Errorf("会话已注销")
+    }
+    if !session.Active() {
+        return nil, fmt.Errorf("会话已过期")
+    }
+
+    // 降低写盘频率：一分钟内只记录一次访问
+    if time.Since(session.LastSeenAt) > time.Minute {
+        session.LastSeenAt = time.Now()
+        s.persist()
+    }
+
+    copied := *session
+    return &copied, nil
+}
+
+// List 列出用户的有效会话（ownerID为空时列出全部），按最近访问时间倒序
+func (s *SessionStore) List(ownerID string) []Session {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]Session, 0)
+    for _, session := range s.sessions {
+        if (ownerID == "" || session.OwnerID == ownerID) && session.Active() {
+            list = append(list, *session)
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].LastSeenAt.After(list[j].LastSeenAt)
+    })
+    return list
+}
+
+// Revoke 注销会话
+func (s *SessionStore) Revoke(id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    session, ok := s.sessions[id]
+    if !ok {
+        return fmt.Errorf("会话不存在")
+    }
+    if session.RevokedAt.IsZero() {
+        session.RevokedAt = time.Now()
+    }
+    return s.persist()
+}
+
+// RevokeAll 注销用户的全部会话，返回注销数量
+func (s *SessionStore) RevokeAll(ownerID string) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    count := 0
+    now := time.Now()
+    for _, session := range s.sessions {
+        if session.OwnerID == ownerID && session.Active() {
+            session.RevokedAt = now
+            count++
+        }
+    }
+    return count, s.persist()
+}
+
+// purgeExpired 清除过期超过一天的会话（调用方需持有锁）
+func (s *SessionStore) purgeExpired() {
+    cutoff := time.Now().Add(-24 * time.Hour)
+    for id, session := range s.sessions {
+        if session.ExpiresAt.Before(cutoff) {
+            delete(s.sessions, id)
+        }
+    }
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *SessionStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*Session, 0, len(s.sessions))
+    for _, session := range s.sessions {
+        list = append(list, session)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
+
+// RandomID 生成n字节的随机十六进制ID
+func RandomID(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", fmt.Errorf("生成随机ID失败: %v", err)
+    }
+    return hex.EncodeToString(b), nil
+}
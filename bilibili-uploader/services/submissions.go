@@ -0,0 +1,272 @@
+// services/submissions.go - 工作区的待审核稿件
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+// 稿件审核状态
+const (
+    SubmissionPending    = "pending"    // 视频已上传，等待发布者审核
+    SubmissionRejected   = "rejected"   // 审核未通过
+    SubmissionPublishing = "publishing" // 审核通过，正在提交稿件
+    SubmissionPublished  = "published"  // 审核通过并已提交稿件
+    SubmissionFailed     = "failed"     // 审核通过但提交稿件失败，可再次审核
+)
+
+// SubmissionPublishTimeout 审核通过后提交稿件的最长时间，超过后认为提交已中断，稿件回到失败状态以便再次审核
+const SubmissionPublishTimeout = DraftPublishTimeout
+
+// 审核结果
+const (
+    ReviewApprove = "approve"
+    ReviewReject  = "reject"
+)
+
+// Review 一次审核记录
+type Review struct {
+    UserID     string    `json:"user_id"`
+    Decision   string    `json:"decision"`
+    Comment    string    `json:"comment,omitempty"`
+    ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+// Submission 编辑上传、等待发布者审核的稿件
+type Submission struct {
+    ID          string            `json:"id"`
+    WorkspaceID string            `json:"workspace_id"`
+    AccountID   string            `json:"account_id"`
+    SubmittedBy string            `json:"submitted_by"`
+    Filename    string            `json:"filename"` // 预上传返回的服务端文件名，提交稿件时使用
+    File        string            `json:"file"`     // 原始文件名
+    Params      VideoUploadParams `json:"params"`
+    Status      string            `json:"status"`
+    Error       string            `json:"error,omitempty"`
+    BVID        string            `json:"bvid,omitempty"`
+    Reviews     []Review          `json:"reviews"`
+    CreatedAt   time.Time         `json:"created_at"`
+    UpdatedAt   time.Time         `json:"updated_at"`
+
+    PublishStartedAt time.Time `json:"publish_started_at,omitempty"` // 审核通过、开始提交稿件的时间
+}
+
+// publishStalled 提交中的稿件是否已超时
+func (sub *Submission) publishStalled() bool {
+    return sub.Status == SubmissionPublishing && time.Since(sub.PublishStartedAt) > SubmissionPublishTimeout
+}
+
+// SubmissionStore 待审核稿件存储，持久化到JSON文件
+type SubmissionStore struct {
+    mu          sync.Mutex
+    path        string
+    submissions map[string]*Submission
+}
+
+// NewSubmissionStore 创建稿件存储，path为空时只保存在内存中
+// 加载时仍处于提交中的稿件是上次运行中断留下的，改为失败状态以便再次审核
+func NewSubmissionStore(path string) *SubmissionStore {
+    store := &SubmissionStore{
+        path:        path,
+        submissions: make(map[string]*Submission),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*Submission
+            if err := json.Unmarshal(data, &list); err == nil {
+                interrupted := false
+                for _, submission := range list {
+                    if submission.Status == SubmissionPublishing {
+                        interruptSubmission(submission)
+                        interrupted = true
+                    }
+                    store.submissions[submission.ID] = submission
+                }
+                if interrupted {
+                    store.persist()
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Create 保存新的待审核稿件
+func (s *SubmissionStore) Create(submission *Submission) (*Submission, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    id, err := RandomID(8)
+    if err != nil {
+        return nil, err
+    }
+    saved := *submission
+    saved.ID = id
+    saved.Status = SubmissionPending
+    saved.Reviews = []Review{}
+    saved.CreatedAt = time.Now()
+    saved.UpdatedAt = saved.CreatedAt
+    s.submissions[id] = &saved
+
+    return saved.copy(), s.persist()
+}
+
+// Get 获取工作区中的稿件
+func (s *SubmissionStore) Get(workspaceID, id string) (*Submission, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    submission, err := s.find(workspaceID, id)
+    if err != nil {
+        return nil, false
+    }
+    return submission.copy(), true
+}
+
+// List 按创建时间倒序列出工作区的稿件，status为空时列出全部
+func (s *SubmissionStore) List(workspaceID, status string) []*Submission {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]*Submission, 0)
+    changed := false
+    for _, submission := range s.submissions {
+        if submission.WorkspaceID == workspaceID && submission.publishStalled() {
+            interruptSubmission(submission)
+            changed = true
+        }
+        if submission.WorkspaceID == workspaceID && (status == "" || submission.Status == status) {
+            list = append(list, submission.copy())
+        }
+    }
+    if changed {
+        s.persist()
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].CreatedAt.After(list[j].CreatedAt)
+    })
+    return list
+}
+
+// UpdateParams 修改待审核稿件的投稿信息
+func (s *SubmissionStore) UpdateParams(workspaceID, id string, params VideoUploadParams) (*Submission, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    submission, err := s.find(workspaceID, id)
+    if err != nil {
+        return nil, err
+    }
+    if submission.Status != SubmissionPending && submission.Status != SubmissionFailed {
+        return nil, fmt.Errorf("稿件当前状态为%s，不能修改", submission.Status)
+    }
+    submission.Params = params
+    submission.UpdatedAt = time.Now()
+    return submission.copy(), s.persist()
+}
+
+// BeginReview 记录审核意见；通过时返回稿件副本，由调用方提交稿件后调用Finish
+// 只有待审核或提交失败的稿件可以审核
+func (s *SubmissionStore) BeginReview(workspaceID, id string, review Review) (*Submission, error) {
+    if review.Decision != ReviewApprove && review.Decision != ReviewReject {
+        return nil, fmt.Errorf("无效的审核结果: %s", review.Decision)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    submission, err := s.find(workspaceID, id)
+    if err != nil {
+        return nil, err
+    }
+    if submission.Status != SubmissionPending && submission.Status != SubmissionFailed {
+        return nil, fmt.Errorf("稿件当前状态为%s，不能再次审核", submission.Status)
+    }
+
+    review.ReviewedAt = time.Now()
+    submission.Reviews = append(submission.Reviews, review)
+    submission.UpdatedAt = review.ReviewedAt
+    if review.Decision == ReviewReject {
+        submission.Status = SubmissionRejected
+    } else {
+        // 提交期间的状态不允许再次审核，避免并发审核重复提交
+        submission.Status = SubmissionPublishing
+        submission.PublishStartedAt = review.ReviewedAt
+    }
+    return submission.copy(), s.persist()
+}
+
+// Finish 记录提交稿件的结果
+func (s *SubmissionStore) Finish(id, bvid string, submitErr error) (*Submission, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    submission, ok := s.submissions[id]
+    if !ok {
+        return nil, fmt.Errorf("稿件不存在")
+    }
+    if submitErr != nil {
+        submission.Status = SubmissionFailed
+        submission.Error = submitErr.Error()
+    } else {
+        submission.Status = SubmissionPublished
+        submission.Error = ""
+        submission.BVID = bvid
+    }
+    submission.PublishStartedAt = time.Time{}
+    submission.UpdatedAt = time.Now()
+    return submission.copy(), s.persist()
+}
+
+// find 查找工作区中的稿件，提交超时的稿件改为失败（调用方需持有锁）
+func (s *SubmissionStore) find(workspaceID, id string) (*Submission, error) {
+    submission, ok := s.submissions[id]
+    if !ok || submission.WorkspaceID != workspaceID {
+        return nil, fmt.Errorf("稿件不存在")
+    }
+    if submission.publishStalled() {
+        interruptSubmission(submission)
+        s.persist()
+    }
+    return submission, nil
+}
+
+// interruptSubmission 把中断的提交改为失败状态
+func interruptSubmission(submission *Submission) {
+    submission.Status = SubmissionFailed
+    submission.Error = publishInterruptedError
+    submission.PublishStartedAt = time.Time{}
+    submission.UpdatedAt = time.Now()
+}
+
+// copy 深拷贝
+func (sub *Submission) copy() *Submission {
+    copied := *sub
+    copied.Reviews = append(make([]Review, 0, len(sub.Reviews)), sub.Reviews...)
+    copied.Params.Tags = append([]string(nil), sub.Params.Tags...)
+    return &copied
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *SubmissionStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*Submission, 0, len(s.submissions))
+    for _, submission := range s.submissions {
+        list = append(list, submission)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func newTestSubmission(t *testing.T, store *SubmissionStore) *Submission {
+    t.Helper()
+    submission, err := store.Create(&Submission{
+        WorkspaceID: "ws",
+        AccountID:   "acc",
+        SubmittedBy: "editor",
+        Filename:    "n1",
+        Params:      VideoUploadParams{Title: "标题", Tags: []string{"a"}},
+    })
+    if err != nil {
+        t.Fatalf("Create() error = %v", err)
+    }
+    return submission
+}
+
+func TestSubmissionReviewTransitions(t *testing.T) {
+    tests := []struct {
+        name       string
+        setup      func(store *SubmissionStore, id string)
+        decision   string
+        wantErr    bool
+        wantStatus string
+    }{
+        {name: "待审核稿件通过", decision: ReviewApprove, wantStatus: SubmissionPublishing},
+        {name: "待审核稿件驳回", decision: ReviewReject, wantStatus: SubmissionRejected},
+        {name: "无效的审核结果", decision: "maybe", wantErr: true, wantStatus: SubmissionPending},
+        {
+            name: "提交失败后可再次审核",
+            setup: func(store *SubmissionStore, id string) {
+                store.BeginReview("ws", id, Review{UserID: "p", Decision: ReviewApprove})
+                store.Finish(id, "", errors.New("投稿失败"))
+            },
+            decision:   ReviewApprove,
+            wantStatus: SubmissionPublishing,
+        },
+        {
+            name: "提交中不能再次审核",
+            setup: func(store *SubmissionStore, id string) {
+                store.BeginReview("ws", id, Review{UserID: "p", Decision: ReviewApprove})
+            },
+            decision:   ReviewReject,
+            wantErr:    true,
+            wantStatus: SubmissionPublishing,
+        },
+        {
+            name: "已驳回不能再次审核",
+            setup: func(store *SubmissionStore, id string) {
+                store.BeginReview("ws", id, Review{UserID: "p", Decision: ReviewReject})
+            },
+            decision:   ReviewApprove,
+            wantErr:    true,
+            wantStatus: SubmissionRejected,
+        },
+        {
+            name: "已发布不能再次审核",
+            setup: func(store *SubmissionStore, id string) {
+                store.BeginReview("ws", id, Review{UserID: "p", Decision: ReviewApprove})
+                store.Finish(id, "BV1xx", nil)
+            },
+            decision:   ReviewApprove,
+            wantErr:    true,
+            wantStatus: SubmissionPublished,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store := NewSubmissionStore("")
+            submission := newTestSubmission(t, store)
+            if tt.setup != nil {
+                tt.setup(store, submission.ID)
+            }
+
+            _, err := store.BeginReview("ws", submission.ID, Review{UserID: "p", Decision: tt.decision})
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("BeginReview() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            got, _ := store.Get("ws", submission.ID)
+            if got.Status != tt.wantStatus {
+                t.Errorf("Status = %s, want %s", got.Status, tt.wantStatus)
+            }
+        })
+    }
+}
+
+func TestSubmissionFinish(t *testing.T) {
+    tests := []struct {
+        name       string
+        bvid       string
+        err        error
+        wantStatus string
+        wantError  string
+    }{
+        {name: "提交成功", bvid: "BV1xx", wantStatus: SubmissionPublished},
+        {name: "提交失败", err: errors.New("投稿失败"), wantStatus: SubmissionFailed, wantError: "投稿失败"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store := NewSubmissionStore("")
+            submission := newTestSubmission(t, store)
+            if _, err := store.BeginReview("ws", submission.ID, Review{UserID: "p", Decision: ReviewApprove}); err != nil {
+                t.Fatal(err)
+            }
+
+            got, err := store.Finish(submission.ID, tt.bvid, tt.err)
+            if err != nil {
+                t.Fatalf("Finish() error = %v", err)
+            }
+            if got.Status != tt.wantStatus || got.Error != tt.wantError || got.BVID != tt.bvid {
+                t.Errorf("Finish() = %s/%q/%q, want %s/%q/%q", got.Status, got.Error, got.BVID, tt.wantStatus, tt.wantError, tt.bvid)
+            }
+            if !got.PublishStartedAt.IsZero() {
+                t.Errorf("PublishStartedAt = %v, want zero", got.PublishStartedAt)
+            }
+        })
+    }
+}
+
+func TestSubmissionUpdateParams(t *testing.T) {
+    store := NewSubmissionStore("")
+    submission := newTestSubmission(t, store)
+
+    if _, err := store.UpdateParams("other", submission.ID, VideoUploadParams{Title: "x"}); err == nil {
+        t.Error("其他工作区修改稿件 error = nil, want error")
+    }
+    updated, err := store.UpdateParams("ws", submission.ID, VideoUploadParams{Title: "新标题"})
+    if err != nil || updated.Params.Title != "新标题" {
+        t.Fatalf("UpdateParams() = %v, %v", updated, err)
+    }
+
+    store.BeginReview("ws", submission.ID, Review{UserID: "p", Decision: ReviewApprove})
+    if _, err := store.UpdateParams("ws", submission.ID, VideoUploadParams{Title: "x"}); err == nil {
+        t.Error("提交中修改稿件 error = nil, want error")
+    }
+}
+
+func TestSubmissionRecoversInterruptedPublish(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "submissions.json")
+    store := NewSubmissionStore(path)
+    submission := newTestSubmission(t, store)
+    if _, err := store.BeginReview("ws", submission.ID, Review{UserID: "p", Decision: ReviewApprove}); err != nil {
+        t.Fatal(err)
+    }
+
+    // 模拟服务在提交稿件期间崩溃后重启
+    reloaded := NewSubmissionStore(path)
+    got, ok := reloaded.Get("ws", submission.ID)
+    if !ok {
+        t.Fatal("重新加载后找不到稿件")
+    }
+    if got.Status != SubmissionFailed || got.Error != publishInterruptedError {
+        t.Fatalf("重新加载后 = %s/%q, want %s", got.Status, got.Error, SubmissionFailed)
+    }
+    if _, err := reloaded.BeginReview("ws", submission.ID, Review{UserID: "p", Decision: ReviewApprove}); err != nil {
+        t.Errorf("中断后再次审核 error = %v", err)
+    }
+
+    // 恢复结果已写回文件
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var list []*Submission
+    if err := json.Unmarshal(data, &list); err != nil || len(list) != 1 {
+        t.Fatalf("文件内容 = %s, error = %v", data, err)
+    }
+}
+
+func TestSubmissionRecoversStalePublish(t *testing.T) {
+    tests := []struct {
+        name       string
+        startedAgo time.Duration
+        wantStatus string
+    }{
+        {name: "未超时仍在提交", startedAgo: time.Minute, wantStatus: SubmissionPublishing},
+        {name: "超时改为失败", startedAgo: SubmissionPublishTimeout + time.Minute, wantStatus: SubmissionFailed},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store := NewSubmissionStore("")
+            submission := newTestSubmission(t, store)
+            store.BeginReview("ws", submission.ID, Review{UserID: "p", Decision: ReviewApprove})
+            store.submissions[submission.ID].PublishStartedAt = time.Now().Add(-tt.startedAgo)
+
+            list := store.List("ws", "")
+            if len(list) != 1 || list[0].Status != tt.wantStatus {
+                t.Fatalf("List() = %v, want status %s", list, tt.wantStatus)
+            }
+            got, _ := store.Get("ws", submission.ID)
+            if got.Status != tt.wantStatus {
+                t.Errorf("Get() status = %s, want %s", got.Status, tt.wantStatus)
+            }
+        })
+    }
+}
+
+func TestRoleAllows(t *testing.T) {
+    tests := []struct {
+        role     string
+        required string
+        want     bool
+    }{
+        {RolePublisher, RolePublisher, true},
+        {RolePublisher, RoleEditor, true},
+        {RoleEditor, RoleEditor, true},
+        {RoleEditor, RolePublisher, false},
+        {RoleViewer, RoleEditor, false},
+        {RoleViewer, RoleViewer, true},
+        {"", RoleViewer, false},
+        {"owner", RoleViewer, false},
+    }
+
+    for _, tt := range tests {
+        if got := RoleAllows(tt.role, tt.required); got != tt.want {
+            t.Errorf("RoleAllows(%q, %q) = %v, want %v", tt.role, tt.required, got, tt.want)
+        }
+    }
+}
+
+func TestWorkspaceKeepsOnePublisher(t *testing.T) {
+    store := NewWorkspaceStore("")
+    workspace, err := store.Create("团队", "alice")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := store.SetMember(workspace.ID, "alice", RoleEditor); err == nil {
+        t.Error("唯一的发布者降级 error = nil, want error")
+    }
+    if err := store.RemoveMember(workspace.ID, "alice"); err == nil {
+        t.Error("移除唯一的发布者 error = nil, want error")
+    }
+    if err := store.SetMember(workspace.ID, "bob", "owner"); err == nil {
+        t.Error("无效角色 error = nil, want error")
+    }
+
+    if err := store.SetMember(workspace.ID, "bob", RolePublisher); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.SetMember(workspace.ID, "alice", RoleViewer); err != nil {
+        t.Errorf("有其他发布者时降级 error = %v", err)
+    }
+    got, _ := store.Get(workspace.ID)
+    if got.RoleOf("alice") != RoleViewer || got.RoleOf("bob") != RolePublisher {
+        t.Errorf("角色 = alice:%s bob:%s", got.RoleOf("alice"), got.RoleOf("bob"))
+    }
+}
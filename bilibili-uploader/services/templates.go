@@ -0,0 +1,163 @@
+// services/templates.go - 投稿模板
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// UploadTemplate 用户保存的投稿模板
+type UploadTemplate struct {
+    ID          string    `json:"id"`
+    UserID      string    `json:"user_id"`
+    Name        string    `json:"name"`
+    Description string    `json:"desc,omitempty"` // {title} 替换为标题
+    Tags        []string  `json:"tags,omitempty"`
+    Category    int       `json:"tid,omitempty"`
+    Copyright   int       `json:"copyright,omitempty"`
+    Source      string    `json:"source,omitempty"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// Apply 为未填写的投稿参数套用模板
+func (t *UploadTemplate) Apply(params *VideoUploadParams) {
+    if params.Description == "" && t.Description != "" {
+        params.Description = strings.ReplaceAll(t.Description, "{title}", params.Title)
+    }
+    if len(params.Tags) == 0 {
+        params.Tags = append([]string(nil), t.Tags...)
+    }
+    if params.Category == 0 {
+        params.Category = t.Category
+    }
+    if params.Copyright == 0 {
+        params.Copyright = t.Copyright
+    }
+    if params.Source == "" {
+        params.Source = t.Source
+    }
+}
+
+// TemplateStore 投稿模板存储，持久化到JSON文件
+type TemplateStore struct {
+    mu        sync.Mutex
+    path      string
+    templates map[string]*UploadTemplate
+}
+
+// NewTemplateStore 创建模板存储，path为空时只保存在内存中
+func NewTemplateStore(path string) *TemplateStore {
+    store := &TemplateStore{
+        path:      path,
+        templates: make(map[string]*UploadTemplate),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*UploadTemplate
+            if err := json.Unmarshal(data, &list); err == nil {
+                for _, template := range list {
+                    store.templates[template.ID] = template
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Save 新建或更新模板（ID为空时新建）
+func (s *TemplateStore) Save(template *UploadTemplate) (*UploadTemplate, error) {
+    if strings.TrimSpace(template.Name) == "" {
+        return nil, fmt.Errorf("模板名称不能为空")
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    saved := *template
+    if saved.ID == "" {
+        id, err := RandomID(8)
+        if err != nil {
+            return nil, err
+        }
+        saved.ID = id
+        saved.CreatedAt = time.Now()
+    } else {
+        existing, ok := s.templates[saved.ID]
+        if !ok || existing.UserID != saved.UserID {
+            return nil, fmt.Errorf("模板不存在")
+        }
+        saved.CreatedAt = existing.CreatedAt
+    }
+    s.templates[saved.ID] = &saved
+
+    copied := saved
+    return &copied, s.persist()
+}
+
+// Get 获取用户的模板
+func (s *TemplateStore) Get(userID, id string) (*UploadTemplate, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    template, ok := s.templates[id]
+    if !ok || template.UserID != userID {
+        return nil, false
+    }
+    copied := *template
+    return &copied, true
+}
+
+// List 列出用户的模板，按名称排序
+func (s *TemplateStore) List(userID string) []UploadTemplate {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]UploadTemplate, 0)
+    for _, template := range s.templates {
+        if template.UserID == userID {
+            list = append(list, *template)
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Name < list[j].Name
+    })
+    return list
+}
+
+// Delete 删除用户的模板
+func (s *TemplateStore) Delete(userID, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    template, ok := s.templates[id]
+    if !ok || template.UserID != userID {
+        return fmt.Errorf("模板不存在")
+    }
+    delete(s.templates, id)
+    return s.persist()
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *TemplateStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*UploadTemplate, 0, len(s.templates))
+    for _, template := range s.templates {
+        list = append(list, template)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
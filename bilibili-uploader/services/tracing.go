@@ -0,0 +1,48 @@
+// services/tracing.go - 链路追踪：上传各阶段、FFmpeg和后台任务的span
+package services
+
+import (
+    "context"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// tracer 服务层的Tracer，全局TracerProvider在启动时设置，之前获取的Tracer也会生效
+var tracer = otel.Tracer("bilibili-uploader/services")
+
+// startSpan 在ctx下创建内部span
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+    return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束span，err不为nil时记录错误
+func endSpan(span trace.Span, err error) {
+    if err != nil {
+        recordSpanError(span, err)
+    }
+    span.End()
+}
+
+// recordSpanError 记录错误并把span标记为失败；B站错误额外记录业务码和分类
+func recordSpanError(span trace.Span, err error) {
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+    if biliErr, ok := AsBilibiliError(err); ok {
+        span.SetAttributes(
+            attribute.Int("bilibili.code", biliErr.Code),
+            attribute.String("bilibili.endpoint", biliErr.Endpoint),
+            attribute.String("bilibili.error_class", string(biliErr.Class())),
+        )
+    }
+}
+
+// spanTraceID span的trace ID，未启用链路追踪时返回空字符串
+func spanTraceID(span trace.Span) string {
+    if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+        return spanContext.TraceID().String()
+    }
+    return ""
+}
@@ -0,0 +1,327 @@
+// services/users.go - 本服务的用户账号
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "sync"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength 密码最小长度
+const MinPasswordLength = 8
+
+// usernamePattern 用户名只允许字母、数字、下划线、点和短横线
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// dummyPasswordHash 用户不存在时也做一次bcrypt比较，避免通过耗时判断用户名是否存在
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("bilibili-uploader"), bcrypt.DefaultCost)
+
+// User 本服务的用户，B站账号关联到用户上
+type User struct {
+    ID           string    `json:"id"`
+    Username     string    `json:"username"`
+    DisplayName  string    `json:"display_name,omitempty"`
+    PasswordHash string    `json:"password_hash,omitempty"` // bcrypt，为空时不能用密码登录
+    OIDCIssuer   string    `json:"oidc_issuer,omitempty"`
+    OIDCSubject  string    `json:"oidc_subject,omitempty"`
+    BilibiliUID  int64     `json:"bilibili_uid,omitempty"` // 通过B站登录创建的用户，B站登录只映射回该用户
+    Email        string    `json:"email,omitempty"`
+    CreatedAt    time.Time `json:"created_at"`
+    LastLoginAt  time.Time `json:"last_login_at,omitempty"`
+}
+
+// Name 显示名称
+func (u *User) Name() string {
+    if u.DisplayName != "" {
+        return u.DisplayName
+    }
+    return u.Username
+}
+
+// UserStore 用户存储，持久化到JSON文件
+type UserStore struct {
+    mu    sync.Mutex
+    path  string
+    users map[string]*User
+}
+
+// NewUserStore 创建用户存储，path为空时只保存在内存中
+func NewUserStore(path string) *UserStore {
+    store := &UserStore{
+        path:  path,
+        users: make(map[string]*User),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*User
+            if err := json.Unmarshal(data, &list); err == nil {
+                for _, user := range list {
+                    store.users[user.ID] = user
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Register 注册密码登录的用户
+func (s *UserStore) Register(username, password string) (*User, error) {
+    if !usernamePattern.MatchString(username) {
+        return nil, fmt.Errorf("用户名需为3-32位字母、数字、下划线、点或短横线")
+    }
+    if len(password) < MinPasswordLength {
+        return nil, fmt.Errorf("密码至少%d位", MinPasswordLength)
+    }
+
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return nil, fmt.Errorf("生成密码哈希失败: %v", err)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.findByUsername(username) != nil {
+        return nil, fmt.Errorf("用户名已存在")
+    }
+    return s.create(&User{
+        Username:     username,
+        PasswordHash: string(hash),
+    })
+}
+
+// Authenticate 校验用户名和密码
+func (s *UserStore) Authenticate(username, password string) (*User, error) {
+    s.mu.Lock()
+    user := s.findByUsername(username)
+    hash := dummyPasswordHash
+    if user != nil && user.PasswordHash != "" {
+        hash = []byte(user.PasswordHash)
+    }
+    s.mu.Unlock()
+
+    // bcrypt比较较慢，不持有锁
+    if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || user == nil || user.PasswordHash == "" {
+        return nil, fmt.Errorf("用户名或密码错误")
+    }
+    return s.touch(user.ID)
+}
+
+// LoginOIDC 按issuer和subject查找OIDC用户，不存在时创建
+func (s *UserStore) LoginOIDC(issuer, subject, username, email string) (*User, error) {
+    s.mu.Lock()
+    var found *User
+    for _, user := range s.users {
+        if user.OIDCIssuer == issuer && user.OIDCSubject == subject {
+            found = user
+            break
+        }
+    }
+    if found == nil {
+        created, err := s.create(&User{
+            Username:    s.uniqueUsername(username, "oidc"),
+            DisplayName: username,
+            OIDCIssuer:  issuer,
+            OIDCSubject: subject,
+            Email:       email,
+        })
+        if err != nil {
+            s.mu.Unlock()
+            return nil, err
+        }
+        found = created
+    } else if email != "" && found.Email != email {
+        found.Email = email
+    }
+    s.mu.Unlock()
+
+    return s.touch(found.ID)
+}
+
+// LoginBilibili 按B站UID查找通过B站登录创建的用户，不存在时创建（没有密码，只能通过该B站账号登录）
+// 只匹配以该UID为登录身份的用户，不会映射到仅关联了该账号的其他用户（共用的频道账号）
+func (s *UserStore) LoginBilibili(uid int64, displayName string) (*User, error) {
+    s.mu.Lock()
+    legacyName := fmt.Sprintf("bilibili_%d", uid)
+    var found *User
+    for _, user := range s.users {
+        if user.BilibiliUID == uid {
+            found = user
+            break
+        }
+        // 旧版本创建的用户没有记录bilibili_uid，按自动生成的用户名识别
+        if user.BilibiliUID == 0 && user.PasswordHash == "" && user.OIDCSubject == "" && user.Username == legacyName {
+            found = user
+        }
+    }
+    if found == nil {
+        created, err := s.create(&User{
+            Username:    s.uniqueUsername(legacyName, "bilibili"),
+            DisplayName: displayName,
+            BilibiliUID: uid,
+        })
+        if err != nil {
+            s.mu.Unlock()
+            return nil, err
+        }
+        found = created
+    } else {
+        found.BilibiliUID = uid
+    }
+    s.mu.Unlock()
+
+    return s.touch(found.ID)
+}
+
+// Get 按ID获取用户
+func (s *UserStore) Get(id string) (*User, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return nil, false
+    }
+    copied := *user
+    return &copied, true
+}
+
+// Lookup 按用户名获取用户
+func (s *UserStore) Lookup(username string) (*User, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    user := s.findByUsername(username)
+    if user == nil {
+        return nil, false
+    }
+    copied := *user
+    return &copied, true
+}
+
+// VerifyPassword 校验用户的当前密码，用户还没有设置密码时直接通过
+func (s *UserStore) VerifyPassword(id, password string) error {
+    s.mu.Lock()
+    user, ok := s.users[id]
+    hash := ""
+    if ok {
+        hash = user.PasswordHash
+    }
+    s.mu.Unlock()
+
+    if !ok {
+        return fmt.Errorf("用户不存在")
+    }
+    if hash == "" {
+        return nil
+    }
+    // bcrypt比较较慢，不持有锁
+    if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+        return fmt.Errorf("当前密码错误")
+    }
+    return nil
+}
+
+// SetPassword 设置或修改密码
+func (s *UserStore) SetPassword(id, password string) error {
+    if len(password) < MinPasswordLength {
+        return fmt.Errorf("密码至少%d位", MinPasswordLength)
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return fmt.Errorf("生成密码哈希失败: %v", err)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return fmt.Errorf("用户不存在")
+    }
+    user.PasswordHash = string(hash)
+    return s.persist()
+}
+
+// touch 记录登录时间并返回副本
+func (s *UserStore) touch(id string) (*User, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return nil, fmt.Errorf("用户不存在")
+    }
+    user.LastLoginAt = time.Now()
+    if err := s.persist(); err != nil {
+        return nil, err
+    }
+    copied := *user
+    return &copied, nil
+}
+
+// create 保存新用户（调用方需持有锁）
+func (s *UserStore) create(user *User) (*User, error) {
+    id, err := RandomID(8)
+    if err != nil {
+        return nil, err
+    }
+    user.ID = "u_" + id
+    user.CreatedAt = time.Now()
+    s.users[user.ID] = user
+    if err := s.persist(); err != nil {
+        delete(s.users, user.ID)
+        return nil, err
+    }
+    copied := *user
+    return &copied, nil
+}
+
+// findByUsername 按用户名查找（不区分大小写，调用方需持有锁）
+func (s *UserStore) findByUsername(username string) *User {
+    for _, user := range s.users {
+        if strings.EqualFold(user.Username, username) {
+            return user
+        }
+    }
+    return nil
+}
+
+// uniqueUsername 生成不重复的用户名（调用方需持有锁）
+func (s *UserStore) uniqueUsername(preferred, fallback string) string {
+    base := preferred
+    if !usernamePattern.MatchString(base) {
+        base = fallback
+    }
+    name := base
+    for i := 2; s.findByUsername(name) != nil; i++ {
+        name = fmt.Sprintf("%s_%d", base, i)
+    }
+    return name
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *UserStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*User, 0, len(s.users))
+    for _, user := range s.users {
+        list = append(list, user)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
@@ -2,14 +2,21 @@
 package services
 
 import (
+    "context"
     "fmt"
-    "log"
+    "log/slog"
     "os"
     "os/exec"
     "path/filepath"
     "strings"
+    "time"
+    
+    "go.opentelemetry.io/otel/attribute"
 )
 
+// ffmpegStopTimeout ctx取消后等待FFmpeg收尾退出的时间，超时后强制结束
+const ffmpegStopTimeout = 5 * time.Second
+
 // VideoProcessor 视频处理器
 type VideoProcessor struct {
     InputDir  string
@@ -26,6 +33,19 @@ func NewVideoProcessor(inputDir, outputDir string) *VideoProcessor {
 
 // ProcessVideo 处理视频文件
 func (vp *VideoProcessor) ProcessVideo(filename string, options ProcessOptions) (string, error) {
+    return vp.ProcessVideoContext(context.Background(), filename, options)
+}
+
+// ProcessVideoContext 处理视频文件，ctx中的关联字段（request_id、job_id等）会写入日志，FFmpeg耗时记录为span
+// ctx取消时先向FFmpeg发送中断信号，ffmpegStopTimeout后仍未退出则强制结束，并删除不完整的输出文件
+func (vp *VideoProcessor) ProcessVideoContext(ctx context.Context, filename string, options ProcessOptions) (output string, err error) {
+    ctx, span := startSpan(ctx, "ffmpeg.process",
+        attribute.String("file.name", filename),
+        attribute.String("ffmpeg.quality", options.Quality),
+        attribute.String("ffmpeg.resolution", options.Resolution),
+    )
+    defer func() { endSpan(span, err) }()
+    
     inputPath := filepath.Join(vp.InputDir, filename)
     outputFilename := fmt.Sprintf("bilibili_%s", filename)
     outputPath := filepath.Join(vp.OutputDir, outputFilename)
@@ -39,15 +59,29 @@ func (vp *VideoProcessor) ProcessVideo(filename string, options ProcessOptions)
     args := vp.buildFFmpegArgs(inputPath, outputPath, options)
     
     // 执行FFmpeg命令
-    cmd := exec.Command("ffmpeg", args...)
+    cmd := exec.CommandContext(ctx, "ffmpeg", args...)
     cmd.Stdout = os.Stdout
     cmd.Stderr = os.Stderr
+    cmd.Cancel = func() error {
+        return cmd.Process.Signal(os.Interrupt)
+    }
+    cmd.WaitDelay = ffmpegStopTimeout
     
-    log.Printf("执行FFmpeg命令: ffmpeg %s", strings.Join(args, " "))
+    slog.InfoContext(ctx, "执行FFmpeg命令", "args", strings.Join(args, " "))
     
-    if err := cmd.Run(); err != nil {
+    start := time.Now()
+    err = cmd.Run()
+    observeFFmpeg(time.Since(start), err)
+    if err != nil {
+        os.Remove(outputPath)
+        if ctx.Err() != nil {
+            slog.WarnContext(ctx, "FFmpeg处理已中断", "file", filename, "duration_ms", time.Since(start).Milliseconds())
+            return "", fmt.Errorf("FFmpeg处理已中断: %w", ctx.Err())
+        }
+        slog.ErrorContext(ctx, "FFmpeg处理失败", "file", filename, "duration_ms", time.Since(start).Milliseconds(), "error", err)
         return "", fmt.Errorf("FFmpeg处理失败: %v", err)
     }
+    slog.InfoContext(ctx, "FFmpeg处理完成", "file", filename, "output", outputFilename, "duration_ms", time.Since(start).Milliseconds())
     
     return outputFilename, nil
 }
@@ -184,8 +218,7 @@ func getFileSize(filepath string) int64 {
 func CheckFFmpeg() bool {
     cmd := exec.Command("ffmpeg", "-version")
     if err := cmd.Run(); err != nil {
-        log.Println("⚠️ FFmpeg未安装，视频处理功能将不可用")
-        log.Println("💡 请安装FFmpeg: brew install ffmpeg")
+        slog.Warn("FFmpeg未安装，视频处理功能将不可用", "hint", "brew install ffmpeg")
         return false
     }
     return true
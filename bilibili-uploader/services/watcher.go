@@ -0,0 +1,498 @@
+// services/watcher.go - 监控目录自动投稿
+package services
+
+import (
+    "bilibili-uploader/logging"
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+    
+    "go.opentelemetry.io/otel/attribute"
+)
+
+const (
+    // WatchDefaultsFile 目录级默认投稿信息文件
+    WatchDefaultsFile = "_defaults.json"
+    // WatchDoneDir 投稿成功后文件移入的子目录
+    WatchDoneDir = "done"
+    // WatchFailedDir 投稿失败后文件移入的子目录
+    WatchFailedDir = "failed"
+)
+
+// videoExtensions 支持自动投稿的视频格式
+var videoExtensions = map[string]bool{
+    ".mp4":  true,
+    ".flv":  true,
+    ".mov":  true,
+    ".mkv":  true,
+    ".avi":  true,
+    ".wmv":  true,
+    ".webm": true,
+}
+
+// UploadFunc 执行上传的函数，返回BV号；ctx携带任务的span和日志关联字段
+type UploadFunc func(ctx context.Context, videoPath string, params VideoUploadParams) (string, error)
+
+// WatchMetadata 投稿信息（旁路文件 <视频名>.json 或目录级 _defaults.json）
+type WatchMetadata struct {
+    Title       string   `json:"title"`
+    Description string   `json:"desc"`
+    Tags        []string `json:"tags"`
+    Category    int      `json:"tid"`
+    Cover       string   `json:"cover"`
+    Source      string   `json:"source"`
+    Copyright   int      `json:"copyright"`
+    Quality     string   `json:"quality"` // 非空时先用FFmpeg转码
+    Resolution  string   `json:"resolution"`
+}
+
+// merge 用other中的非空字段覆盖当前信息
+func (m WatchMetadata) merge(other WatchMetadata) WatchMetadata {
+    if other.Title != "" {
+        m.Title = other.Title
+    }
+    if other.Description != "" {
+        m.Description = other.Description
+    }
+    if len(other.Tags) > 0 {
+        m.Tags = other.Tags
+    }
+    if other.Category != 0 {
+        m.Category = other.Category
+    }
+    if other.Cover != "" {
+        m.Cover = other.Cover
+    }
+    if other.Source != "" {
+        m.Source = other.Source
+    }
+    if other.Copyright != 0 {
+        m.Copyright = other.Copyright
+    }
+    if other.Quality != "" {
+        m.Quality = other.Quality
+    }
+    if other.Resolution != "" {
+        m.Resolution = other.Resolution
+    }
+    return m
+}
+
+// uploadParams 转换为上传参数
+func (m WatchMetadata) uploadParams() VideoUploadParams {
+    return VideoUploadParams{
+        Title:       m.Title,
+        Description: m.Description,
+        Tags:        m.Tags,
+        Category:    m.Category,
+        Cover:       m.Cover,
+        Source:      m.Source,
+        Copyright:   m.Copyright,
+    }
+}
+
+// WatchReport 失败报告，写入 failed/<视频名>.error.json
+type WatchReport struct {
+    File     string            `json:"file"`
+    JobID    string            `json:"job_id"`
+    Stage    string            `json:"stage"`
+    Error    string            `json:"error"`
+    Kind     ErrorClass        `json:"kind,omitempty"` // B站错误的分类，为空表示非B站错误
+    Params   VideoUploadParams `json:"params"`
+    FailedAt time.Time         `json:"failed_at"`
+}
+
+// fileState 文件大小追踪状态
+type fileState struct {
+    size     int64
+    modTime  time.Time
+    stableAt time.Time // 最近一次大小变化的时间
+}
+
+// FolderWatcher 监控目录，新视频写入完成后自动处理并投稿
+type FolderWatcher struct {
+    Dirs         []string
+    Interval     time.Duration // 扫描间隔
+    SettleTime   time.Duration // 文件大小保持不变多久视为写入完成
+    ProcessedDir string
+    Upload       UploadFunc
+    Jobs         *JobStore
+    UserID       string // 任务所属用户，为空表示系统任务
+
+    mu     sync.Mutex
+    seen   map[string]*fileState
+    stop   chan struct{}
+    done   chan struct{}
+    ctx    context.Context // 当前任务的上下文，Interrupt时取消
+    cancel context.CancelFunc
+}
+
+// NewFolderWatcher 创建目录监控器
+func NewFolderWatcher(dirs []string, processedDir string, upload UploadFunc, jobs *JobStore) *FolderWatcher {
+    return &FolderWatcher{
+        Dirs:         dirs,
+        Interval:     5 * time.Second,
+        SettleTime:   30 * time.Second,
+        ProcessedDir: processedDir,
+        Upload:       upload,
+        Jobs:         jobs,
+        seen:         make(map[string]*fileState),
+    }
+}
+
+// Start 启动后台监控
+func (w *FolderWatcher) Start() {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.stop != nil {
+        return
+    }
+    w.stop = make(chan struct{})
+    w.done = make(chan struct{})
+    w.ctx, w.cancel = context.WithCancel(context.Background())
+
+    for _, dir := range w.Dirs {
+        for _, sub := range []string{WatchDoneDir, WatchFailedDir} {
+            if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+                slog.Error("创建目录失败", "dir", filepath.Join(dir, sub), "error", err)
+            }
+        }
+        slog.Info("开始监控目录", "dir", dir)
+    }
+
+    go w.run(w.stop, w.done)
+}
+
+// Stop 停止监控，不再开始处理新文件，等待当前文件处理完成
+// 需要尽快退出时在另一个goroutine中调用Interrupt中断当前文件
+func (w *FolderWatcher) Stop() {
+    w.mu.Lock()
+    stop, done := w.stop, w.done
+    w.stop, w.done = nil, nil
+    w.mu.Unlock()
+
+    if stop == nil {
+        return
+    }
+    close(stop)
+    <-done
+}
+
+// Interrupt 中断正在处理的文件：FFmpeg被终止，上传保存断点，源文件留在监控目录中等待重启后处理
+func (w *FolderWatcher) Interrupt() {
+    w.mu.Lock()
+    cancel := w.cancel
+    w.mu.Unlock()
+
+    if cancel != nil {
+        cancel()
+    }
+}
+
+// jobContext 处理文件使用的上下文
+func (w *FolderWatcher) jobContext() context.Context {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.ctx == nil {
+        return context.Background()
+    }
+    return w.ctx
+}
+
+// run 定时扫描
+func (w *FolderWatcher) run(stop, done chan struct{}) {
+    defer close(done)
+
+    ticker := time.NewTicker(w.Interval)
+    defer ticker.Stop()
+
+    for {
+        w.scan(stop)
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Scan 扫描所有目录一次，处理已写入完成的文件
+func (w *FolderWatcher) Scan() {
+    w.scan(nil)
+}
+
+// scan 扫描所有目录，stop关闭后不再开始处理新文件
+func (w *FolderWatcher) scan(stop chan struct{}) {
+    now := time.Now()
+    present := make(map[string]bool)
+    defer w.forgetMissing(present)
+
+    for _, dir := range w.Dirs {
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+            slog.Error("读取监控目录失败", "dir", dir, "error", err)
+            continue
+        }
+
+        for _, entry := range entries {
+            if entry.IsDir() || !isVideoFile(entry.Name()) {
+                continue
+            }
+            path := filepath.Join(dir, entry.Name())
+            present[path] = true
+            info, err := entry.Info()
+            if err != nil {
+                continue
+            }
+            if !w.isSettled(path, info, now) {
+                continue
+            }
+            select {
+            case <-stop:
+                return
+            default:
+            }
+            w.handleFile(dir, path)
+        }
+    }
+}
+
+// forgetMissing 清除已被删除或移走文件的追踪状态
+func (w *FolderWatcher) forgetMissing(present map[string]bool) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    for path := range w.seen {
+        if !present[path] {
+            delete(w.seen, path)
+        }
+    }
+}
+
+// isSettled 判断文件是否已停止增长
+func (w *FolderWatcher) isSettled(path string, info os.FileInfo, now time.Time) bool {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    state, ok := w.seen[path]
+    if !ok || state.size != info.Size() || !state.modTime.Equal(info.ModTime()) {
+        w.seen[path] = &fileState{
+            size:     info.Size(),
+            modTime:  info.ModTime(),
+            stableAt: now,
+        }
+        return false
+    }
+    return info.Size() > 0 && now.Sub(state.stableAt) >= w.SettleTime
+}
+
+// handleFile 处理并投稿单个文件
+func (w *FolderWatcher) handleFile(dir, path string) {
+    w.mu.Lock()
+    delete(w.seen, path)
+    w.mu.Unlock()
+
+    meta, err := loadWatchMetadata(dir, path)
+    params := meta.uploadParams()
+
+    job := w.Jobs.CreateFor(w.UserID, path, meta.Title)
+    ctx := logging.With(w.jobContext(), "job_id", job.ID, "user_id", w.UserID)
+
+    // 每个任务一条trace，trace_id记录在任务上便于从任务列表查找
+    ctx, span := startSpan(ctx, "watcher.job", attribute.String("job.id", job.ID), attribute.String("file.name", filepath.Base(path)))
+    defer span.End()
+    if traceID := spanTraceID(span); traceID != "" {
+        w.Jobs.Update(job.ID, func(j *Job) {
+            j.TraceID = traceID
+        })
+    }
+    slog.InfoContext(ctx, "发现新视频", "file", path)
+
+    fail := func(stage string, err error) {
+        slog.ErrorContext(ctx, "自动投稿失败", "file", path, "stage", stage, "error", err)
+        recordSpanError(span, err)
+        span.SetAttributes(attribute.String("job.stage", stage))
+        var kind ErrorClass
+        if biliErr, ok := AsBilibiliError(err); ok {
+            kind = biliErr.Class()
+        }
+        w.Jobs.Update(job.ID, func(j *Job) {
+            j.Status = JobStatusFailed
+            j.Stage = stage
+            j.Error = err.Error()
+            j.ErrorKind = string(kind)
+        })
+        report := WatchReport{
+            File:     filepath.Base(path),
+            JobID:    job.ID,
+            Stage:    stage,
+            Error:    err.Error(),
+            Kind:     kind,
+            Params:   params,
+            FailedAt: time.Now(),
+        }
+        if err := moveWithReport(dir, path, WatchFailedDir, &report); err != nil {
+            slog.ErrorContext(ctx, "移动失败文件出错", "file", path, "error", err)
+        }
+    }
+
+    // 服务退出时中断：不移动源文件，重启后重新发现并处理
+    interrupted := func(stage string, err error) bool {
+        if ctx.Err() == nil {
+            return false
+        }
+        slog.WarnContext(ctx, "自动投稿已中断，源文件保留", "file", path, "stage", stage, "error", err)
+        span.SetAttributes(attribute.Bool("job.interrupted", true), attribute.String("job.stage", stage))
+        w.Jobs.Update(job.ID, func(j *Job) {
+            j.Status = JobStatusInterrupted
+            j.Stage = stage
+            j.Error = err.Error()
+        })
+        return true
+    }
+
+    if err != nil {
+        fail("metadata", err)
+        return
+    }
+
+    // 可选：FFmpeg转码
+    uploadPath := path
+    if meta.Quality != "" || meta.Resolution != "" {
+        w.Jobs.Update(job.ID, func(j *Job) {
+            j.Status = JobStatusProcessing
+            j.Stage = "process"
+        })
+        if !CheckFFmpeg() {
+            fail("process", fmt.Errorf("未安装FFmpeg，无法按 quality=%s 处理", meta.Quality))
+            return
+        }
+        processor := NewVideoProcessor(dir, w.ProcessedDir)
+        outputFile, err := processor.ProcessVideoContext(ctx, filepath.Base(path), ProcessOptions{
+            Quality:    meta.Quality,
+            Resolution: meta.Resolution,
+        })
+        if err != nil {
+            if !interrupted("process", err) {
+                fail("process", err)
+            }
+            return
+        }
+        uploadPath = filepath.Join(w.ProcessedDir, outputFile)
+        defer os.Remove(uploadPath)
+    }
+
+    // 上传
+    w.Jobs.Update(job.ID, func(j *Job) {
+        j.Status = JobStatusUploading
+        j.Stage = "upload"
+    })
+    bvid, err := w.Upload(ctx, uploadPath, params)
+    if err != nil {
+        if !interrupted("upload", err) {
+            fail("upload", err)
+        }
+        return
+    }
+
+    w.Jobs.Update(job.ID, func(j *Job) {
+        j.Status = JobStatusDone
+        j.Stage = ""
+        j.BVID = bvid
+    })
+    slog.InfoContext(ctx, "自动投稿成功", "file", path, "bvid", bvid)
+
+    if err := moveWithReport(dir, path, WatchDoneDir, nil); err != nil {
+        slog.ErrorContext(ctx, "移动完成文件出错", "file", path, "error", err)
+    }
+}
+
+// loadWatchMetadata 读取目录默认信息和旁路文件，合并出投稿信息
+func loadWatchMetadata(dir, videoPath string) (WatchMetadata, error) {
+    meta := WatchMetadata{
+        Category:  21, // 默认日常分区
+        Copyright: 1,  // 自制
+    }
+
+    if defaults, err := readMetadataFile(filepath.Join(dir, WatchDefaultsFile)); err != nil {
+        return meta, err
+    } else if defaults != nil {
+        meta = meta.merge(*defaults)
+    }
+
+    if sidecar, err := readMetadataFile(sidecarPath(videoPath)); err != nil {
+        return meta, err
+    } else if sidecar != nil {
+        meta = meta.merge(*sidecar)
+    }
+
+    if meta.Title == "" {
+        base := filepath.Base(videoPath)
+        meta.Title = strings.TrimSuffix(base, filepath.Ext(base))
+    }
+
+    return meta, nil
+}
+
+// readMetadataFile 读取投稿信息文件，文件不存在时返回nil
+func readMetadataFile(path string) (*WatchMetadata, error) {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var meta WatchMetadata
+    if err := json.Unmarshal(data, &meta); err != nil {
+        return nil, fmt.Errorf("解析 %s 失败: %v", filepath.Base(path), err)
+    }
+    return &meta, nil
+}
+
+// moveWithReport 将视频及旁路文件移入子目录，report非空时写入失败报告
+func moveWithReport(dir, videoPath, sub string, report *WatchReport) error {
+    target := filepath.Join(dir, sub)
+    if err := os.MkdirAll(target, 0755); err != nil {
+        return err
+    }
+
+    if err := os.Rename(videoPath, filepath.Join(target, filepath.Base(videoPath))); err != nil {
+        return err
+    }
+
+    sidecar := sidecarPath(videoPath)
+    if _, err := os.Stat(sidecar); err == nil {
+        os.Rename(sidecar, filepath.Join(target, filepath.Base(sidecar)))
+    }
+
+    if report != nil {
+        data, err := json.MarshalIndent(report, "", "  ")
+        if err != nil {
+            return err
+        }
+        reportPath := filepath.Join(target, filepath.Base(videoPath)+".error.json")
+        return os.WriteFile(reportPath, data, 0644)
+    }
+
+    return nil
+}
+
+// sidecarPath 视频对应的旁路信息文件路径
+func sidecarPath(videoPath string) string {
+    return strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".json"
+}
+
+// isVideoFile 判断是否为视频文件
+func isVideoFile(name string) bool {
+    return videoExtensions[strings.ToLower(filepath.Ext(name))]
+}
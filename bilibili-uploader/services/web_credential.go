@@ -0,0 +1,215 @@
+// services/web_credential.go - B站网页Cookie凭证
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// CredentialStoreFile 数据目录中网页凭证的文件名
+const CredentialStoreFile = "web_credentials.json"
+
+// WebCredential 网页登录Cookie凭证（与Python端 bilibili_api.Credential 对应）
+type WebCredential struct {
+    SESSDATA     string    `json:"SESSDATA"`
+    BiliJct      string    `json:"bili_jct"` // 同时作为csrf参数
+    DedeUserID   string    `json:"DedeUserID"`
+    Buvid3       string    `json:"buvid3,omitempty"`
+    RefreshToken string    `json:"refresh_token,omitempty"` // ac_time_value，用于刷新Cookie
+    Username     string    `json:"username,omitempty"`
+    CheckedAt    time.Time `json:"checked_at,omitempty"` // 最近一次nav校验时间
+}
+
+// NewWebCredential 从Cookie键值创建凭证
+func NewWebCredential(cookies map[string]string) *WebCredential {
+    return &WebCredential{
+        SESSDATA:   cookies["SESSDATA"],
+        BiliJct:    cookies["bili_jct"],
+        DedeUserID: cookies["DedeUserID"],
+        Buvid3:     cookies["buvid3"],
+    }
+}
+
+// ParseCookieString 解析浏览器中复制的Cookie字符串，如 "SESSDATA=xxx; bili_jct=yyy"
+func ParseCookieString(raw string) (*WebCredential, error) {
+    cookies := make(map[string]string)
+    for _, pair := range strings.Split(raw, ";") {
+        name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+        if !ok {
+            continue
+        }
+        cookies[strings.TrimSpace(name)] = strings.TrimSpace(value)
+    }
+
+    cred := NewWebCredential(cookies)
+    if err := cred.Validate(); err != nil {
+        return nil, err
+    }
+    return cred, nil
+}
+
+// Validate 检查必要字段是否齐全
+func (c *WebCredential) Validate() error {
+    var missing []string
+    if c.SESSDATA == "" {
+        missing = append(missing, "SESSDATA")
+    }
+    if c.BiliJct == "" {
+        missing = append(missing, "bili_jct")
+    }
+    if c.DedeUserID == "" {
+        missing = append(missing, "DedeUserID")
+    }
+    if len(missing) > 0 {
+        return fmt.Errorf("Cookie缺少字段: %s", strings.Join(missing, ", "))
+    }
+    return nil
+}
+
+// UID 用户ID
+func (c *WebCredential) UID() int64 {
+    uid, _ := strconv.ParseInt(c.DedeUserID, 10, 64)
+    return uid
+}
+
+// CSRF csrf参数值
+func (c *WebCredential) CSRF() string {
+    return c.BiliJct
+}
+
+// CookieHeader 生成Cookie请求头
+func (c *WebCredential) CookieHeader() string {
+    parts := []string{
+        "SESSDATA=" + c.SESSDATA,
+        "bili_jct=" + c.BiliJct,
+        "DedeUserID=" + c.DedeUserID,
+    }
+    if c.Buvid3 != "" {
+        parts = append(parts, "buvid3="+c.Buvid3)
+    }
+    return strings.Join(parts, "; ")
+}
+
+// CheckWebCredential 调用nav接口校验Cookie是否有效，返回对应用户信息
+func CheckWebCredential(ctx context.Context, apiBaseURL string, cred *WebCredential) (*UserInfo, error) {
+    oauth := &BilibiliOAuth{BaseURL: apiBaseURL}
+    userInfo, err := oauth.FetchUserInfo(ctx, &CookieAuth{Credential: cred})
+    if err != nil {
+        return nil, fmt.Errorf("Cookie已失效: %w", err)
+    }
+    if userInfo.UID != cred.UID() {
+        return nil, fmt.Errorf("Cookie中的DedeUserID(%s)与登录账号(%d)不一致", cred.DedeUserID, userInfo.UID)
+    }
+
+    cred.Username = userInfo.Username
+    cred.CheckedAt = time.Now()
+
+    return userInfo, nil
+}
+
+// LogoutWebCredential 调用 /login/exit/v2 使Cookie在B站失效
+func LogoutWebCredential(ctx context.Context, passportURL string, cred *WebCredential) error {
+    form := url.Values{}
+    form.Set("biliCSRF", cred.CSRF())
+
+    req, err := http.NewRequestWithContext(ctx, "POST", passportURL+"/login/exit/v2", strings.NewReader(form.Encode()))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Cookie", cred.CookieHeader())
+
+    client := DefaultHTTPClient()
+    resp, err := client.Do(req)
+    if err != nil {
+        return fmt.Errorf("退出登录失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    if err := decodeAPIResponse("logout", resp, nil); err != nil {
+        return fmt.Errorf("退出登录失败: %w", err)
+    }
+    return nil
+}
+
+// CredentialStore 网页凭证存储（按UID），持久化到JSON文件
+type CredentialStore struct {
+    mu      sync.Mutex
+    path    string
+    keyring *Keyring
+    creds   map[int64]*WebCredential
+}
+
+// NewCredentialStore 创建凭证存储，path为空时只保存在内存中；keyring为空时只能读取明文记录，保存时返回ErrNoCredentialKey
+// 存在无法解密的记录（如缺少对应版本的密钥）时返回错误
+func NewCredentialStore(path string, keyring *Keyring) (*CredentialStore, error) {
+    store := &CredentialStore{
+        path:    path,
+        keyring: keyring,
+        creds:   make(map[int64]*WebCredential),
+    }
+
+    records, err := loadSealedFile(path, keyring)
+    if err != nil {
+        return nil, fmt.Errorf("加载网页凭证失败: %v", err)
+    }
+    for id, data := range records {
+        var cred WebCredential
+        if err := json.Unmarshal(data, &cred); err != nil {
+            return nil, fmt.Errorf("解析UID %s 的网页凭证失败: %v", id, err)
+        }
+        store.creds[cred.UID()] = &cred
+    }
+    return store, nil
+}
+
+// Save 保存凭证
+func (s *CredentialStore) Save(cred *WebCredential) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    copied := *cred
+    s.creds[cred.UID()] = &copied
+    return s.persist(cred.UID())
+}
+
+// Get 按UID获取凭证
+func (s *CredentialStore) Get(uid int64) (*WebCredential, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    cred, ok := s.creds[uid]
+    if !ok {
+        return nil, false
+    }
+    copied := *cred
+    return &copied, true
+}
+
+// Delete 删除凭证
+func (s *CredentialStore) Delete(uid int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    delete(s.creds, uid)
+    return s.persist(uid)
+}
+
+// persist 把UID对应的一条记录写入文件，内存中已删除时从文件中删除（调用方需持有锁）
+func (s *CredentialStore) persist(uid int64) error {
+    var data []byte
+    if cred, ok := s.creds[uid]; ok {
+        var err error
+        if data, err = json.Marshal(cred); err != nil {
+            return err
+        }
+    }
+    return updateSealedFile(s.path, s.keyring, strconv.FormatInt(uid, 10), data)
+}
@@ -0,0 +1,294 @@
+// services/workspaces.go - 团队工作区和成员角色
+package services
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 工作区角色，权限依次递增
+const (
+    RoleViewer    = "viewer"    // 查看稿件和审核记录
+    RoleEditor    = "editor"    // 上传视频、准备稿件并提交审核
+    RolePublisher = "publisher" // 审核通过后提交稿件，管理成员和账号
+)
+
+// roleRank 角色权限等级
+var roleRank = map[string]int{
+    RoleViewer:    1,
+    RoleEditor:    2,
+    RolePublisher: 3,
+}
+
+// ValidRole 是否为有效角色
+func ValidRole(role string) bool {
+    _, ok := roleRank[role]
+    return ok
+}
+
+// RoleAllows 角色是否具备required角色的权限
+func RoleAllows(role, required string) bool {
+    return ValidRole(role) && roleRank[role] >= roleRank[required]
+}
+
+// WorkspaceMember 工作区成员
+type WorkspaceMember struct {
+    UserID  string    `json:"user_id"`
+    Role    string    `json:"role"`
+    AddedAt time.Time `json:"added_at"`
+}
+
+// Workspace 工作区，把用户和关联的B站账号组织在一起
+type Workspace struct {
+    ID         string            `json:"id"`
+    Name       string            `json:"name"`
+    Members    []WorkspaceMember `json:"members"`
+    AccountIDs []string          `json:"account_ids"` // 加入工作区的关联账号
+    CreatedBy  string            `json:"created_by"`
+    CreatedAt  time.Time         `json:"created_at"`
+}
+
+// RoleOf 用户在工作区中的角色，不是成员时返回空
+func (w *Workspace) RoleOf(userID string) string {
+    for _, member := range w.Members {
+        if member.UserID == userID {
+            return member.Role
+        }
+    }
+    return ""
+}
+
+// HasAccount 账号是否已加入工作区
+func (w *Workspace) HasAccount(accountID string) bool {
+    for _, id := range w.AccountIDs {
+        if id == accountID {
+            return true
+        }
+    }
+    return false
+}
+
+// publishers 发布者人数
+func (w *Workspace) publishers() int {
+    count := 0
+    for _, member := range w.Members {
+        if member.Role == RolePublisher {
+            count++
+        }
+    }
+    return count
+}
+
+// copy 深拷贝
+func (w *Workspace) copy() *Workspace {
+    copied := *w
+    copied.Members = append([]WorkspaceMember(nil), w.Members...)
+    copied.AccountIDs = append([]string(nil), w.AccountIDs...)
+    return &copied
+}
+
+// WorkspaceStore 工作区存储，持久化到JSON文件
+type WorkspaceStore struct {
+    mu         sync.Mutex
+    path       string
+    workspaces map[string]*Workspace
+}
+
+// NewWorkspaceStore 创建工作区存储，path为空时只保存在内存中
+func NewWorkspaceStore(path string) *WorkspaceStore {
+    store := &WorkspaceStore{
+        path:       path,
+        workspaces: make(map[string]*Workspace),
+    }
+    if path != "" {
+        if data, err := os.ReadFile(path); err == nil {
+            var list []*Workspace
+            if err := json.Unmarshal(data, &list); err == nil {
+                for _, workspace := range list {
+                    store.workspaces[workspace.ID] = workspace
+                }
+            }
+        }
+    }
+    return store
+}
+
+// Create 创建工作区，创建者成为发布者
+func (s *WorkspaceStore) Create(name, creatorID string) (*Workspace, error) {
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return nil, fmt.Errorf("工作区名称不能为空")
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    id, err := RandomID(8)
+    if err != nil {
+        return nil, err
+    }
+    now := time.Now()
+    workspace := &Workspace{
+        ID:         id,
+        Name:       name,
+        Members:    []WorkspaceMember{{UserID: creatorID, Role: RolePublisher, AddedAt: now}},
+        AccountIDs: []string{},
+        CreatedBy:  creatorID,
+        CreatedAt:  now,
+    }
+    s.workspaces[id] = workspace
+    return workspace.copy(), s.persist()
+}
+
+// Get 获取工作区
+func (s *WorkspaceStore) Get(id string) (*Workspace, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    workspace, ok := s.workspaces[id]
+    if !ok {
+        return nil, false
+    }
+    return workspace.copy(), true
+}
+
+// ListForUser 列出用户所在的工作区，按创建时间排序
+func (s *WorkspaceStore) ListForUser(userID string) []*Workspace {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]*Workspace, 0)
+    for _, workspace := range s.workspaces {
+        if workspace.RoleOf(userID) != "" {
+            list = append(list, workspace.copy())
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].CreatedAt.Before(list[j].CreatedAt)
+    })
+    return list
+}
+
+// WithAccount 列出包含该关联账号的工作区
+func (s *WorkspaceStore) WithAccount(accountID string) []*Workspace {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    list := make([]*Workspace, 0)
+    for _, workspace := range s.workspaces {
+        if workspace.HasAccount(accountID) {
+            list = append(list, workspace.copy())
+        }
+    }
+    return list
+}
+
+// SetMember 添加成员或修改角色
+func (s *WorkspaceStore) SetMember(id, userID, role string) error {
+    if !ValidRole(role) {
+        return fmt.Errorf("无效的角色: %s", role)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    workspace, ok := s.workspaces[id]
+    if !ok {
+        return fmt.Errorf("工作区不存在")
+    }
+    for i, member := range workspace.Members {
+        if member.UserID == userID {
+            if member.Role == RolePublisher && role != RolePublisher && workspace.publishers() == 1 {
+                return fmt.Errorf("工作区至少需要一名发布者")
+            }
+            workspace.Members[i].Role = role
+            return s.persist()
+        }
+    }
+    workspace.Members = append(workspace.Members, WorkspaceMember{
+        UserID:  userID,
+        Role:    role,
+        AddedAt: time.Now(),
+    })
+    return s.persist()
+}
+
+// RemoveMember 移除成员
+func (s *WorkspaceStore) RemoveMember(id, userID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    workspace, ok := s.workspaces[id]
+    if !ok {
+        return fmt.Errorf("工作区不存在")
+    }
+    for i, member := range workspace.Members {
+        if member.UserID == userID {
+            if member.Role == RolePublisher && workspace.publishers() == 1 {
+                return fmt.Errorf("工作区至少需要一名发布者")
+            }
+            workspace.Members = append(workspace.Members[:i], workspace.Members[i+1:]...)
+            return s.persist()
+        }
+    }
+    return fmt.Errorf("用户不是工作区成员")
+}
+
+// AddAccount 把关联账号加入工作区
+func (s *WorkspaceStore) AddAccount(id, accountID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    workspace, ok := s.workspaces[id]
+    if !ok {
+        return fmt.Errorf("工作区不存在")
+    }
+    if workspace.HasAccount(accountID) {
+        return nil
+    }
+    workspace.AccountIDs = append(workspace.AccountIDs, accountID)
+    return s.persist()
+}
+
+// RemoveAccount 从工作区移除关联账号（账号解除关联时也会调用）
+func (s *WorkspaceStore) RemoveAccount(id, accountID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    workspace, ok := s.workspaces[id]
+    if !ok {
+        return fmt.Errorf("工作区不存在")
+    }
+    for i, existing := range workspace.AccountIDs {
+        if existing == accountID {
+            workspace.AccountIDs = append(workspace.AccountIDs[:i], workspace.AccountIDs[i+1:]...)
+            return s.persist()
+        }
+    }
+    return fmt.Errorf("账号不在工作区中")
+}
+
+// persist 写入文件（调用方需持有锁）
+func (s *WorkspaceStore) persist() error {
+    if s.path == "" {
+        return nil
+    }
+    list := make([]*Workspace, 0, len(s.workspaces))
+    for _, workspace := range s.workspaces {
+        list = append(list, workspace)
+    }
+    data, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return err
+    }
+    return os.WriteFile(s.path, data, 0600)
+}
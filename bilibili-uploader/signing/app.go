@@ -0,0 +1,30 @@
+// signing/app.go - App接口签名（appkey + sign）
+package signing
+
+import (
+    "crypto/md5"
+    "encoding/hex"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+// AppSign 计算App接口签名: md5(按key排序的参数串 + appsec)
+func AppSign(params url.Values, appSecret string) string {
+    sum := md5.Sum([]byte(params.Encode() + appSecret))
+    return hex.EncodeToString(sum[:])
+}
+
+// SignAppParams 为参数追加appkey、ts并签名，原参数会被修改
+func SignAppParams(params url.Values, appKey, appSecret string, now time.Time) url.Values {
+    params.Del("sign")
+    params.Set("appkey", appKey)
+    params.Set("ts", strconv.FormatInt(now.Unix(), 10))
+    params.Set("sign", AppSign(params, appSecret))
+    return params
+}
+
+// SignAppForm 签名App风格的表单请求体，返回编码后的表单
+func SignAppForm(form url.Values, appKey, appSecret string, now time.Time) string {
+    return SignAppParams(form, appKey, appSecret, now).Encode()
+}
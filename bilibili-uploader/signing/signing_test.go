@@ -0,0 +1,121 @@
+package signing
+
+import (
+    "net/url"
+    "testing"
+    "time"
+)
+
+// 示例数据来自 bilibili-API-collect 的WBI签名文档
+const (
+    testImgKey = "7cd084941338484aae1ad9425b84077c"
+    testSubKey = "4932caff0ff746eab6f01bf08b70ac45"
+)
+
+func TestMixinKey(t *testing.T) {
+    got := MixinKey(testImgKey, testSubKey)
+    want := "ea1db124af3c7062474693fa704f4ff8"
+    if got != want {
+        t.Fatalf("MixinKey() = %q, want %q", got, want)
+    }
+}
+
+func TestSignWbi(t *testing.T) {
+    tests := []struct {
+        name   string
+        params url.Values
+        want   string
+    }{
+        {
+            name:   "文档示例",
+            params: url.Values{"foo": {"114"}, "bar": {"514"}, "zab": {"1919810"}},
+            want:   "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+        },
+        {
+            name:   "过滤特殊字符并用%20编码空格",
+            params: url.Values{"keyword": {"(hello) world!"}, "mid": {"1"}},
+            want:   "keyword=hello%20world&mid=1&wts=1702204169&w_rid=f56dd924a1794925b14e3223a91a360d",
+        },
+        {
+            name:   "忽略已有的w_rid和wts",
+            params: url.Values{"foo": {"114"}, "bar": {"514"}, "zab": {"1919810"}, "wts": {"1"}, "w_rid": {"x"}},
+            want:   "bar=514&foo=114&wts=1702204169&zab=1919810&w_rid=8f6f2b5b3d485fe1886cec6a0be8c5d4",
+        },
+    }
+
+    now := time.Unix(1702204169, 0)
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := SignWbi(tt.params, testImgKey, testSubKey, now)
+            if got != tt.want {
+                t.Errorf("SignWbi() = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestWbiSignerCachesKeys(t *testing.T) {
+    calls := 0
+    signer := NewWbiSigner(func() (string, string, error) {
+        calls++
+        return testImgKey, testSubKey, nil
+    })
+
+    for i := 0; i < 3; i++ {
+        if _, err := signer.Sign(url.Values{"foo": {"114"}}); err != nil {
+            t.Fatalf("Sign() error = %v", err)
+        }
+    }
+    if calls != 1 {
+        t.Fatalf("fetch called %d times, want 1", calls)
+    }
+
+    signer.Invalidate()
+    if _, err := signer.Sign(url.Values{"foo": {"114"}}); err != nil {
+        t.Fatalf("Sign() error = %v", err)
+    }
+    if calls != 2 {
+        t.Fatalf("fetch called %d times after Invalidate, want 2", calls)
+    }
+}
+
+func TestKeyFromURL(t *testing.T) {
+    got := keyFromURL("https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png")
+    if got != testImgKey {
+        t.Fatalf("keyFromURL() = %q, want %q", got, testImgKey)
+    }
+}
+
+func TestSignAppParams(t *testing.T) {
+    params := url.Values{
+        "id":   {"114514"},
+        "str":  {"1919810"},
+        "test": {"いいよ，こいよ"},
+    }
+    SignAppParams(params, "1d8b6e7d45233436", "560c52ccd288fed045859ed18bffd973", time.Unix(1702204169, 0))
+
+    if got, want := params.Get("sign"), "d54317b2dea8f9df3a14f02aeddc2b20"; got != want {
+        t.Errorf("sign = %q, want %q", got, want)
+    }
+    if got := params.Get("appkey"); got != "1d8b6e7d45233436" {
+        t.Errorf("appkey = %q", got)
+    }
+    if got := params.Get("ts"); got != "1702204169" {
+        t.Errorf("ts = %q", got)
+    }
+}
+
+func TestSignAppFormResign(t *testing.T) {
+    form := url.Values{"id": {"114514"}, "sign": {"stale"}}
+    got := SignAppForm(form, "1d8b6e7d45233436", "560c52ccd288fed045859ed18bffd973", time.Unix(1702204169, 0))
+
+    parsed, err := url.ParseQuery(got)
+    if err != nil {
+        t.Fatalf("ParseQuery() error = %v", err)
+    }
+    parsed.Del("sign")
+    want := AppSign(parsed, "560c52ccd288fed045859ed18bffd973")
+    if form.Get("sign") != want {
+        t.Errorf("sign = %q, want %q", form.Get("sign"), want)
+    }
+}
@@ -0,0 +1,167 @@
+// signing/wbi.go - WBI签名（网页接口 w_rid/wts）
+package signing
+
+import (
+    "crypto/md5"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "path"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// mixinKeyEncTab img_key+sub_key 重排表
+var mixinKeyEncTab = []int{
+    46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35, 27, 43, 5, 49,
+    33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13, 37, 48, 7, 16, 24, 55, 40,
+    61, 26, 17, 0, 1, 60, 51, 30, 4, 22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11,
+    36, 20, 34, 44, 52,
+}
+
+// MixinKey 由img_key和sub_key混合得到32位签名密钥
+func MixinKey(imgKey, subKey string) string {
+    raw := imgKey + subKey
+    var b strings.Builder
+    for _, i := range mixinKeyEncTab {
+        if i < len(raw) {
+            b.WriteByte(raw[i])
+        }
+    }
+    key := b.String()
+    if len(key) > 32 {
+        key = key[:32]
+    }
+    return key
+}
+
+// SignWbi 为查询参数追加wts和w_rid，返回签名后的查询串
+func SignWbi(params url.Values, imgKey, subKey string, now time.Time) string {
+    signed := url.Values{}
+    for key, values := range params {
+        if key == "w_rid" || key == "wts" {
+            continue
+        }
+        for _, value := range values {
+            signed.Add(key, sanitizeWbiValue(value))
+        }
+    }
+    signed.Set("wts", strconv.FormatInt(now.Unix(), 10))
+
+    // url.Values.Encode 已按key排序，空格需编码为%20
+    query := strings.ReplaceAll(signed.Encode(), "+", "%20")
+    sum := md5.Sum([]byte(query + MixinKey(imgKey, subKey)))
+
+    return query + "&w_rid=" + hex.EncodeToString(sum[:])
+}
+
+// sanitizeWbiValue 去除值中的 !'()* 字符
+func sanitizeWbiValue(value string) string {
+    return strings.Map(func(r rune) rune {
+        if strings.ContainsRune("!'()*", r) {
+            return -1
+        }
+        return r
+    }, value)
+}
+
+// KeyFetcher 获取当前的img_key和sub_key
+type KeyFetcher func() (imgKey, subKey string, err error)
+
+// NavKeyFetcher 从nav接口的wbi_img字段获取密钥（未登录也会返回）
+func NavKeyFetcher(apiBaseURL string, client *http.Client) KeyFetcher {
+    if client == nil {
+        client = &http.Client{Timeout: 10 * time.Second}
+    }
+    return func() (string, string, error) {
+        resp, err := client.Get(apiBaseURL + "/x/web-interface/nav")
+        if err != nil {
+            return "", "", fmt.Errorf("获取WBI密钥失败: %v", err)
+        }
+        defer resp.Body.Close()
+
+        var result struct {
+            Data struct {
+                WbiImg struct {
+                    ImgURL string `json:"img_url"`
+                    SubURL string `json:"sub_url"`
+                } `json:"wbi_img"`
+            } `json:"data"`
+        }
+        if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+            return "", "", fmt.Errorf("解析WBI密钥失败: %v", err)
+        }
+
+        imgKey := keyFromURL(result.Data.WbiImg.ImgURL)
+        subKey := keyFromURL(result.Data.WbiImg.SubURL)
+        if imgKey == "" || subKey == "" {
+            return "", "", fmt.Errorf("nav响应中没有wbi_img")
+        }
+        return imgKey, subKey, nil
+    }
+}
+
+// keyFromURL 取URL中的文件名（去掉扩展名）作为密钥
+func keyFromURL(rawURL string) string {
+    base := path.Base(rawURL)
+    if base == "." || base == "/" {
+        return ""
+    }
+    return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// WbiSigner 带密钥缓存的WBI签名器
+type WbiSigner struct {
+    TTL   time.Duration // 密钥缓存时长，B站每天更换一次
+    Fetch KeyFetcher
+
+    mu        sync.Mutex
+    imgKey    string
+    subKey    string
+    fetchedAt time.Time
+}
+
+// NewWbiSigner 创建WBI签名器
+func NewWbiSigner(fetch KeyFetcher) *WbiSigner {
+    return &WbiSigner{
+        TTL:   time.Hour,
+        Fetch: fetch,
+    }
+}
+
+// Sign 签名查询参数，必要时刷新密钥
+func (s *WbiSigner) Sign(params url.Values) (string, error) {
+    imgKey, subKey, err := s.keys()
+    if err != nil {
+        return "", err
+    }
+    return SignWbi(params, imgKey, subKey, time.Now()), nil
+}
+
+// Invalidate 清除缓存的密钥（接口返回-352等签名错误时调用）
+func (s *WbiSigner) Invalidate() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.fetchedAt = time.Time{}
+}
+
+// keys 获取缓存的密钥，过期时重新获取
+func (s *WbiSigner) keys() (string, string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.imgKey != "" && time.Since(s.fetchedAt) < s.TTL {
+        return s.imgKey, s.subKey, nil
+    }
+
+    imgKey, subKey, err := s.Fetch()
+    if err != nil {
+        return "", "", err
+    }
+    s.imgKey, s.subKey, s.fetchedAt = imgKey, subKey, time.Now()
+    return imgKey, subKey, nil
+}
@@ -0,0 +1,112 @@
+// tracing/tracing.go - 链路追踪：OpenTelemetry导出器（OTLP或标准输出）和全局TracerProvider
+package tracing
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// 导出器类型
+const (
+    ExporterNone   = "none"
+    ExporterOTLP   = "otlp"
+    ExporterStdout = "stdout"
+)
+
+// OTLP协议
+const (
+    ProtocolHTTP = "http/protobuf"
+    ProtocolGRPC = "grpc"
+)
+
+// Options 链路追踪配置
+// OTLP的地址、请求头和超时由导出器读取OTEL_EXPORTER_OTLP_*环境变量，采样率读取OTEL_TRACES_SAMPLER*
+type Options struct {
+    Exporter    string    // none、otlp、stdout，为空时不导出
+    Protocol    string    // OTLP协议：http/protobuf或grpc，为空时使用http/protobuf
+    ServiceName string    // 服务名，为空时使用bilibili-uploader
+    Output      io.Writer // stdout导出器的输出，为空时写入标准输出
+}
+
+// ShutdownFunc 导出剩余的span并关闭导出器
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup 创建导出器和TracerProvider并设为全局，同时启用W3C traceparent传播
+// 退出前调用返回的ShutdownFunc，否则最后一批span可能丢失
+func Setup(ctx context.Context, opts Options) (ShutdownFunc, error) {
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+    exporter, err := newExporter(ctx, opts)
+    if err != nil {
+        return nil, err
+    }
+    if exporter == nil {
+        return func(context.Context) error { return nil }, nil
+    }
+
+    serviceName := opts.ServiceName
+    if serviceName == "" {
+        serviceName = "bilibili-uploader"
+    }
+    res, err := resource.New(ctx,
+        resource.WithAttributes(semconv.ServiceName(serviceName)),
+        resource.WithFromEnv(),
+        resource.WithTelemetrySDK(),
+        resource.WithHost(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("创建链路追踪资源失败: %v", err)
+    }
+
+    provider := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exporter),
+        sdktrace.WithResource(res),
+    )
+    otel.SetTracerProvider(provider)
+    return provider.Shutdown, nil
+}
+
+// newExporter 按配置创建导出器，none时返回nil
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+    switch strings.ToLower(opts.Exporter) {
+    case "", ExporterNone:
+        return nil, nil
+    case ExporterStdout, "console":
+        output := opts.Output
+        if output == nil {
+            output = os.Stdout
+        }
+        return stdouttrace.New(stdouttrace.WithWriter(output), stdouttrace.WithPrettyPrint())
+    case ExporterOTLP:
+        switch strings.ToLower(opts.Protocol) {
+        case "", ProtocolHTTP:
+            return otlptracehttp.New(ctx)
+        case ProtocolGRPC:
+            return otlptracegrpc.New(ctx)
+        }
+        return nil, fmt.Errorf("不支持的OTLP协议: %s（可选http/protobuf、grpc）", opts.Protocol)
+    }
+    return nil, fmt.Errorf("不支持的链路追踪导出器: %s（可选otlp、stdout、none）", opts.Exporter)
+}
+
+// TraceID ctx中span的trace ID，没有有效span时返回空字符串
+func TraceID(ctx context.Context) string {
+    spanContext := trace.SpanContextFromContext(ctx)
+    if !spanContext.HasTraceID() {
+        return ""
+    }
+    return spanContext.TraceID().String()
+}
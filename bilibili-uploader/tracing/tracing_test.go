@@ -0,0 +1,53 @@
+package tracing
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+
+    "go.opentelemetry.io/otel"
+)
+
+func TestStdoutExporter(t *testing.T) {
+    var buf bytes.Buffer
+    shutdown, err := Setup(context.Background(), Options{Exporter: ExporterStdout, ServiceName: "test-service", Output: &buf})
+    if err != nil {
+        t.Fatalf("Setup() error = %v", err)
+    }
+
+    ctx, span := otel.Tracer("test").Start(context.Background(), "上传视频")
+    if TraceID(ctx) == "" {
+        t.Errorf("TraceID() empty inside a recording span")
+    }
+    span.End()
+    if err := shutdown(context.Background()); err != nil {
+        t.Fatalf("shutdown() error = %v", err)
+    }
+
+    for _, want := range []string{"上传视频", "test-service", TraceID(ctx)} {
+        if !strings.Contains(buf.String(), want) {
+            t.Errorf("exported spans missing %q: %s", want, buf.String())
+        }
+    }
+}
+
+func TestSetupOptions(t *testing.T) {
+    shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+    if err != nil {
+        t.Fatalf("Setup(none) error = %v", err)
+    }
+    if err := shutdown(context.Background()); err != nil {
+        t.Errorf("shutdown() error = %v", err)
+    }
+
+    if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+        t.Errorf("Setup() with unknown exporter succeeded")
+    }
+    if _, err := Setup(context.Background(), Options{Exporter: ExporterOTLP, Protocol: "http/json"}); err == nil {
+        t.Errorf("Setup() with unknown protocol succeeded")
+    }
+    if TraceID(context.Background()) != "" {
+        t.Errorf("TraceID() without span not empty")
+    }
+}